@@ -3,8 +3,14 @@
 // Goroutine / GC / Heap / Blocked samplers and streams data to the configured
 // FlareGo Gateway.  Intended for scenarios where you cannot import the agent
 // package into the target process but still want to collect traces (e.g., run
-// as a sidecar and attach via pprof HTTP endpoints in future versions).  For
-// v0.1 the agent simply samples itself – useful for demo and load testing.
+// as a sidecar).  With one or more --pprof targets the agent scrapes their
+// net/http/pprof endpoints instead of sampling itself:
+//
+//	flarego-agent --pprof api=http://10.0.0.7:6060 --pprof http://10.0.0.8:6060
+//
+// Without targets it samples itself – useful for demo and load testing.
+// --labels tags the stream (e.g. service=api) so `flarego record
+// --from-gateway --selector` can pick this agent out of the fleet.
 package main
 
 import (
@@ -28,6 +34,21 @@ func main() {
     gatewayAddr := flag.String("gateway", "localhost:4317", "FlareGo gateway gRPC address")
     hz := flag.Int("hz", 100, "Sampling frequency in Hz")
     runFor := flag.Duration("duration", 0, "Optional duration to run; 0 = until signal")
+    authToken := flag.String("auth-token", "", "Bearer token presented to the gateway")
+    tlsCert := flag.String("tls-cert", "", "Client certificate for mTLS (PEM)")
+    tlsKey := flag.String("tls-key", "", "Client key for mTLS (PEM)")
+    tlsCA := flag.String("tls-ca", "", "CA bundle used to verify the gateway (default: system roots)")
+    serverName := flag.String("tls-server-name", "", "Override the server name checked against the gateway certificate")
+    labels := flag.String("labels", "", "Labels identifying this agent to subscribers, e.g. service=api,env=prod")
+    var pprofTargets []sampler.PprofTarget
+    flag.Func("pprof", "Scrape a net/http/pprof target, `label=url` (repeatable)", func(v string) error {
+        t, err := sampler.ParsePprofTarget(v)
+        if err == nil {
+            pprofTargets = append(pprofTargets, t)
+        }
+        return err
+    })
+    scrapeEvery := flag.Duration("pprof-interval", 5*time.Second, "Scrape and export interval for --pprof targets")
     flag.Parse()
 
     // Logger ----------------------------------------------------------------
@@ -39,17 +60,31 @@ func main() {
     defer lg.Sync()
 
     // Collector -------------------------------------------------------------
+    exportEvery := 500 * time.Millisecond
+    if len(pprofTargets) > 0 {
+        exportEvery = *scrapeEvery // the collector scrapes right before each export
+    }
     col := agent.NewCollector(agent.Config{
         Hz:          *hz,
-        ExportEvery: 500 * time.Millisecond,
+        ExportEvery: exportEvery,
     })
-    col.AddSampler(sampler.NewGoroutineSampler(col.Builder(), *hz))
-    col.AddSampler(sampler.NewGCSampler(col.Builder(), 10))
-    col.AddSampler(sampler.NewHeapSampler(col.Builder(), 2))
-    col.AddSampler(sampler.NewBlockedSampler(col.Builder(), 50))
+    if len(pprofTargets) > 0 {
+        col.AddSampler(sampler.NewPprofScraper(col.Builder(), pprofTargets))
+    } else {
+        col.AddSampler(sampler.NewGoroutineSampler(col.Builder(), *hz))
+        col.AddSampler(sampler.NewGCSampler(col.Builder(), 10))
+        col.AddSampler(sampler.NewHeapSampler(col.Builder(), 2))
+        col.AddSampler(sampler.NewBlockedSampler(col.Builder(), 50))
+    }
 
     exp, err := exporter.NewGRPCExporter(context.Background(), exporter.Config{
-        Addr: *gatewayAddr,
+        Addr:        *gatewayAddr,
+        AuthToken:   *authToken,
+        TLSCertPath: *tlsCert,
+        TLSKeyPath:  *tlsKey,
+        TLSCAPath:   *tlsCA,
+        ServerName:  *serverName,
+        Labels:      *labels,
     })
     if err != nil {
         lg.Fatal("grpc exporter", zap.Error(err))
@@ -10,8 +10,25 @@
 //	HTTP_LISTEN   – HTTP listen address (default :8080)
 //	RETENTION     – retention window (e.g., 15m)
 //	AUTH_TOKEN    – static bearer token (optional)
+//	TOKEN_FILE     – static tokens with per-token scopes (optional)
+//	TENANTS_FILE   – per-tenant quotas (`tenants:` list)
+//	JWT_SECRET     – HS256 secret for JWT auth (optional)
+//	JWT_KEYS       – PEM or JWKS file with RS256/ES256/EdDSA public keys
+//	JWT_ISSUER     – required iss claim
+//	JWT_AUDIENCE   – required aud claim
+//	JWT_DENYLIST   – revoked token ids (`flarego token revoke`)
 //	TLS_CERT      – path to TLS certificate (PEM)
 //	TLS_KEY       – path to TLS key (PEM)
+//	TLS_CLIENT_CA  – CA bundle for verifying agent certificates (mTLS)
+//	WEBHOOK_URL    – alert webhook endpoint (optional)
+//	WEBHOOK_SECRET – HMAC key used to sign webhook deliveries
+//	WEBHOOK_OUTBOX – directory of the persistent webhook outbox
+//	ALERTS_FILE    – YAML/TOML/JSON file with an `alerts:` rule list
+//	ARTIFACT_DIR   – directory for alert evidence (.fgo)
+//	AUDIT_LOG      – JSON-lines audit trail (auth failures, subscriptions, admin calls)
+//	PUBLIC_URL     – external HTTP base URL used in evidence links
+//	JIRA_EMAIL     – Jira account for "jira:" sinks
+//	JIRA_TOKEN     – Jira API token for "jira:" sinks
 //
 // Usage pattern from main.go:
 //
@@ -20,6 +37,7 @@ package main
 
 import (
 	"flag"
+	"log"
 	"time"
 
 	"github.com/spf13/viper"
@@ -43,10 +61,39 @@ func loadGatewayConfig() (gateway.Config, gateway.HTTPConfig) {
     httpListen := flag.String("http-listen", httpCfg.ListenAddr, "HTTP listen address (host:port, empty to disable)")
     tlsCert := flag.String("tls-cert", "", "TLS certificate file (PEM)")
     tlsKey := flag.String("tls-key", "", "TLS key file (PEM)")
+    clientCA := flag.String("tls-client-ca", "", "CA bundle for verifying agent client certificates (enables mTLS)")
+    requireClientCert := flag.Bool("tls-require-client-cert", false, "Reject TLS clients without a verified certificate")
     authToken := flag.String("auth-token", "", "Static bearer token (optional)")
+    tokenFile := flag.String("token-file", "", "File of static tokens with subjects and scopes (ingest, read, admin)")
+    tenantsFile := flag.String("tenants-file", "", "Config file containing a `tenants:` quota list")
+    tenantAgents := flag.Int("tenant-max-agents", 0, "Default per-tenant limit on concurrent agents (0 = unlimited)")
+    tenantSubs := flag.Int("tenant-max-subscribers", 0, "Default per-tenant limit on concurrent subscribers (0 = unlimited)")
+    agentChunkRate := flag.Float64("agent-max-chunks-per-sec", 0, "Per-agent chunk rate limit (0 = unlimited)")
+    agentByteRate := flag.Float64("agent-max-bytes-per-sec", 0, "Per-agent byte rate limit (0 = unlimited)")
+    tenantChunkRate := flag.Float64("tenant-max-chunks-per-sec", 0, "Default per-tenant chunk rate limit across all agents (0 = unlimited)")
+    tenantByteRate := flag.Float64("tenant-max-bytes-per-sec", 0, "Default per-tenant byte rate limit across all agents (0 = unlimited)")
+    maxPayload := flag.Int("max-payload-bytes", 0, "Largest accepted chunk payload (0 = unlimited)")
+    maxNodes := flag.Int("max-nodes", 0, "Largest accepted flamegraph, in nodes (0 = unlimited)")
+    jwtDenylist := flag.String("jwt-denylist", "", "Denylist file of revoked token ids (written by `flarego token revoke`)")
+    jwtTenantClaim := flag.String("jwt-tenant-claim", "tenant", "JWT claim naming the caller's tenant")
+    jwtSecret := flag.String("jwt-secret", "", "HS256 secret for JWT auth (optional)")
+    jwtKeys := flag.String("jwt-keys", "", "PEM or JWKS file with JWT verification keys (reloaded periodically)")
+    jwtRefresh := flag.Duration("jwt-keys-refresh", 5*time.Minute, "Reload interval for --jwt-keys")
+    jwtIssuer := flag.String("jwt-issuer", "", "Required JWT iss claim (optional)")
+    jwtAudience := flag.String("jwt-audience", "", "Required JWT aud claim (optional)")
     retention := flag.Duration("retention", gwCfg.RetentionDur, "Retention window (e.g., 15m)")
     maxClients := flag.Int("max-clients", gwCfg.MaxClients, "Soft limit on WebSocket subscribers")
     disableMetrics := flag.Bool("no-metrics", false, "Disable Prometheus /metrics endpoint")
+    webhookURL := flag.String("webhook-url", "", "Alert webhook endpoint (optional)")
+    webhookSecret := flag.String("webhook-secret", "", "HMAC secret for signing webhook deliveries")
+    webhookOutbox := flag.String("webhook-outbox", "", "Directory for the persistent webhook outbox (empty = in memory)")
+    alertsFile := flag.String("alerts-file", "", "Config file containing an `alerts:` rule list")
+    artifactDir := flag.String("artifact-dir", "", "Directory for alert evidence .fgo files (empty disables capture)")
+    auditLog := flag.String("audit-log", "", "Audit log file (JSON lines; empty disables auditing)")
+    auditMaxSize := flag.Int64("audit-max-size-mb", 100, "Rotate the audit log after this many MiB")
+    auditBackups := flag.Int("audit-max-backups", 5, "Rotated audit log files to keep")
+    publicURL := flag.String("public-url", "", "External HTTP base URL used in alert evidence links")
+    baseline := flag.Duration("baseline-window", time.Minute, "History diffed against when an alert fires")
     flag.Parse()
 
     // ----- merge precedence: flags > env > defaults ------------------------
@@ -63,26 +110,125 @@ func loadGatewayConfig() (gateway.Config, gateway.HTTPConfig) {
     if tok := v.GetString("AUTH_TOKEN"); tok != "" {
         gwCfg.AuthToken = tok
     }
+    if f := v.GetString("TOKEN_FILE"); f != "" && *tokenFile == "" {
+        *tokenFile = f
+    }
+    if f := v.GetString("TENANTS_FILE"); f != "" && *tenantsFile == "" {
+        *tenantsFile = f
+    }
+    if sec := v.GetString("JWT_SECRET"); sec != "" && *jwtSecret == "" {
+        *jwtSecret = sec
+    }
+    if k := v.GetString("JWT_KEYS"); k != "" && *jwtKeys == "" {
+        *jwtKeys = k
+    }
+    if iss := v.GetString("JWT_ISSUER"); iss != "" && *jwtIssuer == "" {
+        *jwtIssuer = iss
+    }
+    if aud := v.GetString("JWT_AUDIENCE"); aud != "" && *jwtAudience == "" {
+        *jwtAudience = aud
+    }
+    if d := v.GetString("JWT_DENYLIST"); d != "" && *jwtDenylist == "" {
+        *jwtDenylist = d
+    }
     if c := v.GetString("TLS_CERT"); c != "" {
         *tlsCert = c
     }
     if k := v.GetString("TLS_KEY"); k != "" {
         *tlsKey = k
     }
+    if ca := v.GetString("TLS_CLIENT_CA"); ca != "" && *clientCA == "" {
+        *clientCA = ca
+    }
+    if u := v.GetString("WEBHOOK_URL"); u != "" && *webhookURL == "" {
+        *webhookURL = u
+    }
+    if sec := v.GetString("WEBHOOK_SECRET"); sec != "" && *webhookSecret == "" {
+        *webhookSecret = sec
+    }
+    if d := v.GetString("WEBHOOK_OUTBOX"); d != "" && *webhookOutbox == "" {
+        *webhookOutbox = d
+    }
+    if f := v.GetString("ALERTS_FILE"); f != "" && *alertsFile == "" {
+        *alertsFile = f
+    }
+    if d := v.GetString("ARTIFACT_DIR"); d != "" && *artifactDir == "" {
+        *artifactDir = d
+    }
+    if f := v.GetString("AUDIT_LOG"); f != "" && *auditLog == "" {
+        *auditLog = f
+    }
+    if u := v.GetString("PUBLIC_URL"); u != "" && *publicURL == "" {
+        *publicURL = u
+    }
+    gwCfg.JiraEmail = v.GetString("JIRA_EMAIL")
+    gwCfg.JiraToken = v.GetString("JIRA_TOKEN")
 
     // ----- apply flags -----------------------------------------------------
     gwCfg.ListenAddr = *listen
     gwCfg.AuthToken = *authToken
     gwCfg.RetentionDur = *retention
+    gwCfg.TokenFile = *tokenFile
+    gwCfg.JWT = gateway.JWTConfig{
+        Secret:          []byte(*jwtSecret),
+        KeysFile:        *jwtKeys,
+        RefreshInterval: *jwtRefresh,
+        Issuer:          *jwtIssuer,
+        Audience:        *jwtAudience,
+        TenantClaim:     *jwtTenantClaim,
+        DenylistFile:    *jwtDenylist,
+    }
+    if *jwtSecret == "" {
+        gwCfg.JWT.Secret = nil
+    }
     gwCfg.MaxClients = *maxClients
+    gwCfg.DefaultQuota = gateway.TenantQuota{
+        MaxAgents:      *tenantAgents,
+        MaxSubscribers: *tenantSubs,
+        Agent: gateway.IngestLimits{
+            ChunksPerSec:    *agentChunkRate,
+            BytesPerSec:     *agentByteRate,
+            MaxPayloadBytes: *maxPayload,
+            MaxNodes:        *maxNodes,
+        },
+        Ingest: gateway.IngestLimits{ChunksPerSec: *tenantChunkRate, BytesPerSec: *tenantByteRate},
+    }
+    if *tenantsFile != "" {
+        quotas, err := gateway.LoadTenantsFile(*tenantsFile)
+        if err != nil {
+            log.Fatalf("tenants file: %v", err)
+        }
+        gwCfg.Tenants = quotas
+    }
     httpCfg.ListenAddr = *httpListen
     httpCfg.EnableMetrics = !*disableMetrics
+    gwCfg.WebhookURL = *webhookURL
+    gwCfg.WebhookSecret = *webhookSecret
+    gwCfg.WebhookOutboxDir = *webhookOutbox
+    gwCfg.ArtifactDir = *artifactDir
+    gwCfg.AuditLogPath = *auditLog
+    gwCfg.AuditMaxBytes = *auditMaxSize << 20
+    gwCfg.AuditMaxBackups = *auditBackups
+    gwCfg.PublicURL = *publicURL
+    gwCfg.BaselineWindow = *baseline
+    if *alertsFile != "" {
+        rv := viper.New()
+        rv.SetConfigFile(*alertsFile)
+        if err := rv.ReadInConfig(); err != nil {
+            log.Fatalf("alerts file: %v", err)
+        }
+        if err := rv.UnmarshalKey("alerts", &gwCfg.AlertRules); err != nil {
+            log.Fatalf("alerts file: %v", err)
+        }
+    }
 
     // TLS handled by gateway.LoadConfig, but honour flags here too.
     if *tlsCert != "" && *tlsKey != "" {
         gwCfg.TLSCertPath = *tlsCert
         gwCfg.TLSKeyPath = *tlsKey
     }
+    gwCfg.ClientCAPath = *clientCA
+    gwCfg.RequireClientCert = *requireClientCert
 
     // sanity clamps
     if gwCfg.RetentionDur < time.Minute {
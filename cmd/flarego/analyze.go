@@ -0,0 +1,308 @@
+// cmd/flarego/analyze.go
+// Implements `flarego analyze`, offline analysis of a recording in the
+// spirit of `go tool pprof`'s text reports:
+//
+//	flarego analyze flare.fgo                      # top 10 by self and by cum
+//	flarego analyze flare.fgo --by package --top 20
+//	flarego analyze flare.fgo --by module --tree      # grpc vs. our code vs. std
+//	flarego analyze flare.fgo --tree --depth 4 --min-share 1
+//	flarego analyze cpu.pb.gz --unit nanoseconds --peek 'json\.Marshal'
+//	flarego analyze flare.fgo --invert --depth 3     # bottom-up call tree
+//	flarego analyze flare.fgo --butterfly 'mallocgc$'
+//
+// Self is a frame's value minus its children's (values are inclusive), cum
+// the value of its outermost occurrence on each path.  Shares are relative
+// to the stack total without the runtime bands, which are reported
+// separately in their own units.  --invert prints the call tree bottom‑up
+// (flamegraph.Invert); --butterfly prints the merged callers and callees of
+// each matching function, with shares relative to that function's cum.
+// --by package|module applies to every view: the tables fold frames by unit
+// and the trees are regrouped with flamegraph.GroupBy, so consecutive frames
+// of one unit become a single frame.
+// --format json emits the same data.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+func newAnalyzeCmd() *cobra.Command {
+    var (
+        format   string
+        output   string
+        top      int
+        sortBy   string
+        by       string
+        unit     string
+        tree     bool
+        depth    int
+        minShare float64
+        peek     string
+        invert   bool
+        fly      string
+    )
+    cmd := &cobra.Command{
+        Use:   "analyze <file>",
+        Short: "Print top functions, call tree and callers/callees of a recording",
+        Args:  cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            g, err := flamegraph.ParseGranularity(by)
+            if err != nil {
+                return fmt.Errorf("--by: %w", err)
+            }
+            if sortBy != "both" && sortBy != "self" && sortBy != "cum" {
+                return fmt.Errorf("--sort: want self, cum or both, got %q", sortBy)
+            }
+            var peekRe, flyRe *regexp.Regexp
+            if peek != "" {
+                if peekRe, err = regexp.Compile(peek); err != nil {
+                    return fmt.Errorf("--peek: %w", err)
+                }
+            }
+            if fly != "" {
+                if flyRe, err = regexp.Compile(fly); err != nil {
+                    return fmt.Errorf("--butterfly: %w", err)
+                }
+            }
+            root, err := loadFlameFile(args[0])
+            if err != nil {
+                return err
+            }
+
+            stacks := flamegraph.WithoutBands(root)
+            rep := analyzeReport{
+                File:  args[0],
+                Unit:  unit,
+                By:    g,
+                Total: flamegraph.Total(stacks),
+            }
+            for _, b := range flamegraph.RuntimeBands {
+                if c, ok := root.Children[b]; ok {
+                    u, _ := flamegraph.BandUnit(b)
+                    rep.Bands = append(rep.Bands, analyzeBand{Name: b, Value: c.Value, Unit: u})
+                }
+            }
+            view := stacks
+            if g != flamegraph.ByFunction {
+                view = flamegraph.GroupBy(g)(stacks)
+            }
+            hs := flamegraph.HotspotsBy(stacks, g.Key)
+            if sortBy != "self" {
+                rep.TopCum = firstN(hs, top)
+            }
+            if sortBy != "cum" {
+                bySelf := append([]flamegraph.Hotspot(nil), hs...)
+                sort.SliceStable(bySelf, func(i, j int) bool { return bySelf[i].Self > bySelf[j].Self })
+                rep.TopSelf = firstN(bySelf, top)
+            }
+            switch {
+            case invert:
+                rep.Inverted = true
+                rep.Tree = treeLines(flamegraph.Invert(view), depth, minShare)
+            case tree:
+                rep.Tree = treeLines(view, depth, minShare)
+            }
+            if peekRe != nil {
+                rep.Peek = flamegraph.Peek(view, peekRe)
+                if len(rep.Peek) == 0 {
+                    return fmt.Errorf("--peek: no frame matches %q", peek)
+                }
+            }
+            if flyRe != nil {
+                for _, v := range flamegraph.Butterflies(view, flyRe) {
+                    rep.Butterflies = append(rep.Butterflies, butterflyReport{
+                        Hotspot: v.Hotspot,
+                        Callers: treeLines(v.Callers, depth, minShare),
+                        Callees: treeLines(v.Callees, depth, minShare),
+                    })
+                }
+                if len(rep.Butterflies) == 0 {
+                    return fmt.Errorf("--butterfly: no frame matches %q", fly)
+                }
+            }
+
+            var w io.Writer = os.Stdout
+            if output != "" && output != "-" {
+                f, err := os.Create(output)
+                if err != nil {
+                    return err
+                }
+                defer f.Close()
+                w = f
+            }
+            switch format {
+            case "text":
+                rep.writeText(w)
+                return nil
+            case "json":
+                enc := json.NewEncoder(w)
+                enc.SetIndent("", "  ")
+                return enc.Encode(rep)
+            }
+            return fmt.Errorf("--format: unknown format %q (text, json)", format)
+        },
+    }
+    cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+    cmd.Flags().StringVarP(&output, "output", "o", "", "Write the report to this file instead of stdout")
+    cmd.Flags().IntVar(&top, "top", 10, "Number of rows in the top tables")
+    cmd.Flags().StringVar(&sortBy, "sort", "both", "Top tables to print: self, cum or both")
+    cmd.Flags().StringVar(&by, "by", "function", "Aggregate frames by function, package or module (tables, trees, peek and butterfly)")
+    cmd.Flags().StringVar(&unit, "unit", flamegraph.UnitSamples, "Unit of stack values: samples, nanoseconds, bytes or count")
+    cmd.Flags().BoolVar(&tree, "tree", false, "Print the call tree")
+    cmd.Flags().IntVar(&depth, "depth", 4, "Levels of the call tree to print")
+    cmd.Flags().Float64Var(&minShare, "min-share", 1, "Omit call tree frames below this percentage of the total")
+    cmd.Flags().StringVar(&peek, "peek", "", "List callers and callees of functions matching this regex")
+    cmd.Flags().BoolVar(&invert, "invert", false, "Print the call tree bottom-up, hot functions first with their callers below")
+    cmd.Flags().StringVar(&fly, "butterfly", "", "Print the merged caller and callee trees of functions matching this regex")
+    return cmd
+}
+
+// analyzeReport bundles everything the output formats need.
+type analyzeReport struct {
+    File        string                 `json:"file"`
+    Unit        string                 `json:"unit"`
+    By          flamegraph.Granularity `json:"by"`
+    Total       int64                  `json:"total"`
+    Bands       []analyzeBand          `json:"bands,omitempty"`
+    TopSelf     []flamegraph.Hotspot   `json:"top_self,omitempty"`
+    TopCum      []flamegraph.Hotspot   `json:"top_cum,omitempty"`
+    Inverted    bool                   `json:"inverted,omitempty"`
+    Tree        []treeLine             `json:"tree,omitempty"`
+    Peek        []flamegraph.PeekEntry `json:"peek,omitempty"`
+    Butterflies []butterflyReport      `json:"butterflies,omitempty"`
+}
+
+// butterflyReport is a flamegraph.ButterflyView cut down to tree lines.
+type butterflyReport struct {
+    flamegraph.Hotspot
+    Callers []treeLine `json:"callers"`
+    Callees []treeLine `json:"callees"`
+}
+
+type analyzeBand struct {
+    Name  string `json:"name"`
+    Value int64  `json:"value"`
+    Unit  string `json:"unit"`
+}
+
+// treeLine is one printed node of the depth‑limited call tree.
+type treeLine struct {
+    Depth int    `json:"depth"`
+    Name  string `json:"name"`
+    Self  int64  `json:"self"`
+    Cum   int64  `json:"cum"`
+}
+
+func firstN(hs []flamegraph.Hotspot, n int) []flamegraph.Hotspot {
+    if n > 0 && len(hs) > n {
+        return hs[:n]
+    }
+    return hs
+}
+
+// treeLines walks root down to maxDepth levels, heaviest children first,
+// skipping frames below minShare percent of the total.
+func treeLines(root *flamegraph.Frame, maxDepth int, minShare float64) []treeLine {
+    total := flamegraph.Total(root)
+    var out []treeLine
+    var walk func(f *flamegraph.Frame, depth int)
+    walk = func(f *flamegraph.Frame, depth int) {
+        if depth > maxDepth || pct(f.Value, total) < minShare {
+            return
+        }
+        out = append(out, treeLine{Depth: depth, Name: f.Name, Self: flamegraph.SelfValue(f), Cum: f.Value})
+        for _, c := range flamegraph.ChildrenByWeight(f) {
+            walk(c, depth+1)
+        }
+    }
+    for _, c := range flamegraph.ChildrenByWeight(root) {
+        walk(c, 1)
+    }
+    return out
+}
+
+//--------------------------------------------------------------------
+// text
+//--------------------------------------------------------------------
+
+func (r *analyzeReport) writeText(w io.Writer) {
+    v := func(x int64) string { return flamegraph.FormatValue(x, r.Unit) }
+    fmt.Fprintf(w, "File: %s\n", r.File)
+    fmt.Fprintf(w, "Total: %s %s\n", v(r.Total), r.Unit)
+    if len(r.Bands) > 0 {
+        var parts []string
+        for _, b := range r.Bands {
+            parts = append(parts, b.Name+" "+flamegraph.FormatValue(b.Value, b.Unit))
+        }
+        fmt.Fprintf(w, "Runtime bands: %s\n", strings.Join(parts, ", "))
+    }
+
+    table := func(title string, rows []flamegraph.Hotspot) {
+        if rows == nil {
+            return
+        }
+        fmt.Fprintf(w, "\n%s\n", title)
+        fmt.Fprintf(w, "  %12s %7s %7s  %12s %7s  %s\n", "SELF", "SELF%", "SUM%", "CUM", "CUM%", strings.ToUpper(string(r.By)))
+        var sum int64
+        for _, h := range rows {
+            sum += h.Self
+            fmt.Fprintf(w, "  %12s %6.2f%% %6.2f%%  %12s %6.2f%%  %s\n",
+                v(h.Self), pct(h.Self, r.Total), pct(sum, r.Total), v(h.Cum), pct(h.Cum, r.Total), h.Name)
+        }
+    }
+    table(fmt.Sprintf("Top %d by self:", len(r.TopSelf)), r.TopSelf)
+    table(fmt.Sprintf("Top %d by cum:", len(r.TopCum)), r.TopCum)
+
+    if r.Tree != nil {
+        if r.Inverted {
+            fmt.Fprintln(w, "\nCall tree (bottom-up):")
+        } else {
+            fmt.Fprintln(w, "\nCall tree:")
+        }
+        fmt.Fprintf(w, "  %12s %7s  %12s  %s\n", "CUM", "CUM%", "SELF", strings.ToUpper(string(r.By)))
+        for _, l := range r.Tree {
+            fmt.Fprintf(w, "  %12s %6.2f%%  %12s  %s%s\n", v(l.Cum), pct(l.Cum, r.Total), v(l.Self), strings.Repeat("  ", l.Depth-1), l.Name)
+        }
+    }
+
+    // pprof -peek layout: callers above, callees below the function line.
+    for _, e := range r.Peek {
+        fmt.Fprintf(w, "\n%s\n", strings.Repeat("-", 72))
+        for _, c := range e.Callers {
+            fmt.Fprintf(w, "  %34s %6.2f%% |   %s\n", v(c.Value), pct(c.Value, e.Cum), c.Name)
+        }
+        fmt.Fprintf(w, "  %12s %6.2f%%  %12s %6.2f%% | %s\n", v(e.Self), pct(e.Self, r.Total), v(e.Cum), pct(e.Cum, r.Total), e.Name)
+        for _, c := range e.Callees {
+            fmt.Fprintf(w, "  %34s %6.2f%% |   %s\n", v(c.Value), pct(c.Value, e.Cum), c.Name)
+        }
+    }
+
+    // Butterfly: the caller tree grows upwards from the function, so it is
+    // printed deepest caller first, the callee tree downwards below it.
+    for _, b := range r.Butterflies {
+        fmt.Fprintf(w, "\n%s\n", strings.Repeat("=", 72))
+        fmt.Fprintf(w, "  %12s %7s  %s\n", "CUM", "SHARE", "CALLERS OF "+b.Name)
+        maxDepth := 0
+        for _, l := range b.Callers {
+            maxDepth = max(maxDepth, l.Depth)
+        }
+        for i := len(b.Callers) - 1; i >= 0; i-- {
+            l := b.Callers[i]
+            fmt.Fprintf(w, "  %12s %6.2f%%  %s%s\n", v(l.Cum), pct(l.Cum, b.Cum), strings.Repeat("  ", maxDepth-l.Depth), l.Name)
+        }
+        fmt.Fprintf(w, "  %12s %6.2f%%  %s%s  (self %s, %.2f%% of total)\n", v(b.Cum), pct(b.Cum, r.Total), strings.Repeat("  ", maxDepth), b.Name, v(b.Self), pct(b.Self, r.Total))
+        for _, l := range b.Callees {
+            fmt.Fprintf(w, "  %12s %6.2f%%  %s%s\n", v(l.Cum), pct(l.Cum, b.Cum), strings.Repeat("  ", maxDepth+l.Depth), l.Name)
+        }
+    }
+}
@@ -1,12 +1,13 @@
 // cmd/flarego/attach.go
-// Implements the `flarego attach` command.  For v0.1 this command starts an
-// in‑process agent that samples the *current* Go program (i.e., the flarego
-// CLI itself) for quick local experimentation.  In later versions it will
-// support eBPF‑based dynamic attach to arbitrary PIDs.
+// Implements the `flarego attach` command.  Without --pprof this command
+// starts an in‑process agent that samples the *current* Go program (i.e., the
+// flarego CLI itself) for quick local experimentation.  With --pprof it
+// scrapes the net/http/pprof endpoints of the given services instead.
 //
 // Typical usage:
 //
 //	flarego attach --gateway localhost:4317 --duration 30s
+//	flarego attach --pprof api=http://localhost:6060 --pprof-interval 2s
 //
 // The command spins up a Collector with a GoroutineSampler and a gRPC Exporter
 // pointed at the specified gateway address.  It shuts down cleanly on SIGINT
@@ -32,6 +33,9 @@ func newAttachCmd() *cobra.Command {
         gatewayAddr string
         sampleHz    int
         duration    time.Duration
+        expCfg      exporter.Config
+        pprofURLs   []string
+        scrapeEvery time.Duration
     )
 
     cmd := &cobra.Command{
@@ -44,17 +48,32 @@ func newAttachCmd() *cobra.Command {
             }
             defer cancel()
 
+            var targets []sampler.PprofTarget
+            for _, u := range pprofURLs {
+                t, err := sampler.ParsePprofTarget(u)
+                if err != nil {
+                    return err
+                }
+                targets = append(targets, t)
+            }
+
             // Set up collector.
+            exportEvery := 500 * time.Millisecond
+            if len(targets) > 0 {
+                exportEvery = scrapeEvery // the collector scrapes right before each export
+            }
             col := agent.NewCollector(agent.Config{
                 Hz:          sampleHz,
-                ExportEvery: 500 * time.Millisecond,
+                ExportEvery: exportEvery,
             })
-            gs := sampler.NewGoroutineSampler(col.Builder(), sampleHz)
-            col.AddSampler(gs)
+            if len(targets) > 0 {
+                col.AddSampler(sampler.NewPprofScraper(col.Builder(), targets))
+            } else {
+                col.AddSampler(sampler.NewGoroutineSampler(col.Builder(), sampleHz))
+            }
 
-            exp, err := exporter.NewGRPCExporter(ctx, exporter.Config{
-                Addr: gatewayAddr,
-            })
+            expCfg.Addr = gatewayAddr
+            exp, err := exporter.NewGRPCExporter(ctx, expCfg)
             if err != nil {
                 return err
             }
@@ -83,5 +102,13 @@ func newAttachCmd() *cobra.Command {
     cmd.Flags().StringVar(&gatewayAddr, "gateway", "localhost:4317", "FlareGo gateway gRPC address (host:port)")
     cmd.Flags().IntVar(&sampleHz, "hz", 100, "Sampling frequency in Hz (1‑10000)")
     cmd.Flags().DurationVar(&duration, "duration", 0, "Optional run time (e.g., 30s); 0 = run until Ctrl‑C")
+    cmd.Flags().StringArrayVar(&pprofURLs, "pprof", nil, "Scrape a net/http/pprof target instead of this process, label=url (repeatable)")
+    cmd.Flags().DurationVar(&scrapeEvery, "pprof-interval", 5*time.Second, "Scrape and export interval for --pprof targets")
+    cmd.Flags().StringVar(&expCfg.AuthToken, "auth-token", "", "Bearer token presented to the gateway")
+    cmd.Flags().StringVar(&expCfg.Labels, "labels", "", "Labels identifying this agent to subscribers, e.g. service=api,env=prod")
+    cmd.Flags().StringVar(&expCfg.TLSCertPath, "tls-cert", "", "Client certificate for mTLS (PEM)")
+    cmd.Flags().StringVar(&expCfg.TLSKeyPath, "tls-key", "", "Client key for mTLS (PEM)")
+    cmd.Flags().StringVar(&expCfg.TLSCAPath, "tls-ca", "", "CA bundle used to verify the gateway (default: system roots)")
+    cmd.Flags().StringVar(&expCfg.ServerName, "tls-server-name", "", "Override the server name checked against the gateway certificate")
     return cmd
 }
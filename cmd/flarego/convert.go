@@ -0,0 +1,144 @@
+// cmd/flarego/convert.go
+// Implements `flarego convert`, which translates profiles between the formats
+// registered with pkg/flamegraph:
+//
+//	flarego convert flare.fgo flare.folded          # formats from file names
+//	flarego convert cpu.pb.gz - --to speedscope     # to stdout
+//	cat stacks.txt | flarego convert - out.fgo --from folded
+//
+// The input format is detected from the content unless --from is given; the
+// output format comes from --to or the output file's extension.  Anything the
+// target format cannot represent is reported on stderr, and --strict turns
+// such a loss into a non‑zero exit.  For pprof, --sample-index picks the
+// value to read (e.g. alloc_space of a heap profile) and --sample-type labels
+// the value written (e.g. cpu/nanoseconds).  --transform rewrites the tree
+// between reading and writing, e.g. to strip runtime frames from an export.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+	"github.com/Voskan/flarego/pkg/flamegraph/pprof"
+)
+
+func newConvertCmd() *cobra.Command {
+    var (
+        from, to    string
+        strict      bool
+        sampleIndex string
+        sampleType  string
+        trans       transformFlags
+    )
+    cmd := &cobra.Command{
+        Use:   "convert <input|-> <output|->",
+        Short: "Convert profiles between .fgo, JSON, folded, speedscope and pprof",
+        Long: "Convert profiles between formats. Supported: " + formatList() + ".\n" +
+            "The input format is auto-detected; the output format is taken from --to or the output file extension.",
+        Args: cobra.ExactArgs(2),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            in, out := args[0], args[1]
+            target := flamegraph.Format(to)
+            if target == "" {
+                f, ok := flamegraph.FormatForPath(out)
+                if !ok || out == "-" {
+                    return fmt.Errorf("cannot infer output format from %q; use --to (%s)", out, formatList())
+                }
+                target = f
+            }
+            if _, ok := flamegraph.LookupCodec(target); !ok {
+                return fmt.Errorf("--to: unknown format %q (%s)", target, formatList())
+            }
+            tr, err := trans.parse()
+            if err != nil {
+                return err
+            }
+
+            var r io.Reader = os.Stdin
+            if in != "-" {
+                f, err := os.Open(in)
+                if err != nil {
+                    return err
+                }
+                defer f.Close()
+                r = f
+            }
+            var (
+                root   *flamegraph.Frame
+                source flamegraph.Format
+            )
+            switch {
+            case sampleIndex != "":
+                if from != "" && from != string(flamegraph.FormatPprof) {
+                    return fmt.Errorf("--sample-index applies to pprof input only")
+                }
+                source = flamegraph.FormatPprof
+                root, err = pprof.DecodeIndex(r, sampleIndex)
+            case from != "":
+                source = flamegraph.Format(from)
+                root, err = flamegraph.ReadFormat(r, source)
+            default:
+                root, source, err = flamegraph.Read(r)
+            }
+            if err != nil {
+                return fmt.Errorf("%s: %w", in, err)
+            }
+            root = tr(root)
+
+            var w io.Writer = os.Stdout
+            var file *os.File
+            if out != "-" {
+                if file, err = os.Create(out); err != nil {
+                    return err
+                }
+                w = file
+            }
+            var loss flamegraph.Loss
+            if target == flamegraph.FormatPprof && sampleType != "" {
+                vt, perr := pprof.ParseValueType(sampleType)
+                if perr != nil {
+                    return perr
+                }
+                loss, err = pprof.EncodeWith(w, root, pprof.Options{SampleType: vt})
+            } else {
+                loss, err = flamegraph.Write(w, root, target)
+            }
+            if file != nil {
+                if cerr := file.Close(); err == nil {
+                    err = cerr
+                }
+            }
+            if err != nil {
+                return err
+            }
+            for _, l := range loss {
+                fmt.Fprintf(os.Stderr, "loss (%s → %s): %s\n", source, target, l)
+            }
+            if strict && len(loss) > 0 {
+                cmd.SilenceUsage = true
+                return fmt.Errorf("conversion from %s to %s is lossy", source, target)
+            }
+            return nil
+        },
+    }
+    cmd.Flags().StringVar(&from, "from", "", "Input format (default: auto-detect)")
+    cmd.Flags().StringVar(&to, "to", "", "Output format (default: from output extension)")
+    cmd.Flags().StringVar(&sampleIndex, "sample-index", "", "pprof input: sample type to read, by name or position (default: the profile's default)")
+    cmd.Flags().StringVar(&sampleType, "sample-type", "", "pprof output: type/unit of the written values (default: samples/count)")
+    trans.register(cmd.Flags())
+    cmd.Flags().BoolVar(&strict, "strict", false, "Fail when the output format cannot represent the whole input")
+    return cmd
+}
+
+func formatList() string {
+    var names []string
+    for _, c := range flamegraph.Codecs() {
+        names = append(names, string(c.Format))
+    }
+    return strings.Join(names, ", ")
+}
@@ -0,0 +1,303 @@
+// cmd/flarego/diff.go
+// Implements `flarego diff`, the comparison of two recordings that CI jobs
+// gate on:
+//
+//	flarego diff before.fgo after.fgo
+//	flarego diff before.fgo after.fgo --fail-on 'frame=^encoding/json\.,growth>10%'
+//	flarego diff before.fgo after.fgo --format junit -o diff.xml --fail-on 'self>2'
+//
+// Frames are compared by their share of each recording's total
+// (flamegraph.Compare), so recordings of different length or load remain
+// comparable.  The text report ranks the frames whose self share grew and
+// shrank the most; --format json|junit emit the same data for tools, and
+// --format tree keeps the raw flamegraph.Diff output.  --transform rewrites
+// both recordings the same way before they are compared.  The command exits
+// non‑zero when any --fail-on rule is violated.
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+func newDiffCmd() *cobra.Command {
+    var (
+        format string
+        output string
+        top    int
+        rules  []string
+        trans  transformFlags
+    )
+    cmd := &cobra.Command{
+        Use:   "diff <before.fgo> <after.fgo>",
+        Short: "Compare two flamegraph recordings and gate on regressions",
+        Args:  cobra.ExactArgs(2),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            var thresholds []flamegraph.Threshold
+            for _, r := range rules {
+                th, err := flamegraph.ParseThreshold(r)
+                if err != nil {
+                    return err
+                }
+                thresholds = append(thresholds, th)
+            }
+            tr, err := trans.parse()
+            if err != nil {
+                return err
+            }
+            before, err := loadFlameFile(args[0])
+            if err != nil {
+                return err
+            }
+            after, err := loadFlameFile(args[1])
+            if err != nil {
+                return err
+            }
+            before, after = tr(before), tr(after)
+
+            var w io.Writer = os.Stdout
+            if output != "" {
+                f, err := os.Create(output)
+                if err != nil {
+                    return err
+                }
+                defer f.Close()
+                w = f
+            }
+
+            rep := diffReport{
+                Base:       args[0],
+                Head:       args[1],
+                Comparison: flamegraph.Compare(before, after),
+            }
+            for _, th := range thresholds {
+                rep.Results = append(rep.Results, thresholdResult{Threshold: th, Violations: th.Violations(rep.Comparison)})
+            }
+
+            switch format {
+            case "text":
+                rep.writeText(w, top)
+            case "json":
+                err = rep.writeJSON(w, top)
+            case "junit":
+                err = rep.writeJUnit(w)
+            case "tree":
+                err = flamegraph.EncodeJSON(w, flamegraph.Diff(after, before), flamegraph.JSONOptions{Indent: "  "})
+            default:
+                return fmt.Errorf("--format: unknown format %q (text, json, junit, tree)", format)
+            }
+            if err != nil {
+                return err
+            }
+            if n := rep.failed(); n > 0 {
+                cmd.SilenceUsage = true
+                return fmt.Errorf("%d of %d regression threshold(s) exceeded", n, len(rep.Results))
+            }
+            return nil
+        },
+    }
+    cmd.Flags().StringVar(&format, "format", "text", "Output format: text, json, junit or tree (raw diff tree)")
+    cmd.Flags().StringVarP(&output, "output", "o", "", "Write the report to this file instead of stdout")
+    cmd.Flags().IntVar(&top, "top", 10, "Number of grown and shrunk frames to list")
+    trans.register(cmd.Flags())
+    cmd.Flags().StringArrayVar(&rules, "fail-on", nil, "Regression rule, e.g. 'frame=^main\\.,growth>10%' (repeatable; metrics: growth, cum, self, share)")
+    return cmd
+}
+
+// diffReport bundles everything the output formats need.
+type diffReport struct {
+    Base, Head string
+    *flamegraph.Comparison
+    Results []thresholdResult
+}
+
+type thresholdResult struct {
+    flamegraph.Threshold
+    Violations []flamegraph.FrameChange
+}
+
+func (r *diffReport) failed() int {
+    n := 0
+    for _, res := range r.Results {
+        if len(res.Violations) > 0 {
+            n++
+        }
+    }
+    return n
+}
+
+//--------------------------------------------------------------------
+// text
+//--------------------------------------------------------------------
+
+func (r *diffReport) writeText(w io.Writer, top int) {
+    fmt.Fprintf(w, "base  %s  (total %d)\n", r.Base, r.BaseTotal)
+    fmt.Fprintf(w, "head  %s  (total %d)\n", r.Head, r.HeadTotal)
+
+    grew, shrank := r.Ranked(top)
+    section := func(title string, rows []flamegraph.FrameChange) {
+        fmt.Fprintf(w, "\n%s\n", title)
+        if len(rows) == 0 {
+            fmt.Fprintln(w, "  (none)")
+            return
+        }
+        fmt.Fprintf(w, "  %8s %8s %8s  %8s %8s %9s  %s\n", "SELF%", "→", "Δpp", "CUM%", "→", "GROWTH", "FRAME")
+        for _, c := range rows {
+            fmt.Fprintf(w, "  %7.2f%% %7.2f%% %+8.2f  %7.2f%% %7.2f%% %9s  %s\n",
+                c.BaseSelf, c.HeadSelf, c.SelfDelta(), c.BaseCum, c.HeadCum, fmtGrowth(c.Growth()), c.Name)
+        }
+    }
+    section("Grew (share of total, base → head):", grew)
+    section("Shrank (share of total, base → head):", shrank)
+
+    if len(r.Results) == 0 {
+        return
+    }
+    fmt.Fprintln(w, "\nThresholds:")
+    for _, res := range r.Results {
+        if len(res.Violations) == 0 {
+            fmt.Fprintf(w, "  ok    %s\n", res.Rule)
+            continue
+        }
+        fmt.Fprintf(w, "  FAIL  %s\n", res.Rule)
+        for _, c := range res.Violations {
+            fmt.Fprintf(w, "          %s  self %+.2fpp  cum %+.2fpp  growth %s\n",
+                c.Name, c.SelfDelta(), c.CumDelta(), fmtGrowth(c.Growth()))
+        }
+    }
+}
+
+func fmtGrowth(g float64) string {
+    if math.IsInf(g, 1) {
+        return "new"
+    }
+    return fmt.Sprintf("%+.1f%%", g)
+}
+
+//--------------------------------------------------------------------
+// json
+//--------------------------------------------------------------------
+
+// jsonChange flattens the derived metrics; Growth is null for new frames
+// because JSON has no infinity.
+type jsonChange struct {
+    flamegraph.FrameChange
+    SelfDelta float64  `json:"self_delta_pp"`
+    CumDelta  float64  `json:"cum_delta_pp"`
+    Growth    *float64 `json:"growth_pct"`
+    New       bool     `json:"new,omitempty"`
+}
+
+func toJSONChanges(in []flamegraph.FrameChange) []jsonChange {
+    out := make([]jsonChange, 0, len(in))
+    for _, c := range in {
+        jc := jsonChange{FrameChange: c, SelfDelta: c.SelfDelta(), CumDelta: c.CumDelta()}
+        if g := c.Growth(); math.IsInf(g, 1) {
+            jc.New = true
+        } else {
+            jc.Growth = &g
+        }
+        out = append(out, jc)
+    }
+    return out
+}
+
+func (r *diffReport) writeJSON(w io.Writer, top int) error {
+    type jsonResult struct {
+        flamegraph.Threshold
+        Passed     bool         `json:"passed"`
+        Violations []jsonChange `json:"violations"`
+    }
+    grew, shrank := r.Ranked(top)
+    out := struct {
+        Base       string       `json:"base"`
+        Head       string       `json:"head"`
+        BaseTotal  int64        `json:"base_total"`
+        HeadTotal  int64        `json:"head_total"`
+        Grew       []jsonChange `json:"grew"`
+        Shrank     []jsonChange `json:"shrank"`
+        Thresholds []jsonResult `json:"thresholds"`
+        Passed     bool         `json:"passed"`
+    }{
+        Base: r.Base, Head: r.Head, BaseTotal: r.BaseTotal, HeadTotal: r.HeadTotal,
+        Grew: toJSONChanges(grew), Shrank: toJSONChanges(shrank),
+        Thresholds: []jsonResult{}, Passed: r.failed() == 0,
+    }
+    for _, res := range r.Results {
+        out.Thresholds = append(out.Thresholds, jsonResult{
+            Threshold:  res.Threshold,
+            Passed:     len(res.Violations) == 0,
+            Violations: toJSONChanges(res.Violations),
+        })
+    }
+    enc := json.NewEncoder(w)
+    enc.SetIndent("", "  ")
+    return enc.Encode(out)
+}
+
+//--------------------------------------------------------------------
+// junit
+//--------------------------------------------------------------------
+
+// JUnit XML as understood by common CI systems: one test case per --fail-on
+// rule.
+type junitSuite struct {
+    XMLName  xml.Name    `xml:"testsuite"`
+    Name     string      `xml:"name,attr"`
+    Tests    int         `xml:"tests,attr"`
+    Failures int         `xml:"failures,attr"`
+    Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+    Name      string        `xml:"name,attr"`
+    ClassName string        `xml:"classname,attr"`
+    Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+    Message string `xml:"message,attr"`
+    Text    string `xml:",chardata"`
+}
+
+func (r *diffReport) writeJUnit(w io.Writer) error {
+    suite := junitSuite{
+        Name:     "flarego diff " + r.Base + " → " + r.Head,
+        Tests:    len(r.Results),
+        Failures: r.failed(),
+    }
+    for _, res := range r.Results {
+        tc := junitCase{Name: res.Rule, ClassName: "flarego.diff"}
+        if len(res.Violations) > 0 {
+            var b strings.Builder
+            for _, c := range res.Violations {
+                fmt.Fprintf(&b, "%s: self %+.2fpp, cum %+.2fpp, growth %s\n",
+                    c.Name, c.SelfDelta(), c.CumDelta(), fmtGrowth(c.Growth()))
+            }
+            tc.Failure = &junitFailure{
+                Message: fmt.Sprintf("%d frame(s) exceed %s", len(res.Violations), res.Rule),
+                Text:    b.String(),
+            }
+        }
+        suite.Cases = append(suite.Cases, tc)
+    }
+    if _, err := io.WriteString(w, xml.Header); err != nil {
+        return err
+    }
+    enc := xml.NewEncoder(w)
+    enc.Indent("", "  ")
+    if err := enc.Encode(suite); err != nil {
+        return err
+    }
+    _, err := fmt.Fprintln(w)
+    return err
+}
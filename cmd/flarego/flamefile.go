@@ -0,0 +1,52 @@
+// cmd/flarego/flamefile.go
+// Shared loading of flamegraph files for the commands that read them (top,
+// diff, …).  Any format registered with pkg/flamegraph is accepted and
+// detected from the content, so `record --no-compress` output, folded stacks
+// and pprof captures all work where a .fgo is expected.  transformFlags adds
+// the --transform flag of the commands that rewrite what they load.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+	_ "github.com/Voskan/flarego/pkg/flamegraph/pprof" // registers the pprof codec
+)
+
+// loadFlameFile reads a profile in any supported format.
+func loadFlameFile(path string) (*flamegraph.Frame, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    root, _, err := flamegraph.Read(f)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    return root, nil
+}
+
+// transformFlags collects repeatable --transform specs
+// (flamegraph.ParseTransform), applied in the order given.
+type transformFlags []string
+
+func (t *transformFlags) register(fs *pflag.FlagSet) {
+    fs.StringArrayVar((*[]string)(t), "transform", nil,
+        "Rewrite the tree: focus=RE, ignore=RE, hide=RE, prune=1%, maxdepth=N, collapse, invert, group=package|module, rename=RE=>REPL (repeatable)")
+}
+
+// parse returns the chained transforms, or the identity when none were given.
+func (t transformFlags) parse() (flamegraph.Transform, error) {
+    tr, err := flamegraph.ParseTransforms(t)
+    if err != nil {
+        return nil, fmt.Errorf("--transform: %w", err)
+    }
+    if tr == nil {
+        return func(root *flamegraph.Frame) *flamegraph.Frame { return root }, nil
+    }
+    return tr, nil
+}
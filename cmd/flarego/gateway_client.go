@@ -0,0 +1,74 @@
+// cmd/flarego/gateway_client.go
+// Connection flags shared by commands that talk to a gateway as a client
+// (top, record --from-gateway, replay --to-gateway).  Mirrors the agent's
+// exporter options: bearer token, optional mTLS material, and a plaintext
+// switch for local gateways started without certificates.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/Voskan/flarego/internal/util"
+)
+
+// gatewayFlags holds client connection settings.
+type gatewayFlags struct {
+    Addr       string
+    AuthToken  string
+    Plaintext  bool
+    TLSCert    string
+    TLSKey     string
+    TLSCA      string
+    ServerName string
+}
+
+// register adds the flags to fs.
+func (g *gatewayFlags) register(fs *pflag.FlagSet) {
+    fs.StringVar(&g.Addr, "gateway", "localhost:4317", "FlareGo gateway gRPC address (host:port)")
+    g.registerConn(fs)
+}
+
+// registerConn adds every flag but the address, for commands that name the
+// gateway flag after its role (e.g. --to-gateway).
+func (g *gatewayFlags) registerConn(fs *pflag.FlagSet) {
+    fs.StringVar(&g.AuthToken, "auth-token", "", "Bearer token presented to the gateway")
+    fs.BoolVar(&g.Plaintext, "plaintext", false, "Connect without TLS (local gateways without certificates)")
+    fs.StringVar(&g.TLSCert, "tls-cert", "", "Client certificate for mTLS (PEM)")
+    fs.StringVar(&g.TLSKey, "tls-key", "", "Client key for mTLS (PEM)")
+    fs.StringVar(&g.TLSCA, "tls-ca", "", "CA bundle used to verify the gateway (default: system roots)")
+    fs.StringVar(&g.ServerName, "tls-server-name", "", "Override the server name checked against the gateway certificate")
+}
+
+// dial connects to the gateway.  The connection is lazy; errors surface on
+// the first RPC.
+func (g *gatewayFlags) dial() (*grpc.ClientConn, error) {
+    var creds credentials.TransportCredentials
+    switch {
+    case g.Plaintext:
+        creds = insecure.NewCredentials()
+    case g.TLSCert != "" || g.TLSCA != "":
+        r, err := util.NewCertReloader(g.TLSCert, g.TLSKey, g.TLSCA)
+        if err != nil {
+            return nil, err
+        }
+        creds = credentials.NewTLS(r.ClientConfig(g.ServerName, g.Addr))
+    default:
+        creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12, ServerName: g.ServerName})
+    }
+    return grpc.NewClient(g.Addr, grpc.WithTransportCredentials(creds))
+}
+
+// outgoing attaches the bearer token to ctx.
+func (g *gatewayFlags) outgoing(ctx context.Context) context.Context {
+    if g.AuthToken == "" {
+        return ctx
+    }
+    return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+g.AuthToken)
+}
@@ -1,42 +1,58 @@
 // cmd/flarego/record.go
 // Implements the `flarego record` command.  It starts an in‑process agent,
-// samples the current program for a fixed duration and writes the aggregated
-// flame‑graph to a `.fgo` file on disk.  The output is a gzipped JSON blob so
-// that `flarego replay <file>` can load it instantly, while still being
-// usable by external tools after decompression.
+// samples the current program for a fixed duration and writes a `.fgo` v2
+// container: a header describing the recording (host, pid, Go version, build,
+// samplers) followed by one timestamped snapshot every --snapshot-every, so
+// `flarego replay` can show the timeline or any window of it.  Snapshots are
+// appended as they are taken; a recording that is interrupted before the
+// index is written remains readable.  With --from-gateway the snapshots come
+// from agents streaming to a gateway instead (see record_gateway.go).
 package main
 
 import (
-	"compress/gzip"
+	"bufio"
 	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/Voskan/flarego/internal/agent"
 	"github.com/Voskan/flarego/internal/agent/sampler"
+	"github.com/Voskan/flarego/internal/gateway"
 	"github.com/Voskan/flarego/internal/logging"
+	"github.com/Voskan/flarego/pkg/flamegraph"
 )
 
 func newRecordCmd() *cobra.Command {
     var (
-        outFile    string
-        duration   time.Duration
-        sampleHz   int
-        noCompress bool
+        outFile       string
+        duration      time.Duration
+        snapshotEvery time.Duration
+        sampleHz      int
+        noCompress    bool
+        gw            gatewayFlags
+        selector      string
     )
 
     cmd := &cobra.Command{
         Use:   "record",
-        Short: "Record a local flame graph snapshot to a .fgo file",
-        Long:  `Starts a lightweight agent inside the flarego process, samples runtime activity for the specified duration and stores the resulting flame‑graph JSON (optionally gzipped) to disk.`,
+        Short: "Record a flame graph timeline to a .fgo file",
+        Long:  `Starts a lightweight agent inside the flarego process, samples runtime activity for the specified duration and stores a snapshot every --snapshot-every in a .fgo v2 container together with metadata about the recording.  With --from-gateway it records the snapshots that agents matching --selector send to a gateway instead, each labelled with its agent.`,
         RunE: func(cmd *cobra.Command, args []string) error {
             if duration <= 0 {
                 return fmt.Errorf("--duration must be > 0")
             }
+            if snapshotEvery <= 0 {
+                return fmt.Errorf("--snapshot-every must be > 0")
+            }
+            if _, err := gateway.ParseSelector(selector); err != nil {
+                return fmt.Errorf("--selector: %w", err)
+            }
             // Default output filename: flare-2025‑05‑27T18‑00‑00.fgo
             if outFile == "" {
                 ts := time.Now().Format("20060102T150405")
@@ -46,57 +62,130 @@ func newRecordCmd() *cobra.Command {
                 outFile += ".fgo"
             }
 
+            f, err := os.Create(outFile)
+            if err != nil {
+                return err
+            }
+            defer f.Close()
+            bw := bufio.NewWriter(f)
+
+            if gw.Addr != "" {
+                start := time.Now()
+                hdr := flamegraph.Header{
+                    Start:  start,
+                    Labels: map[string]string{"gateway": gw.Addr},
+                }
+                if selector != "" {
+                    hdr.Labels["selector"] = selector
+                }
+                cw, err := flamegraph.NewContainerWriter(bw, hdr, !noCompress)
+                if err != nil {
+                    return err
+                }
+                cmd.SilenceUsage = true
+                n, err := recordFromGateway(cmd.Context(), gw, selector, duration, cw, bw.Flush)
+                if err != nil {
+                    return err
+                }
+                if err := cw.Close(time.Now()); err != nil {
+                    return err
+                }
+                if err := bw.Flush(); err != nil {
+                    return err
+                }
+                logging.Sugar().Infow("recording saved", "file", outFile, "snapshots", n)
+                return nil
+            }
+
+            start := time.Now()
+            hdr := recordingHeader(start, []string{"goroutine", "gc"}, sampleHz)
+            cw, err := flamegraph.NewContainerWriter(bw, hdr, !noCompress)
+            if err != nil {
+                return err
+            }
+
             ctx, cancel := context.WithTimeout(cmd.Context(), duration)
             defer cancel()
 
             // Collector with Goroutine & GC samplers.
             col := agent.NewCollector(agent.Config{
                 Hz:          sampleHz,
-                ExportEvery: 0, // no live export
+                ExportEvery: 0, // snapshots are taken below
             })
             col.AddSampler(sampler.NewGoroutineSampler(col.Builder(), sampleHz))
             col.AddSampler(sampler.NewGCSampler(col.Builder(), 10))
             col.Start()
-            logging.Sugar().Infow("recording started", "duration", duration, "hz", sampleHz)
+            logging.Sugar().Infow("recording started", "duration", duration, "hz", sampleHz, "snapshot_every", snapshotEvery)
 
-            <-ctx.Done()
+            // Build resets the builder, so each snapshot covers one interval.
+            snapshots := 0
+            snap := func(t time.Time) error {
+                snapshots++
+                if err := cw.WriteSnapshot(flamegraph.Snapshot{Time: t, Root: col.Builder().Build()}); err != nil {
+                    return err
+                }
+                return bw.Flush()
+            }
+            ticker := time.NewTicker(snapshotEvery)
+            defer ticker.Stop()
+        loop:
+            for {
+                select {
+                case t := <-ticker.C:
+                    if err := snap(t); err != nil {
+                        col.Stop()
+                        return err
+                    }
+                case <-ctx.Done():
+                    break loop
+                }
+            }
             col.Stop()
-            root := col.Builder().Build()
-
-            data, err := root.ToJSON()
-            if err != nil {
+            end := time.Now()
+            if err := snap(end); err != nil {
                 return err
             }
-
-            f, err := os.Create(outFile)
-            if err != nil {
+            if err := cw.Close(end); err != nil {
                 return err
             }
-            defer f.Close()
-
-            if noCompress {
-                if _, err := f.Write(data); err != nil {
-                    return err
-                }
-            } else {
-                gw := gzip.NewWriter(f)
-                if _, err := gw.Write(data); err != nil {
-                    _ = gw.Close()
-                    return err
-                }
-                if err := gw.Close(); err != nil {
-                    return err
-                }
+            if err := bw.Flush(); err != nil {
+                return err
             }
 
-            logging.Sugar().Infow("recording saved", "file", outFile, "size", len(data))
+            logging.Sugar().Infow("recording saved", "file", outFile, "snapshots", snapshots)
             return nil
         },
     }
 
     cmd.Flags().DurationVarP(&duration, "duration", "d", 30*time.Second, "Recording duration (e.g., 30s, 2m)")
+    cmd.Flags().DurationVar(&snapshotEvery, "snapshot-every", time.Second, "Interval between timeline snapshots")
     cmd.Flags().StringVarP(&outFile, "output", "o", "", "Output .fgo file path (default auto‑named)")
     cmd.Flags().IntVar(&sampleHz, "hz", 100, "Sampling frequency in Hz")
-    cmd.Flags().BoolVar(&noCompress, "no-compress", false, "Disable gzip compression of output file")
+    cmd.Flags().BoolVar(&noCompress, "no-compress", false, "Store snapshots without gzip compression")
+    cmd.Flags().StringVar(&gw.Addr, "from-gateway", "", "Record the agents streaming to this gateway (host:port) instead of this process")
+    cmd.Flags().StringVar(&selector, "selector", "", "With --from-gateway, only agents with these labels, e.g. service=api,env=prod")
+    gw.registerConn(cmd.Flags())
     return cmd
 }
+
+// recordingHeader describes a recording of this process.
+func recordingHeader(start time.Time, samplers []string, hz int) flamegraph.Header {
+    host, _ := os.Hostname()
+    h := flamegraph.Header{
+        Host:      host,
+        PID:       os.Getpid(),
+        GoVersion: runtime.Version(),
+        Samplers:  samplers,
+        Hz:        hz,
+        Start:     start,
+    }
+    if bi, ok := debug.ReadBuildInfo(); ok {
+        h.Build = bi.Main.Path + "@" + bi.Main.Version
+        for _, s := range bi.Settings {
+            if s.Key == "vcs.revision" {
+                h.Build += " (" + s.Value + ")"
+            }
+        }
+    }
+    return h
+}
@@ -0,0 +1,114 @@
+// cmd/flarego/record_gateway.go
+// `flarego record --from-gateway`: records what a fleet sends to a gateway
+// instead of the CLI's own process.  The command subscribes through
+// UIService.StreamFlamegraphs with a label selector and asks for enveloped
+// chunks (see internal/gateway/selector.go), so every snapshot in the .fgo v2
+// output carries the agent that produced it: its ID, subject, address and
+// labels.  Only chunks arriving while recording are kept; the gateway's
+// retained history cannot be attributed to agents.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/Voskan/flarego/internal/gateway"
+	"github.com/Voskan/flarego/internal/logging"
+	agentpb "github.com/Voskan/flarego/internal/proto"
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+// recordFromGateway writes the selected agents' chunks to cw for duration and
+// returns the number of snapshots written.
+func recordFromGateway(ctx context.Context, gw gatewayFlags, selector string, duration time.Duration, cw *flamegraph.ContainerWriter, flush func() error) (int, error) {
+    if _, err := gateway.ParseSelector(selector); err != nil {
+        return 0, err
+    }
+    conn, err := gw.dial()
+    if err != nil {
+        return 0, err
+    }
+    defer conn.Close()
+
+    ctx, cancel := context.WithTimeout(ctx, duration)
+    defer cancel()
+    sctx := metadata.AppendToOutgoingContext(gw.outgoing(ctx), gateway.EnvelopeMetadata, "1")
+    if selector != "" {
+        sctx = metadata.AppendToOutgoingContext(sctx, gateway.SelectorMetadata, selector)
+    }
+    stream, err := agentpb.NewUIServiceClient(conn).StreamFlamegraphs(sctx, &emptypb.Empty{})
+    if err != nil {
+        return 0, fmt.Errorf("subscribe: %w", err)
+    }
+    logging.Sugar().Infow("recording from gateway", "gateway", gw.Addr, "selector", selector, "duration", duration)
+
+    n := 0
+    warned := false
+    for {
+        chunk, err := stream.Recv()
+        if err != nil {
+            code := status.Code(err)
+            if errors.Is(err, io.EOF) || code == codes.DeadlineExceeded || code == codes.Canceled {
+                return n, nil
+            }
+            return n, fmt.Errorf("receive: %w", err)
+        }
+        snap, enveloped, err := envelopeSnapshot(chunk.Payload)
+        if err != nil {
+            logging.Sugar().Warnw("skipping undecodable chunk", "err", err)
+            continue
+        }
+        if !enveloped && !warned {
+            // An older gateway ignores the subscription options.
+            logging.Sugar().Warnw("gateway sent plain chunks: no agent metadata, selector not applied")
+            warned = true
+        }
+        if err := cw.WriteSnapshot(snap); err != nil {
+            return n, err
+        }
+        if err := flush(); err != nil {
+            return n, err
+        }
+        n++
+    }
+}
+
+// envelopeSnapshot turns a ChunkEnvelope (or, from gateways without envelope
+// support, a plain Frame) into a snapshot labelled with its agent.
+func envelopeSnapshot(payload []byte) (flamegraph.Snapshot, bool, error) {
+    var env gateway.ChunkEnvelope
+    if err := json.Unmarshal(payload, &env); err != nil {
+        return flamegraph.Snapshot{}, false, err
+    }
+    if len(env.Root) == 0 {
+        var root flamegraph.Frame
+        if err := json.Unmarshal(payload, &root); err != nil {
+            return flamegraph.Snapshot{}, false, err
+        }
+        return flamegraph.Snapshot{Time: time.Now(), Root: &root}, false, nil
+    }
+    var root flamegraph.Frame
+    if err := json.Unmarshal(env.Root, &root); err != nil {
+        return flamegraph.Snapshot{}, true, err
+    }
+    labels := maps.Clone(env.Labels)
+    if labels == nil {
+        labels = make(map[string]string)
+    }
+    for k, v := range map[string]string{"agent": env.Agent, "subject": env.Subject, "remote_addr": env.RemoteAddr} {
+        if v != "" {
+            labels[k] = v
+        }
+    }
+    return flamegraph.Snapshot{Time: env.Time, Labels: labels, Root: &root}, true, nil
+}
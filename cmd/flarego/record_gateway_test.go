@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/Voskan/flarego/internal/gateway"
+	agentpb "github.com/Voskan/flarego/internal/proto"
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+// startGateway serves an open in‑process gateway until the test ends and
+// returns its address.
+func startGateway(t *testing.T) string {
+	t.Helper()
+	s, err := gateway.New(gateway.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go s.Serve(ctx, ln)
+	return ln.Addr().String()
+}
+
+// pushChunks streams a one‑frame chunk every interval as an agent labelled
+// with labels until ctx is done.
+func pushChunks(ctx context.Context, t *testing.T, addr, labels, frame string, interval time.Duration) {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sctx := metadata.AppendToOutgoingContext(ctx, gateway.LabelsMetadata, labels)
+	stream, err := agentpb.NewGatewayServiceClient(conn).Stream(sctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := flamegraph.New("root")
+	root.AddSample([]string{frame}, 1)
+	payload, _ := json.Marshal(root)
+	go func() {
+		defer conn.Close()
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if stream.Send(&agentpb.FlamegraphChunk{Payload: payload}) != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func TestEnvelopeSnapshot(t *testing.T) {
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	payload, _ := json.Marshal(gateway.ChunkEnvelope{
+		Time:    at,
+		Agent:   "a1",
+		Subject: "svc",
+		Labels:  map[string]string{"service": "api"},
+		Root:    json.RawMessage(`{"name":"root","children":{"main":{"name":"main","value":3}}}`),
+	})
+	snap, enveloped, err := envelopeSnapshot(payload)
+	if err != nil || !enveloped {
+		t.Fatalf("Expected an enveloped snapshot, got %v %v", enveloped, err)
+	}
+	if !snap.Time.Equal(at) || snap.Labels["agent"] != "a1" || snap.Labels["subject"] != "svc" || snap.Labels["service"] != "api" {
+		t.Errorf("Unexpected snapshot %+v", snap)
+	}
+	if _, ok := snap.Labels["remote_addr"]; ok {
+		t.Errorf("Expected empty agent fields to be left out")
+	}
+	if m := snap.Root.Children["main"]; m == nil || m.Value != 3 {
+		t.Errorf("Unexpected tree %+v", snap.Root)
+	}
+
+	snap, enveloped, err = envelopeSnapshot([]byte(`{"name":"root","children":{"main":{"name":"main","value":2}}}`))
+	if err != nil || enveloped {
+		t.Fatalf("Expected a plain chunk fallback, got %v %v", enveloped, err)
+	}
+	if snap.Labels != nil || snap.Root.Children["main"] == nil || snap.Root.Children["main"].Value != 2 {
+		t.Errorf("Unexpected fallback snapshot %+v", snap)
+	}
+	if _, _, err := envelopeSnapshot([]byte(`{"root":`)); err == nil {
+		t.Errorf("Expected an error for a truncated chunk")
+	}
+}
+
+func TestRecordFromGateway(t *testing.T) {
+	addr := startGateway(t)
+	gw := gatewayFlags{Addr: addr, Plaintext: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var buf bytes.Buffer
+	cw, err := flamegraph.NewContainerWriter(&buf, flamegraph.Header{Start: time.Now()}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := recordFromGateway(ctx, gw, "service=api", time.Second, cw, func() error { return nil })
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond) // let the subscription start
+	pushChunks(ctx, t, addr, "service=api", "api.work", 50*time.Millisecond)
+	pushChunks(ctx, t, addr, "service=db", "db.work", 50*time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if err := cw.Close(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, snaps, err := flamegraph.ReadContainer(&buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(snaps) == 0 {
+		t.Fatal("Expected snapshots from the selected agent")
+	}
+	for _, s := range snaps {
+		if s.Labels["service"] != "api" || s.Labels["agent"] == "" || s.Root.Children["db.work"] != nil {
+			t.Errorf("Expected only labelled api snapshots, got %+v", s)
+		}
+	}
+
+	if _, err := recordFromGateway(ctx, gw, "service:api", time.Second, cw, func() error { return nil }); err == nil {
+		t.Errorf("Expected a malformed selector to be rejected")
+	}
+}
@@ -0,0 +1,59 @@
+// cmd/flarego/render.go
+// Implements `flarego render`, which draws a recording as a static SVG
+// (pkg/flamegraph.RenderSVG) that can be attached to tickets or opened in any
+// browser:
+//
+//	flarego render flare.fgo -o flare.svg --search 'json\.'
+//	flarego render after.fgo --base before.fgo -o diff.svg   # red grew, blue shrank
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+func newRenderCmd() *cobra.Command {
+    var (
+        opt    flamegraph.SVGOptions
+        output string
+        base   string
+    )
+    cmd := &cobra.Command{
+        Use:   "render <file>",
+        Short: "Render a flamegraph file as an SVG image",
+        Args:  cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            root, err := loadFlameFile(args[0])
+            if err != nil {
+                return err
+            }
+            if base != "" {
+                if opt.Base, err = loadFlameFile(base); err != nil {
+                    return err
+                }
+            }
+            var w io.Writer = os.Stdout
+            if output != "" && output != "-" {
+                f, err := os.Create(output)
+                if err != nil {
+                    return err
+                }
+                defer f.Close()
+                w = f
+            }
+            return flamegraph.RenderSVG(w, root, opt)
+        },
+    }
+    cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default: stdout)")
+    cmd.Flags().IntVar(&opt.Width, "width", 1200, "Image width in pixels")
+    cmd.Flags().Float64Var(&opt.MinWidth, "min-width", 0.1, "Omit frames narrower than this many pixels")
+    cmd.Flags().StringVar(&opt.Title, "title", "", "Image title")
+    cmd.Flags().BoolVar(&opt.Icicle, "icicle", false, "Draw the root at the top (icicle graph)")
+    cmd.Flags().StringVar(&opt.Search, "search", "", "Highlight frames matching this regex")
+    cmd.Flags().StringVar(&base, "base", "", "Colour by change against this earlier recording (red grew, blue shrank)")
+    return cmd
+}
@@ -1,22 +1,27 @@
 // cmd/flarego/replay.go
 // Implements the `flarego replay` command.  It loads a previously recorded
-// `.fgo` file (produced by `flarego record`), decodes the embedded flamegraph
-// JSON and provides two output modes:
+// `.fgo` file (produced by `flarego record`) and provides two output modes:
 //  1. Human‑readable summary on stdout (default)
 //  2. Full pretty‑printed JSON via `--json`
 //
-// Future versions will embed a mini HTTP server that renders the same
-// WebComponent used by the dashboard, but for v0.1 the focus is on quick CLI
-// inspection and piping into other tools.
+// v2 containers hold a timeline of snapshots: --timeline lists them, --from
+// and --to merge a window of it and --at selects the single snapshot in
+// effect at a point in time.  Times are RFC 3339, an offset from the start of
+// the recording ("90s") or, when negative, from its end ("-30s").  Legacy v1
+// files (one gzipped tree) and the other formats `flarego convert` knows are
+// read as a single tree.  --transform rewrites the selected tree (or every
+// streamed snapshot).  With --to-gateway the recording is streamed into a
+// gateway instead (see replay_gateway.go).
 package main
 
 import (
-	"compress/gzip"
-	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -25,90 +30,271 @@ import (
 )
 
 func newReplayCmd() *cobra.Command {
-    var outputJSON bool
+    var (
+        outputJSON bool
+        timeline   bool
+        from, to   string
+        at         string
+        gw         gatewayFlags
+        streamer   replayStreamer
+        trans      transformFlags
+    )
 
     cmd := &cobra.Command{
-        Use:   "replay <file.fgo>",
-        Short: "Inspect a recorded .fgo flamegraph file",
+        Use:   "replay <file.fgo|dir>",
+        Short: "Inspect a recorded .fgo flamegraph file or stream it into a gateway",
         Args:  cobra.ExactArgs(1),
         RunE: func(cmd *cobra.Command, args []string) error {
             path := args[0]
+            if at != "" && (from != "" || to != "") {
+                return fmt.Errorf("--at cannot be combined with --from/--to")
+            }
+            tr, err := trans.parse()
+            if err != nil {
+                return err
+            }
+            if gw.Addr != "" {
+                if at != "" || timeline || outputJSON {
+                    return fmt.Errorf("--to-gateway cannot be combined with --at, --timeline or --json")
+                }
+                if streamer.speed < 0 {
+                    return fmt.Errorf("--speed must be >= 0")
+                }
+                streamer.gw = gw
+                if streamer.label == "" {
+                    streamer.label = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+                }
+                snaps, err := replaySource(path, from, to)
+                if err != nil {
+                    return err
+                }
+                for i := range snaps {
+                    load := snaps[i].load
+                    snaps[i].load = func() (*flamegraph.Frame, error) {
+                        root, err := load()
+                        if err != nil {
+                            return nil, err
+                        }
+                        return tr(root), nil
+                    }
+                }
+                cmd.SilenceUsage = true
+                return streamer.run(cmd.Context(), snaps)
+            }
             f, err := os.Open(path)
             if err != nil {
                 return err
             }
             defer f.Close()
 
-            var r io.Reader = f
-            if isGzip(path) {
-                gr, err := gzip.NewReader(f)
+            var magic [8]byte
+            n, _ := io.ReadFull(f, magic[:])
+            if !flamegraph.IsContainer(magic[:n]) {
+                if timeline || from != "" || to != "" || at != "" {
+                    return fmt.Errorf("%s has no timeline (not a .fgo v2 recording)", path)
+                }
+                root, err := loadFlameFile(path)
                 if err != nil {
                     return err
                 }
-                defer gr.Close()
-                r = gr
+                root = tr(root)
+                if outputJSON {
+                    return printFrameJSON(root)
+                }
+                fmt.Printf("File: %s\n", path)
+                printReplaySummary(root)
+                return nil
             }
 
-            var root flamegraph.Frame
-            dec := json.NewDecoder(r)
-            if err := dec.Decode(&root); err != nil {
-                return fmt.Errorf("decode flamegraph: %w", err)
+            st, err := f.Stat()
+            if err != nil {
+                return err
             }
-
-            if outputJSON {
-                enc := json.NewEncoder(os.Stdout)
-                enc.SetIndent("", "  ")
-                return enc.Encode(root)
+            c, err := flamegraph.OpenContainer(f, st.Size())
+            if err != nil {
+                return fmt.Errorf("%s: %w", path, err)
             }
+            h := c.Header
 
-            // Human summary – compute quick stats.
-            rows := root.Flatten()
-            var totalSelf, totalCum int64
-            for _, row := range rows {
-                totalSelf += row.Self
-                if row.Depth == 0 {
-                    totalCum = row.Cumulative
+            var (
+                root   *flamegraph.Frame
+                window string
+            )
+            switch {
+            case at != "":
+                t, err := parseReplayTime(at, h.Start, h.End)
+                if err != nil {
+                    return fmt.Errorf("--at: %w", err)
+                }
+                if c.Len() == 0 {
+                    return fmt.Errorf("%s contains no snapshots", path)
+                }
+                i := c.At(t)
+                s, err := c.Snapshot(i)
+                if err != nil {
+                    return err
+                }
+                root = s.Root
+                window = fmt.Sprintf("snapshot %d at %s", i, s.Time.Format(time.RFC3339Nano))
+            default:
+                var fromT, toT time.Time
+                if from != "" {
+                    if fromT, err = parseReplayTime(from, h.Start, h.End); err != nil {
+                        return fmt.Errorf("--from: %w", err)
+                    }
+                }
+                if to != "" {
+                    if toT, err = parseReplayTime(to, h.Start, h.End); err != nil {
+                        return fmt.Errorf("--to: %w", err)
+                    }
                 }
+                var merged int
+                if root, merged, err = c.Window(fromT, toT); err != nil {
+                    return err
+                }
+                window = fmt.Sprintf("%d of %d snapshots", merged, c.Len())
             }
+            root = tr(root)
 
-            fmt.Printf("File: %s\n", path)
-            fmt.Printf("Nodes: %d\n", len(rows))
-            fmt.Printf("Cumulative time: %s\n", time.Duration(totalCum))
-            fmt.Printf("Self time total: %s\n", time.Duration(totalSelf))
-            fmt.Println("Top 10 hottest stacks:")
-            for i, row := range rows[:min(10, len(rows))] {
-                fmt.Printf("%2d. %-50s %12s\n", i+1, row.Name, time.Duration(row.Cumulative))
+            if outputJSON {
+                return printFrameJSON(root)
+            }
+
+            fmt.Printf("File: %s (fgo v%d)\n", path, h.Version)
+            if h.Host != "" || h.PID != 0 {
+                fmt.Printf("Process: %s pid %d\n", h.Host, h.PID)
+            }
+            if h.GoVersion != "" {
+                fmt.Printf("Go: %s\n", h.GoVersion)
+            }
+            if h.Build != "" {
+                fmt.Printf("Build: %s\n", h.Build)
             }
+            if len(h.Samplers) > 0 {
+                fmt.Printf("Samplers: %s @ %d Hz\n", strings.Join(h.Samplers, ", "), h.Hz)
+            }
+            for _, k := range slices.Sorted(maps.Keys(h.Labels)) {
+                fmt.Printf("Label %s: %s\n", k, h.Labels[k])
+            }
+            fmt.Printf("Recorded: %s – %s (%s)\n", h.Start.Format(time.RFC3339), h.End.Format(time.RFC3339), h.End.Sub(h.Start).Round(time.Millisecond))
+            fmt.Printf("Window: %s\n", window)
+            if timeline {
+                fmt.Println("Timeline:")
+                for i, e := range c.Index {
+                    s, err := c.Snapshot(i)
+                    if err != nil {
+                        return err
+                    }
+                    fmt.Printf("%4d. %10s  %12d", i, e.Time.Sub(h.Start).Round(time.Millisecond), flamegraph.Total(flamegraph.WithoutBands(s.Root)))
+                    for _, k := range slices.Sorted(maps.Keys(s.Labels)) {
+                        fmt.Printf("  %s=%s", k, s.Labels[k])
+                    }
+                    fmt.Println()
+                }
+            }
+            printReplaySummary(root)
             return nil
         },
     }
 
     cmd.Flags().BoolVar(&outputJSON, "json", false, "Output full flamegraph JSON instead of summary")
+    cmd.Flags().BoolVar(&timeline, "timeline", false, "List every snapshot of a v2 recording")
+    cmd.Flags().StringVar(&from, "from", "", "Merge snapshots taken at or after this time")
+    cmd.Flags().StringVar(&to, "to", "", "Merge snapshots taken at or before this time")
+    cmd.Flags().StringVar(&at, "at", "", "Select the snapshot in effect at this time")
+    trans.register(cmd.Flags())
+    cmd.Flags().StringVar(&gw.Addr, "to-gateway", "", "Stream the recording into this gateway (host:port) instead of printing it")
+    gw.registerConn(cmd.Flags())
+    cmd.Flags().Float64Var(&streamer.speed, "speed", 1, "Replay speed factor for --to-gateway (0 = no pauses)")
+    cmd.Flags().BoolVar(&streamer.loop, "loop", false, "Repeat the replay until interrupted")
+    cmd.Flags().StringVar(&streamer.label, "label", "", "Value of the replay label on the stream (default: file name)")
     return cmd
 }
 
-// isGzip infers gzip compression from file extension or magic bytes.
-func isGzip(path string) bool {
-    if filepath.Ext(path) == ".fgo" {
-        // record command always gzips unless --no-compress; rely on extension.
-        return true
+// replaySource lists the snapshots to stream from path: a directory of file
+// exporter outputs, the window of a v2 recording, or a single profile.
+func replaySource(path, from, to string) ([]replaySnapshot, error) {
+    st, err := os.Stat(path)
+    if err != nil {
+        return nil, err
+    }
+    if st.IsDir() {
+        if from != "" || to != "" {
+            return nil, fmt.Errorf("--from/--to need a .fgo v2 recording, not a directory")
+        }
+        return dirSnapshots(path)
     }
-    // Fallback: peek first two bytes.
     f, err := os.Open(path)
     if err != nil {
-        return false
+        return nil, err
     }
-    defer f.Close()
-    var magic [2]byte
-    if _, err := io.ReadFull(f, magic[:]); err != nil {
-        return false
+    var magic [8]byte
+    n, _ := io.ReadFull(f, magic[:])
+    if !flamegraph.IsContainer(magic[:n]) {
+        f.Close()
+        if from != "" || to != "" {
+            return nil, fmt.Errorf("%s has no timeline (not a .fgo v2 recording)", path)
+        }
+        return []replaySnapshot{{at: st.ModTime(), load: func() (*flamegraph.Frame, error) { return loadFlameFile(path) }}}, nil
     }
-    return magic[0] == 0x1f && magic[1] == 0x8b
+    // The file stays open for the lifetime of the command.
+    c, err := flamegraph.OpenContainer(f, st.Size())
+    if err != nil {
+        f.Close()
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    var fromT, toT time.Time
+    if from != "" {
+        if fromT, err = parseReplayTime(from, c.Header.Start, c.Header.End); err != nil {
+            return nil, fmt.Errorf("--from: %w", err)
+        }
+    }
+    if to != "" {
+        if toT, err = parseReplayTime(to, c.Header.Start, c.Header.End); err != nil {
+            return nil, fmt.Errorf("--to: %w", err)
+        }
+    }
+    return containerSnapshots(c, fromT, toT), nil
 }
 
-func min(a, b int) int {
-    if a < b {
-        return a
+// parseReplayTime accepts RFC 3339 or a duration relative to the start of
+// the recording, or to its end when negative.
+func parseReplayTime(s string, start, end time.Time) (time.Time, error) {
+    if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+        return t, nil
+    }
+    d, err := time.ParseDuration(strings.TrimPrefix(s, "+"))
+    if err != nil {
+        return time.Time{}, fmt.Errorf("%q is neither RFC 3339 nor a duration", s)
+    }
+    if strings.HasPrefix(s, "-") {
+        return end.Add(d), nil
+    }
+    return start.Add(d), nil
+}
+
+// printFrameJSON writes root as an ordered v2 document with self values.
+func printFrameJSON(root *flamegraph.Frame) error {
+    return flamegraph.EncodeJSON(os.Stdout, root, flamegraph.JSONOptions{Self: true, Indent: "  "})
+}
+
+// printReplaySummary prints quick stats about root: the stack total, the
+// runtime bands in their own units and the heaviest functions (see `flarego
+// analyze` for more).
+func printReplaySummary(root *flamegraph.Frame) {
+    stacks := flamegraph.WithoutBands(root)
+    total := flamegraph.Total(stacks)
+    fmt.Printf("Nodes: %d\n", len(root.Flatten()))
+    fmt.Printf("Total: %d samples\n", total)
+    for _, b := range flamegraph.RuntimeBands {
+        if c, ok := root.Children[b]; ok {
+            unit, _ := flamegraph.BandUnit(b)
+            fmt.Printf("%s: %s\n", b, flamegraph.FormatValue(c.Value, unit))
+        }
+    }
+    fmt.Println("Top 10 functions by cumulative samples:")
+    hs := flamegraph.Hotspots(stacks)
+    for i, h := range hs[:min(10, len(hs))] {
+        fmt.Printf("%2d. %-50s %10d %6.2f%%  (self %d)\n", i+1, h.Name, h.Cum, pct(h.Cum, total), h.Self)
     }
-    return b
 }
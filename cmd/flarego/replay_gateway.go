@@ -0,0 +1,192 @@
+// cmd/flarego/replay_gateway.go
+// `flarego replay --to-gateway`: streams a recording into a live gateway
+// through GatewayService.Stream, as an agent would.  The source is a .fgo v2
+// recording (optionally windowed with --from/--to), a directory of file
+// exporter outputs (<prefix>-20060102T150405.000.json[.gz], ordered by the
+// timestamp in the name, else by modification time) or any single profile.
+//
+// Snapshots are sent with their original spacing divided by --speed (0 sends
+// them back to back); --loop starts over until interrupted.  The stream
+// carries a replay=<label> label (gateway.LabelsMetadata) so it can be told
+// apart from live agents in /admin/agents.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/Voskan/flarego/internal/gateway"
+	"github.com/Voskan/flarego/internal/logging"
+	agentpb "github.com/Voskan/flarego/internal/proto"
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+// exporterTimeLayout is the timestamp in file exporter file names.
+const exporterTimeLayout = "20060102T150405.000"
+
+// replaySnapshot is one tree to send, loaded when its turn comes.
+type replaySnapshot struct {
+    at   time.Time
+    load func() (*flamegraph.Frame, error)
+}
+
+// replayStreamer sends snapshots to a gateway.
+type replayStreamer struct {
+    gw    gatewayFlags
+    label string
+    speed float64
+    loop  bool
+}
+
+// containerSnapshots lists the snapshots of c taken within [from, to].
+func containerSnapshots(c *flamegraph.Container, from, to time.Time) []replaySnapshot {
+    var out []replaySnapshot
+    for i, e := range c.Index {
+        if (!from.IsZero() && e.Time.Before(from)) || (!to.IsZero() && e.Time.After(to)) {
+            continue
+        }
+        out = append(out, replaySnapshot{at: e.Time, load: func() (*flamegraph.Frame, error) {
+            s, err := c.Snapshot(i)
+            if err != nil {
+                return nil, err
+            }
+            return s.Root, nil
+        }})
+    }
+    return out
+}
+
+// dirSnapshots lists the profiles in dir, oldest first.
+func dirSnapshots(dir string) ([]replaySnapshot, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, err
+    }
+    var out []replaySnapshot
+    for _, e := range entries {
+        name := e.Name()
+        if e.IsDir() {
+            continue
+        }
+        if _, ok := flamegraph.FormatForPath(strings.TrimSuffix(name, ".gz")); !ok {
+            continue
+        }
+        path := filepath.Join(dir, name)
+        at, ok := exporterTime(name)
+        if !ok {
+            info, err := e.Info()
+            if err != nil {
+                return nil, err
+            }
+            at = info.ModTime()
+        }
+        out = append(out, replaySnapshot{at: at, load: func() (*flamegraph.Frame, error) { return loadFlameFile(path) }})
+    }
+    if len(out) == 0 {
+        return nil, fmt.Errorf("%s contains no profiles", dir)
+    }
+    sort.SliceStable(out, func(i, j int) bool { return out[i].at.Before(out[j].at) })
+    return out, nil
+}
+
+// exporterTime parses the timestamp of a file exporter output name.
+func exporterTime(name string) (time.Time, bool) {
+    base := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".json")
+    i := strings.LastIndex(base, "-")
+    if i < 0 {
+        return time.Time{}, false
+    }
+    t, err := time.ParseInLocation(exporterTimeLayout, base[i+1:], time.UTC)
+    return t, err == nil
+}
+
+// run streams snaps until done (or interrupted when looping).
+func (r *replayStreamer) run(ctx context.Context, snaps []replaySnapshot) error {
+    if len(snaps) == 0 {
+        return fmt.Errorf("nothing to replay")
+    }
+    conn, err := r.gw.dial()
+    if err != nil {
+        return err
+    }
+    defer conn.Close()
+
+    sctx := metadata.AppendToOutgoingContext(r.gw.outgoing(ctx), gateway.LabelsMetadata, "replay="+r.label)
+    stream, err := agentpb.NewGatewayServiceClient(conn).Stream(sctx)
+    if err != nil {
+        return fmt.Errorf("open stream: %w", err)
+    }
+
+    // Between passes wait as long as between the first two snapshots.
+    loopGap := time.Second
+    if len(snaps) > 1 {
+        loopGap = snaps[1].at.Sub(snaps[0].at)
+    }
+    logging.Sugar().Infow("replay started", "gateway", r.gw.Addr, "label", r.label, "snapshots", len(snaps), "speed", r.speed, "loop", r.loop)
+
+    for pass := 1; ; pass++ {
+        for i, s := range snaps {
+            gap := loopGap
+            if i > 0 {
+                gap = s.at.Sub(snaps[i-1].at)
+            } else if pass == 1 {
+                gap = 0
+            }
+            if !r.wait(ctx, gap) {
+                return r.finish(stream)
+            }
+            root, err := s.load()
+            if err != nil {
+                return fmt.Errorf("snapshot %d: %w", i, err)
+            }
+            data, err := root.ToJSON()
+            if err != nil {
+                return err
+            }
+            if err := stream.Send(&agentpb.FlamegraphChunk{Payload: data}); err != nil {
+                // The gateway's reason only surfaces via CloseAndRecv.
+                if errors.Is(err, io.EOF) {
+                    _, err = stream.CloseAndRecv()
+                }
+                return fmt.Errorf("send snapshot %d: %w", i, err)
+            }
+        }
+        logging.Sugar().Infow("replay pass complete", "pass", pass, "snapshots", len(snaps))
+        if !r.loop {
+            return r.finish(stream)
+        }
+    }
+}
+
+// wait sleeps for the recorded gap scaled by the speed; false when ctx ended.
+func (r *replayStreamer) wait(ctx context.Context, gap time.Duration) bool {
+    if r.speed > 0 && gap > 0 {
+        t := time.NewTimer(time.Duration(float64(gap) / r.speed))
+        defer t.Stop()
+        select {
+        case <-t.C:
+        case <-ctx.Done():
+        }
+    }
+    return ctx.Err() == nil
+}
+
+// finish closes the stream.  The gateway ends streams without a response
+// (io.EOF), and cancellation by the user is not an error either.
+func (r *replayStreamer) finish(stream agentpb.GatewayService_StreamClient) error {
+    if _, err := stream.CloseAndRecv(); err != nil && !errors.Is(err, io.EOF) && status.Code(err) != codes.Canceled {
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/Voskan/flarego/internal/gateway"
+	agentpb "github.com/Voskan/flarego/internal/proto"
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+func TestExporterTime(t *testing.T) {
+	want := time.Date(2026, 1, 2, 15, 4, 5, 123e6, time.UTC)
+	cases := []struct {
+		name string
+		ok   bool
+	}{
+		{"flarego-20260102T150405.123.json", true},
+		{"flarego-20260102T150405.123.json.gz", true},
+		{"my-agent-20260102T150405.123.json", true},
+		{"profile.json", false},
+		{"flarego-yesterday.json", false},
+	}
+	for _, c := range cases {
+		got, ok := exporterTime(c.name)
+		if ok != c.ok || (ok && !got.Equal(want)) {
+			t.Errorf("exporterTime(%q) = %v, %v; expected %v", c.name, got, ok, c.ok)
+		}
+	}
+}
+
+func TestDirSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	frame := func(name string) []byte {
+		root := flamegraph.New("root")
+		root.AddSample([]string{name}, 1)
+		data, _ := json.Marshal(root)
+		return data
+	}
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(frame("second"))
+	zw.Close()
+	write("agent-20260102T150406.000.json.gz", gz.Bytes())
+	write("agent-20260102T150407.000.json", frame("third"))
+	// No timestamp in the name: ordered by modification time.
+	old := write("manual.json", frame("first"))
+	at := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(old, at, at); err != nil {
+		t.Fatal(err)
+	}
+	write("notes.md", []byte("not a profile"))
+	if err := os.Mkdir(filepath.Join(dir, "sub.json"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	snaps, err := dirSnapshots(dir)
+	if err != nil {
+		t.Fatalf("dirSnapshots: %v", err)
+	}
+	if len(snaps) != 3 {
+		t.Fatalf("Expected 3 profiles, got %d", len(snaps))
+	}
+	for i, want := range []string{"first", "second", "third"} {
+		root, err := snaps[i].load()
+		if err != nil {
+			t.Fatalf("load %d: %v", i, err)
+		}
+		if root.Children[want] == nil {
+			t.Errorf("Expected snapshot %d to be %s, got %+v", i, want, root)
+		}
+	}
+	if _, err := dirSnapshots(t.TempDir()); err == nil {
+		t.Errorf("Expected an error for a directory without profiles")
+	}
+}
+
+func TestContainerSnapshots(t *testing.T) {
+	t0 := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	cw, err := flamegraph.NewContainerWriter(&buf, flamegraph.Header{Start: t0}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		root := flamegraph.New("root")
+		root.AddSample([]string{"main"}, int64(i+1))
+		if err := cw.WriteSnapshot(flamegraph.Snapshot{Time: t0.Add(time.Duration(i) * time.Second), Root: root}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cw.Close(t0.Add(4 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	c, err := flamegraph.OpenContainer(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		from, to time.Time
+		want     []int64
+	}{
+		{time.Time{}, time.Time{}, []int64{1, 2, 3, 4}},
+		{t0.Add(time.Second), time.Time{}, []int64{2, 3, 4}},
+		{time.Time{}, t0.Add(time.Second), []int64{1, 2}},
+		{t0.Add(1500 * time.Millisecond), t0.Add(2 * time.Second), []int64{3}},
+		{t0.Add(time.Hour), time.Time{}, nil},
+	}
+	for _, cs := range cases {
+		snaps := containerSnapshots(c, cs.from, cs.to)
+		var got []int64
+		for _, s := range snaps {
+			root, err := s.load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, root.Children["main"].Value)
+		}
+		if len(got) != len(cs.want) {
+			t.Errorf("[%v, %v]: expected %v, got %v", cs.from, cs.to, cs.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != cs.want[i] {
+				t.Errorf("[%v, %v]: expected %v, got %v", cs.from, cs.to, cs.want, got)
+				break
+			}
+		}
+	}
+}
+
+// subscribeEnveloped collects the enveloped chunks of addr until ctx ends.
+func subscribeEnveloped(ctx context.Context, t *testing.T, addr string) <-chan gateway.ChunkEnvelope {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sctx := metadata.AppendToOutgoingContext(ctx, gateway.EnvelopeMetadata, "1")
+	stream, err := agentpb.NewUIServiceClient(conn).StreamFlamegraphs(sctx, &emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan gateway.ChunkEnvelope, 64)
+	go func() {
+		defer conn.Close()
+		defer close(out)
+		for {
+			chunk, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			var env gateway.ChunkEnvelope
+			if json.Unmarshal(chunk.Payload, &env) == nil {
+				out <- env
+			}
+		}
+	}()
+	time.Sleep(100 * time.Millisecond) // let the subscription start
+	return out
+}
+
+func staticSnapshots(gaps ...time.Duration) []replaySnapshot {
+	at := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	var out []replaySnapshot
+	for i, g := range append([]time.Duration{0}, gaps...) {
+		at = at.Add(g)
+		root := flamegraph.New("root")
+		root.AddSample([]string{"main"}, int64(i+1))
+		out = append(out, replaySnapshot{at: at, load: func() (*flamegraph.Frame, error) { return root, nil }})
+	}
+	return out
+}
+
+func TestReplayStreamerRun(t *testing.T) {
+	addr := startGateway(t)
+	gw := gatewayFlags{Addr: addr, Plaintext: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chunks := subscribeEnveloped(ctx, t, addr)
+
+	// Speed 0 sends back to back, however far apart the snapshots were.
+	r := &replayStreamer{gw: gw, label: "INC-1", speed: 0}
+	start := time.Now()
+	if err := r.run(ctx, staticSnapshots(time.Hour, time.Hour)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Expected speed 0 not to wait, took %v", d)
+	}
+	for i := 1; i <= 3; i++ {
+		select {
+		case env := <-chunks:
+			if env.Labels["replay"] != "INC-1" {
+				t.Errorf("Expected replay=INC-1 label, got %v", env.Labels)
+			}
+			var root flamegraph.Frame
+			if err := json.Unmarshal(env.Root, &root); err != nil || root.Children["main"].Value != int64(i) {
+				t.Errorf("Expected snapshot %d in order, got %s", i, env.Root)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected 3 chunks, got %d", i-1)
+		}
+	}
+
+	// Looping at speed 2 with 200ms between snapshots sends one every 100ms,
+	// the first pass right away and the next after the same gap.
+	lctx, lcancel := context.WithCancel(ctx)
+	defer time.AfterFunc(350*time.Millisecond, lcancel).Stop()
+	r = &replayStreamer{gw: gw, label: "loop", speed: 2, loop: true}
+	if err := r.run(lctx, staticSnapshots(200*time.Millisecond)); err != nil {
+		t.Fatalf("looping run: %v", err)
+	}
+	n := 0
+	for n < 5 {
+		select {
+		case <-chunks:
+			n++
+			continue
+		case <-time.After(200 * time.Millisecond):
+		}
+		break
+	}
+	if n < 3 || n > 4 {
+		t.Errorf("Expected 3-4 chunks in 350ms at 100ms spacing, got %d", n)
+	}
+	if err := r.run(ctx, nil); err == nil {
+		t.Errorf("Expected an error for nothing to replay")
+	}
+}
@@ -0,0 +1,69 @@
+// cmd/flarego/report.go
+// Implements `flarego report`, which writes a self‑contained interactive HTML
+// flamegraph (pkg/report) for postmortems and tickets:
+//
+//	flarego report flare.fgo -o report.html
+//	flarego report after.fgo --diff before.fgo -o regression.html
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Voskan/flarego/pkg/report"
+)
+
+func newReportCmd() *cobra.Command {
+    var (
+        output   string
+        diff     string
+        title    string
+        minShare float64
+    )
+    cmd := &cobra.Command{
+        Use:   "report <file>",
+        Short: "Write a self-contained interactive HTML flamegraph report",
+        Args:  cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            head, err := loadFlameFile(args[0])
+            if err != nil {
+                return err
+            }
+            opt := report.Options{
+                Title:    title,
+                HeadName: filepath.Base(args[0]),
+                MinShare: minShare / 100,
+            }
+            if diff != "" {
+                if opt.Base, err = loadFlameFile(diff); err != nil {
+                    return err
+                }
+                opt.BaseName = filepath.Base(diff)
+            }
+            if output == "" {
+                output = args[0] + ".html"
+            }
+            f, err := os.Create(output)
+            if err != nil {
+                return err
+            }
+            if err := report.Write(f, head, opt); err != nil {
+                f.Close()
+                return err
+            }
+            if err := f.Close(); err != nil {
+                return err
+            }
+            fmt.Fprintf(cmd.OutOrStdout(), "Report written to %s\n", output)
+            return nil
+        },
+    }
+    cmd.Flags().StringVarP(&output, "output", "o", "", "Output HTML file (default: <file>.html)")
+    cmd.Flags().StringVar(&diff, "diff", "", "Earlier recording to compare against (diff colouring)")
+    cmd.Flags().StringVar(&title, "title", "", "Report title")
+    cmd.Flags().Float64Var(&minShare, "min-share", 0, "Drop frames below this percentage of the total to keep the file small")
+    return cmd
+}
@@ -5,16 +5,13 @@
 // Root command for the `flarego` CLI. It wires common flags, global
 // initialisation (logger, config file, colour output) and adds top‑level
 // sub‑commands located in sibling files (attach.go, record.go, replay.go,
-// version.go).
+// diff.go, version.go).
 
 // Build‑tag `cli` allows excluding the CLI from tiny agent-only builds.
 package main
 
 import (
-	"compress/gzip"
-	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -59,8 +56,14 @@ func init() {
     rootCmd.AddCommand(newReplayCmd())
     rootCmd.AddCommand(newVersionCmd())
     rootCmd.AddCommand(newDiffCmd())
+    rootCmd.AddCommand(newConvertCmd())
+    rootCmd.AddCommand(newRenderCmd())
+    rootCmd.AddCommand(newReportCmd())
     rootCmd.AddCommand(newEBPFAttachCmd())
     rootCmd.AddCommand(newKubectlCmd())
+    rootCmd.AddCommand(newTokenCmd())
+    rootCmd.AddCommand(newTopCmd())
+    rootCmd.AddCommand(newAnalyzeCmd())
 }
 
 // Execute is called by main.main().
@@ -126,48 +129,3 @@ func mustDecodeFlame(path string) *flamegraph.Frame {
     }
     return &root
 }
-
-// flarego diff <before.fgo> <after.fgo>
-func newDiffCmd() *cobra.Command {
-    cmd := &cobra.Command{
-        Use:   "diff <before.fgo> <after.fgo>",
-        Short: "Show diff between two .fgo flamegraph files",
-        Args:  cobra.ExactArgs(2),
-        RunE: func(cmd *cobra.Command, args []string) error {
-            load := func(path string) (*flamegraph.Frame, error) {
-                f, err := os.Open(path)
-                if err != nil {
-                    return nil, err
-                }
-                defer f.Close()
-                var r io.Reader = f
-                if filepath.Ext(path) == ".fgo" {
-                    gr, err := gzip.NewReader(f)
-                    if err != nil {
-                        return nil, err
-                    }
-                    defer gr.Close()
-                    r = gr
-                }
-                var root flamegraph.Frame
-                if err := json.NewDecoder(r).Decode(&root); err != nil {
-                    return nil, err
-                }
-                return &root, nil
-            }
-            before, err := load(args[0])
-            if err != nil {
-                return err
-            }
-            after, err := load(args[1])
-            if err != nil {
-                return err
-            }
-            diff := flamegraph.Diff(after, before)
-            out, _ := json.MarshalIndent(diff, "", "  ")
-            fmt.Println(string(out))
-            return nil
-        },
-    }
-    return cmd
-}
@@ -0,0 +1,283 @@
+// cmd/flarego/token.go
+// Implements `flarego token`, a small front‑end over pkg/auth for gateway
+// credentials:
+//
+//	flarego token create --sub agent-7 --scope ingest --tenant team-a --ttl 24h --key signer.pem
+//	flarego token inspect <jwt> [--keys jwks.json | --secret …]
+//	flarego token revoke  <jwt> --denylist /etc/flarego/denylist.jsonl
+//
+// Signing uses either an RSA/ECDSA/Ed25519 private key (--key, algorithm
+// follows the key type) or an HS256 secret (--secret, or FLAREGO_JWT_SECRET so
+// the secret stays out of shell history).
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/Voskan/flarego/internal/gateway"
+	"github.com/Voskan/flarego/pkg/auth"
+)
+
+func newTokenCmd() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "token",
+        Short: "Create, inspect and revoke gateway tokens",
+    }
+    cmd.AddCommand(newTokenCreateCmd(), newTokenInspectCmd(), newTokenRevokeCmd())
+    return cmd
+}
+
+// tokenSecret returns --secret or FLAREGO_JWT_SECRET.
+func tokenSecret(flagVal string) []byte {
+    if flagVal == "" {
+        flagVal = viper.GetString("jwt_secret")
+    }
+    if flagVal == "" {
+        return nil
+    }
+    return []byte(flagVal)
+}
+
+func newTokenCreateCmd() *cobra.Command {
+    var (
+        subject  string
+        scopes   []string
+        tenant   string
+        ttl      time.Duration
+        keyPath  string
+        kid      string
+        secret   string
+        issuer   string
+        audience string
+    )
+    cmd := &cobra.Command{
+        Use:   "create",
+        Short: "Sign a new token",
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if subject == "" {
+                return errors.New("--sub is required")
+            }
+            parsed, err := gateway.ParseScopes(strings.Join(scopes, " "))
+            if err != nil {
+                return err
+            }
+            if len(parsed) == 0 {
+                return errors.New("at least one --scope is required (ingest, read, admin)")
+            }
+            if tenant != "" && !gateway.ValidTenant(tenant) {
+                return fmt.Errorf("invalid tenant %q", tenant)
+            }
+
+            var signer *auth.Signer
+            switch {
+            case keyPath != "":
+                key, err := auth.LoadPrivateKeyPEM(keyPath)
+                if err != nil {
+                    return err
+                }
+                if signer, err = auth.NewKeySigner(key, kid, issuer, ttl); err != nil {
+                    return err
+                }
+            case tokenSecret(secret) != nil:
+                signer = auth.NewSigner(tokenSecret(secret), issuer, ttl)
+            default:
+                return errors.New("--key or --secret (FLAREGO_JWT_SECRET) is required")
+            }
+
+            names := make([]string, len(parsed))
+            for i, sc := range parsed {
+                names[i] = string(sc)
+            }
+            extra := map[string]any{"scope": strings.Join(names, " ")}
+            if tenant != "" {
+                extra["tenant"] = tenant
+            }
+            if audience != "" {
+                extra["aud"] = audience
+            }
+            tok, err := signer.Sign(signer.Claims(subject, extra))
+            if err != nil {
+                return err
+            }
+            fmt.Println(tok)
+            return nil
+        },
+    }
+    cmd.Flags().StringVar(&subject, "sub", "", "Subject (agent or user identity)")
+    cmd.Flags().StringSliceVar(&scopes, "scope", nil, "Scopes to grant: ingest, read, admin (repeatable or comma separated)")
+    cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant the token belongs to (default tenant when empty)")
+    cmd.Flags().DurationVar(&ttl, "ttl", 24*time.Hour, "Token lifetime")
+    cmd.Flags().StringVar(&keyPath, "key", "", "PEM private key (RSA, ECDSA or Ed25519)")
+    cmd.Flags().StringVar(&kid, "kid", "", "Key id written to the token header")
+    cmd.Flags().StringVar(&secret, "secret", "", "HS256 secret (prefer FLAREGO_JWT_SECRET)")
+    cmd.Flags().StringVar(&issuer, "issuer", "flarego", "iss claim")
+    cmd.Flags().StringVar(&audience, "audience", "", "aud claim (optional)")
+    return cmd
+}
+
+func newTokenInspectCmd() *cobra.Command {
+    var (
+        keysPath string
+        secret   string
+        issuer   string
+        audience string
+        denylist string
+        asJSON   bool
+    )
+    cmd := &cobra.Command{
+        Use:   "inspect <jwt>",
+        Short: "Decode a token, print its claims and verify it when keys are given",
+        Args:  cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            tok, claims, err := parseUnverified(args[0])
+            if err != nil {
+                return err
+            }
+
+            verdict := "not verified (pass --keys or --secret)"
+            var verifyErr error
+            if keysPath != "" || tokenSecret(secret) != nil {
+                vc := auth.VerifierConfig{Secret: tokenSecret(secret), Issuer: issuer, Audience: audience}
+                if keysPath != "" {
+                    ks, err := auth.LoadKeySet(keysPath, 0)
+                    if err != nil {
+                        return err
+                    }
+                    vc.Keys = ks
+                }
+                if denylist != "" {
+                    d, err := auth.LoadDenylist(denylist)
+                    if err != nil {
+                        return err
+                    }
+                    vc.Denylist = d
+                }
+                v, err := auth.NewVerifierWithConfig(vc)
+                if err != nil {
+                    return err
+                }
+                if _, verifyErr = v.ParseAndVerify(args[0]); verifyErr == nil {
+                    verdict = "valid"
+                } else {
+                    verdict = "INVALID: " + verifyErr.Error()
+                }
+            }
+
+            if asJSON {
+                enc := json.NewEncoder(os.Stdout)
+                enc.SetIndent("", "  ")
+                if err := enc.Encode(map[string]any{"header": tok.Header, "claims": claims, "verification": verdict}); err != nil {
+                    return err
+                }
+            } else {
+                printTokenSummary(tok.Header, claims, verdict)
+            }
+            return verifyErr
+        },
+    }
+    cmd.Flags().StringVar(&keysPath, "keys", "", "PEM or JWKS public keys to verify against")
+    cmd.Flags().StringVar(&secret, "secret", "", "HS256 secret to verify against (prefer FLAREGO_JWT_SECRET)")
+    cmd.Flags().StringVar(&issuer, "issuer", "", "Expected iss claim")
+    cmd.Flags().StringVar(&audience, "audience", "", "Expected aud claim")
+    cmd.Flags().StringVar(&denylist, "denylist", "", "Denylist file to check the jti against")
+    cmd.Flags().BoolVar(&asJSON, "json", false, "Print header, claims and verdict as JSON")
+    return cmd
+}
+
+func newTokenRevokeCmd() *cobra.Command {
+    var (
+        denylist string
+        jti      string
+    )
+    cmd := &cobra.Command{
+        Use:   "revoke [<jwt>]",
+        Short: "Add a token's jti to the gateway denylist",
+        Args:  cobra.MaximumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            if denylist == "" {
+                return errors.New("--denylist is required")
+            }
+            r := auth.Revocation{JTI: jti}
+            if len(args) == 1 {
+                _, claims, err := parseUnverified(args[0])
+                if err != nil {
+                    return err
+                }
+                r.JTI, _ = claims["jti"].(string)
+                r.Subject, _ = claims["sub"].(string)
+                if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+                    r.Expires = exp.Unix()
+                }
+            }
+            if r.JTI == "" {
+                return errors.New("token has no jti; pass a token or --jti")
+            }
+            if err := auth.AppendRevocation(denylist, r); err != nil {
+                return err
+            }
+            fmt.Printf("revoked %s", r.JTI)
+            if r.Subject != "" {
+                fmt.Printf(" (sub %s)", r.Subject)
+            }
+            fmt.Println()
+            return nil
+        },
+    }
+    cmd.Flags().StringVar(&denylist, "denylist", "", "Denylist file read by the gateway (--jwt-denylist)")
+    cmd.Flags().StringVar(&jti, "jti", "", "Token id to revoke when the token itself is unavailable")
+    return cmd
+}
+
+// parseUnverified decodes a token without checking its signature.
+func parseUnverified(raw string) (*jwt.Token, jwt.MapClaims, error) {
+    claims := jwt.MapClaims{}
+    tok, _, err := jwt.NewParser().ParseUnverified(strings.TrimSpace(raw), claims)
+    if err != nil {
+        return nil, nil, fmt.Errorf("decode token: %w", err)
+    }
+    return tok, claims, nil
+}
+
+// printTokenSummary renders header, claims and expiry for humans.
+func printTokenSummary(header map[string]any, claims jwt.MapClaims, verdict string) {
+    fmt.Printf("alg:     %v\n", header["alg"])
+    if kid, ok := header["kid"]; ok {
+        fmt.Printf("kid:     %v\n", kid)
+    }
+    keys := make([]string, 0, len(claims))
+    for k := range claims {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    fmt.Println("claims:")
+    for _, k := range keys {
+        v := claims[k]
+        switch k {
+        case "exp", "iat", "nbf":
+            if f, ok := v.(float64); ok {
+                v = fmt.Sprintf("%.0f (%s)", f, time.Unix(int64(f), 0).UTC().Format(time.RFC3339))
+            }
+        }
+        fmt.Printf("  %-7s %v\n", k+":", v)
+    }
+    if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+        if left := time.Until(exp.Time); left > 0 {
+            fmt.Printf("expires: in %s\n", left.Round(time.Second))
+        } else {
+            fmt.Printf("expires: EXPIRED %s ago\n", (-left).Round(time.Second))
+        }
+    } else {
+        fmt.Println("expires: never")
+    }
+    fmt.Printf("status:  %s\n", verdict)
+}
@@ -0,0 +1,584 @@
+// cmd/flarego/top.go
+// Implements `flarego top`, a live terminal view for hosts where the web UI
+// is out of reach:
+//
+//	flarego top --gateway gw:4317 --auth-token $TOKEN   # subscribe via UIService
+//	flarego top flare.fgo                               # follow a local file
+//
+// Every refresh the view shows the hottest frames of the latest snapshot by
+// self and cumulative weight (pkg/flamegraph.Hotspots), the change of each
+// frame's cumulative weight since the previous snapshot, and the (GC), (Heap)
+// and (Blocked) bands.  Gateway chunks received between two refreshes are
+// merged into one snapshot scaled to a single export of every agent, so the
+// deltas do not depend on how many exports fell into a refresh.  The
+// subscription asks for enveloped chunks, which keeps the retained history
+// the gateway would otherwise replay out of the first snapshot.  A file is
+// re‑read whenever it changes.
+//
+// Keys: ↑/↓ (k/j) select, Enter drill into the selected frame, Backspace/Esc
+// go back up, / filter by regex, s toggle sort, p or space pause, q quit.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/Voskan/flarego/internal/gateway"
+	agentpb "github.com/Voskan/flarego/internal/proto"
+	"github.com/Voskan/flarego/internal/util"
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+func newTopCmd() *cobra.Command {
+    var (
+        gw      gatewayFlags
+        refresh time.Duration
+        filter  string
+        once    bool
+    )
+    cmd := &cobra.Command{
+        Use:   "top [file.fgo]",
+        Short: "Live terminal view of the hottest frames from a gateway or a .fgo file",
+        Args:  cobra.MaximumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt)
+            defer cancel()
+
+            var src topSource
+            if len(args) == 1 {
+                src = &fileSource{path: args[0]}
+            } else {
+                gs, err := newGatewaySource(ctx, &gw)
+                if err != nil {
+                    return err
+                }
+                src = gs
+            }
+
+            v := &topView{source: src.describe(), sortBySelf: false}
+            if filter != "" {
+                re, err := regexp.Compile(filter)
+                if err != nil {
+                    return fmt.Errorf("--filter: %w", err)
+                }
+                v.filter = re
+            }
+
+            if once {
+                // Wait one refresh period so a gateway has time to deliver.
+                if _, ok := src.(*gatewaySource); ok {
+                    select {
+                    case <-time.After(refresh):
+                    case <-ctx.Done():
+                    }
+                }
+                snap, err := src.take()
+                if err != nil {
+                    return err
+                }
+                v.update(snap)
+                v.render(os.Stdout, 120, 40, false)
+                return nil
+            }
+            return runTop(ctx, src, v, refresh)
+        },
+    }
+    gw.register(cmd.Flags())
+    cmd.Flags().DurationVar(&refresh, "refresh", time.Second, "Screen refresh interval")
+    cmd.Flags().StringVar(&filter, "filter", "", "Initial frame filter (regex)")
+    cmd.Flags().BoolVar(&once, "once", false, "Print a single snapshot and exit (no interactive screen)")
+    return cmd
+}
+
+//--------------------------------------------------------------------
+// sources
+//--------------------------------------------------------------------
+
+// topSource yields the next snapshot, or nil when nothing new arrived.
+type topSource interface {
+    take() (*flamegraph.Frame, error)
+    describe() string
+}
+
+// fileSource re‑reads a file whenever its modification time changes.
+type fileSource struct {
+    path  string
+    mtime time.Time
+}
+
+func (s *fileSource) describe() string { return s.path }
+
+func (s *fileSource) take() (*flamegraph.Frame, error) {
+    st, err := os.Stat(s.path)
+    if err != nil {
+        return nil, err
+    }
+    if st.ModTime().Equal(s.mtime) {
+        return nil, nil
+    }
+    root, err := loadFlameFile(s.path)
+    if err != nil {
+        return nil, err
+    }
+    s.mtime = st.ModTime()
+    return root, nil
+}
+
+// gatewaySource merges streamed chunks until take() collects them.  The
+// stream is re‑established with back‑off when it breaks.
+type gatewaySource struct {
+    addr string
+
+    mu      sync.Mutex
+    pending *flamegraph.Frame
+    chunks  map[string]int // chunks merged into pending, per agent
+    err     error
+}
+
+func newGatewaySource(ctx context.Context, gw *gatewayFlags) (*gatewaySource, error) {
+    conn, err := gw.dial()
+    if err != nil {
+        return nil, err
+    }
+    s := &gatewaySource{addr: gw.Addr}
+    client := agentpb.NewUIServiceClient(conn)
+    go func() {
+        defer conn.Close()
+        bo := util.NewBackoff()
+        for ctx.Err() == nil {
+            sctx := metadata.AppendToOutgoingContext(gw.outgoing(ctx), gateway.EnvelopeMetadata, "1")
+            err := s.follow(sctx, client, bo)
+            s.mu.Lock()
+            s.err = err
+            s.mu.Unlock()
+            select {
+            case <-time.After(bo.Next()):
+            case <-ctx.Done():
+            }
+        }
+    }()
+    return s, nil
+}
+
+func (s *gatewaySource) follow(ctx context.Context, client agentpb.UIServiceClient, bo *util.Backoff) error {
+    stream, err := client.StreamFlamegraphs(ctx, &emptypb.Empty{})
+    if err != nil {
+        return err
+    }
+    for {
+        chunk, err := stream.Recv()
+        if err != nil {
+            return err
+        }
+        snap, _, err := envelopeSnapshot(chunk.Payload)
+        if err != nil {
+            continue
+        }
+        bo.Reset()
+        s.mu.Lock()
+        if s.pending == nil {
+            s.pending = flamegraph.New(snap.Root.Name)
+            s.chunks = make(map[string]int)
+        }
+        s.pending.Merge(snap.Root)
+        s.chunks[snap.Labels["agent"]]++
+        s.err = nil
+        s.mu.Unlock()
+    }
+}
+
+func (s *gatewaySource) describe() string { return s.addr }
+
+// take returns the chunks merged since the last call divided by the average
+// number of chunks per agent, i.e. one export of every agent.
+func (s *gatewaySource) take() (*flamegraph.Frame, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    snap := s.pending
+    s.pending = nil
+    if snap == nil {
+        if s.err != nil && !errors.Is(s.err, context.Canceled) {
+            return nil, s.err
+        }
+        return nil, nil
+    }
+    total := 0
+    for _, n := range s.chunks {
+        total += n
+    }
+    if total > len(s.chunks) {
+        scaleFrame(snap, float64(len(s.chunks))/float64(total))
+    }
+    return snap, nil
+}
+
+// scaleFrame multiplies every value below and including f by k.
+func scaleFrame(f *flamegraph.Frame, k float64) {
+    f.Value = int64(math.Round(float64(f.Value) * k))
+    for _, c := range f.Children {
+        scaleFrame(c, k)
+    }
+}
+
+//--------------------------------------------------------------------
+// interactive loop
+//--------------------------------------------------------------------
+
+func runTop(ctx context.Context, src topSource, v *topView, refresh time.Duration) error {
+    restore, err := makeRaw()
+    raw := err == nil
+    if raw {
+        defer restore()
+    }
+    out := bufio.NewWriter(os.Stdout)
+    fmt.Fprint(out, "\x1b[?1049h\x1b[?25l") // alternate screen, hide cursor
+    defer func() {
+        fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+        out.Flush()
+    }()
+
+    keys := make(chan string)
+    go readKeys(os.Stdin, raw, keys)
+
+    draw := func() {
+        w, h, err := termSize()
+        if err != nil || w <= 0 || h <= 0 {
+            w, h = 80, 24
+        }
+        fmt.Fprint(out, "\x1b[H\x1b[2J")
+        v.render(out, w, h, true)
+        out.Flush()
+    }
+    poll := func() {
+        if v.paused {
+            return
+        }
+        snap, err := src.take()
+        v.status = ""
+        if err != nil {
+            v.status = err.Error()
+        }
+        if snap != nil {
+            v.update(snap)
+        }
+    }
+
+    poll()
+    draw()
+    ticker := time.NewTicker(refresh)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return nil
+        case <-ticker.C:
+            poll()
+            draw()
+        case k, ok := <-keys:
+            if !ok || v.handleKey(k) {
+                return nil
+            }
+            draw()
+        }
+    }
+}
+
+// readKeys forwards key presses.  In raw mode one read is one key (escape
+// sequences arrive whole); otherwise every line is one command and an empty
+// line means Enter.
+func readKeys(r io.Reader, raw bool, out chan<- string) {
+    defer close(out)
+    if !raw {
+        sc := bufio.NewScanner(r)
+        for sc.Scan() {
+            line := sc.Text()
+            switch {
+            case line == "":
+                out <- "\r"
+            case strings.HasPrefix(line, "/"):
+                out <- line + "\r" // whole filter at once
+            default:
+                for _, c := range line {
+                    out <- string(c)
+                }
+            }
+        }
+        return
+    }
+    buf := make([]byte, 64)
+    for {
+        n, err := r.Read(buf)
+        if err != nil {
+            return
+        }
+        out <- string(buf[:n])
+    }
+}
+
+//--------------------------------------------------------------------
+// view model
+//--------------------------------------------------------------------
+
+// topView holds everything the screen shows.
+type topView struct {
+    source     string
+    cur, prev  *flamegraph.Frame
+    updated    time.Time
+    focus      []string // drill path, outermost first
+    filter     *regexp.Regexp
+    sortBySelf bool
+    paused     bool
+    cursor     int
+    status     string
+
+    typing bool   // reading a filter after "/"
+    input  string // filter being typed
+
+    rows []topRow // last rendered rows, for Enter
+}
+
+type topRow struct {
+    flamegraph.Hotspot
+    Delta   int64
+    IsNew   bool
+    HasPrev bool
+}
+
+func (v *topView) update(snap *flamegraph.Frame) {
+    v.prev, v.cur = v.cur, snap
+    v.updated = time.Now()
+}
+
+// view returns the focused subtree of t.
+func (v *topView) view(t *flamegraph.Frame) *flamegraph.Frame {
+    t = flamegraph.WithoutBands(t)
+    for _, name := range v.focus {
+        if t == nil {
+            return nil
+        }
+        t = flamegraph.FocusName(t, name)
+    }
+    return t
+}
+
+// computeRows ranks the focused frames and annotates the change since the
+// previous snapshot.
+func (v *topView) computeRows() []topRow {
+    cur := v.view(v.cur)
+    if cur == nil {
+        return nil
+    }
+    before := map[string]int64{}
+    havePrev := v.prev != nil
+    if p := v.view(v.prev); p != nil {
+        for _, h := range flamegraph.Hotspots(p) {
+            before[h.Name] = h.Cum
+        }
+    }
+    var rows []topRow
+    for _, h := range flamegraph.Hotspots(cur) {
+        if v.filter != nil && !v.filter.MatchString(h.Name) {
+            continue
+        }
+        old, seen := before[h.Name]
+        rows = append(rows, topRow{Hotspot: h, Delta: h.Cum - old, IsNew: havePrev && !seen, HasPrev: havePrev})
+    }
+    if v.sortBySelf {
+        sort.SliceStable(rows, func(i, j int) bool { return rows[i].Self > rows[j].Self })
+    }
+    return rows
+}
+
+// handleKey applies one key press and reports whether to quit.
+func (v *topView) handleKey(k string) bool {
+    if v.typing || (strings.HasPrefix(k, "/") && strings.HasSuffix(k, "\r") && len(k) > 1) {
+        return v.handleFilterKey(k)
+    }
+    switch k {
+    case "q", "Q", "\x03":
+        return true
+    case "p", " ":
+        v.paused = !v.paused
+    case "s":
+        v.sortBySelf = !v.sortBySelf
+        v.cursor = 0
+    case "/":
+        v.typing, v.input = true, ""
+    case "j", "\x1b[B":
+        if v.cursor < len(v.rows)-1 {
+            v.cursor++
+        }
+    case "k", "\x1b[A":
+        if v.cursor > 0 {
+            v.cursor--
+        }
+    case "\r", "\n":
+        if v.cursor < len(v.rows) {
+            v.focus = append(v.focus, v.rows[v.cursor].Name)
+            v.cursor = 0
+        }
+    case "\x7f", "\b", "\x1b", "h":
+        if len(v.focus) > 0 {
+            v.focus = v.focus[:len(v.focus)-1]
+            v.cursor = 0
+        }
+    }
+    return false
+}
+
+func (v *topView) handleFilterKey(k string) bool {
+    if !v.typing { // line mode: "/regex\r" in one go
+        v.input = strings.TrimSuffix(strings.TrimPrefix(k, "/"), "\r")
+        k = "\r"
+    }
+    switch k {
+    case "\r", "\n":
+        v.typing = false
+        v.cursor = 0
+        if v.input == "" {
+            v.filter = nil
+            return false
+        }
+        re, err := regexp.Compile(v.input)
+        if err != nil {
+            v.status = "bad filter: " + err.Error()
+            return false
+        }
+        v.filter = re
+    case "\x1b":
+        v.typing = false
+    case "\x7f", "\b":
+        if v.input != "" {
+            v.input = v.input[:len(v.input)-1]
+        }
+    default:
+        if len(k) == 1 && k[0] >= 0x20 {
+            v.input += k
+        }
+    }
+    return false
+}
+
+// render writes the screen.  With interactive unset no cursor highlight or
+// key help is drawn.
+func (v *topView) render(w io.Writer, width, height int, interactive bool) {
+    state := ""
+    if v.paused {
+        state = "  [PAUSED]"
+    }
+    sortBy := "cum"
+    if v.sortBySelf {
+        sortBy = "self"
+    }
+    updated := "waiting for data…"
+    if !v.updated.IsZero() {
+        updated = "updated " + v.updated.Format("15:04:05")
+    }
+    fmt.Fprintf(w, "flarego top — %s  %s  sort: %s%s\n", v.source, updated, sortBy, state)
+
+    var total int64
+    var bands []string
+    if v.cur != nil {
+        total = flamegraph.Total(flamegraph.WithoutBands(v.cur))
+        for _, b := range flamegraph.RuntimeBands {
+            val := int64(0)
+            if c := v.cur.Children[b]; c != nil {
+                val = c.Value
+            }
+            unit, _ := flamegraph.BandUnit(b)
+            bands = append(bands, b+" "+flamegraph.FormatValue(val, unit))
+        }
+    }
+    fmt.Fprintf(w, "total %s   %s\n", flamegraph.FormatValue(total, flamegraph.UnitSamples), strings.Join(bands, "   "))
+
+    crumbs := append([]string{"root"}, v.focus...)
+    line := "focus: " + strings.Join(crumbs, " › ")
+    switch {
+    case v.typing:
+        line += "   filter: /" + v.input + "▌"
+    case v.filter != nil:
+        line += "   filter: /" + v.filter.String() + "/"
+    }
+    fmt.Fprintln(w, line)
+    if v.status != "" {
+        fmt.Fprintln(w, "! "+v.status)
+    } else {
+        fmt.Fprintln(w)
+    }
+
+    rows := v.computeRows()
+    v.rows = rows
+    if v.cursor >= len(rows) {
+        v.cursor = max(0, len(rows)-1)
+    }
+    base := total
+    if f := v.view(v.cur); f != nil && len(v.focus) > 0 {
+        base = f.Value
+    }
+    fmt.Fprintf(w, "%10s %6s %10s %6s %10s  %s\n", "SELF", "SELF%", "CUM", "CUM%", "ΔCUM", "FRAME")
+
+    avail := height - 6
+    if interactive {
+        avail-- // key help
+    }
+    start := 0
+    if v.cursor >= avail && avail > 0 {
+        start = v.cursor - avail + 1
+    }
+    for i := start; i < len(rows) && i-start < avail; i++ {
+        r := rows[i]
+        delta := fmtDelta(r.Delta)
+        switch {
+        case !r.HasPrev:
+            delta = "-"
+        case r.IsNew:
+            delta = "new"
+        }
+        name := r.Name
+        if maxName := width - 53; maxName > 3 && utf8.RuneCountInString(name) > maxName {
+            name = string([]rune(name)[:maxName-1]) + "…"
+        }
+        text := fmt.Sprintf("%10s %5.1f%% %10s %5.1f%% %10s  %s",
+            flamegraph.FormatValue(r.Self, flamegraph.UnitSamples), pct(r.Self, base), flamegraph.FormatValue(r.Cum, flamegraph.UnitSamples), pct(r.Cum, base), delta, name)
+        if interactive && i == v.cursor {
+            text = "\x1b[7m" + text + "\x1b[0m"
+        }
+        fmt.Fprintln(w, text)
+    }
+    if interactive {
+        fmt.Fprintf(w, "\x1b[%d;1H\x1b[2mq quit  p pause  ↑/↓ select  enter drill  ⌫ up  / filter  s sort\x1b[0m", height)
+    }
+}
+
+func pct(v, total int64) float64 {
+    if total == 0 {
+        return 0
+    }
+    return float64(v) * 100 / float64(total)
+}
+
+func fmtDelta(v int64) string {
+    switch {
+    case v > 0:
+        return "+" + flamegraph.FormatValue(v, flamegraph.UnitSamples)
+    case v == 0:
+        return "0"
+    }
+    return flamegraph.FormatValue(v, flamegraph.UnitSamples)
+}
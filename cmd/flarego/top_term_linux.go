@@ -0,0 +1,40 @@
+//go:build linux
+
+// cmd/flarego/top_term_linux.go
+// Raw terminal mode for `flarego top` so single key presses arrive without
+// Enter and are not echoed.
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// makeRaw switches stdin to raw‑ish mode (no echo, no line buffering; output
+// processing and signals stay on so Ctrl‑C still works) and returns a restore
+// func.
+func makeRaw() (func(), error) {
+    fd := int(os.Stdin.Fd())
+    old, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+    if err != nil {
+        return nil, err
+    }
+    raw := *old
+    raw.Lflag &^= unix.ECHO | unix.ICANON
+    raw.Cc[unix.VMIN] = 1
+    raw.Cc[unix.VTIME] = 0
+    if err := unix.IoctlSetTermios(fd, unix.TCSETS, &raw); err != nil {
+        return nil, err
+    }
+    return func() { _ = unix.IoctlSetTermios(fd, unix.TCSETS, old) }, nil
+}
+
+// termSize returns the terminal's columns and rows.
+func termSize() (int, int, error) {
+    ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+    if err != nil {
+        return 0, 0, err
+    }
+    return int(ws.Col), int(ws.Row), nil
+}
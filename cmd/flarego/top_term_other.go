@@ -0,0 +1,12 @@
+//go:build !linux
+
+// cmd/flarego/top_term_other.go
+// Fallback for platforms without the Linux termios ioctls: keys are read
+// line‑buffered (type the key, then Enter) and a fixed 80×24 size is assumed.
+package main
+
+import "errors"
+
+func makeRaw() (func(), error) { return nil, errors.New("raw terminal mode not supported") }
+
+func termSize() (int, int, error) { return 0, 0, errors.New("terminal size not supported") }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+func TestTopRender(t *testing.T) {
+	root := flamegraph.New("root")
+	root.AddSample([]string{"main", strings.Repeat("関数", 60)}, 5)
+	root.AddSample([]string{flamegraph.BandGC}, int64(1500*time.Millisecond))
+	root.AddSample([]string{flamegraph.BandHeap}, 3<<20)
+	v := &topView{source: "test"}
+	v.update(root)
+
+	var buf bytes.Buffer
+	v.render(&buf, 80, 20, false)
+	out := buf.String()
+	if !utf8.ValidString(out) {
+		t.Errorf("Expected valid UTF-8 after truncating a multi-byte frame name")
+	}
+	if !strings.Contains(out, "関…") && !strings.Contains(out, "数…") {
+		t.Errorf("Expected the long name cut at a rune boundary:\n%s", out)
+	}
+	if !strings.Contains(out, "(GC) 1.5s") || !strings.Contains(out, "(Heap) 3.0 MiB") || !strings.Contains(out, "(Blocked) 0") {
+		t.Errorf("Expected bands in their own units:\n%s", out)
+	}
+}
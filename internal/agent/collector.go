@@ -25,6 +25,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Voskan/flarego/internal/logging"
 	"github.com/Voskan/flarego/pkg/flamegraph"
 )
 
@@ -34,6 +35,15 @@ type Sampler interface {
     Stop()
 }
 
+// Scraper is a Sampler that pulls one complete sample set on demand instead
+// of sampling continuously (e.g. sampler.PprofScraper).  The collector calls
+// ScrapeOnce right before every export, so each snapshot holds exactly one
+// scrape.
+type Scraper interface {
+    Sampler
+    ScrapeOnce(ctx context.Context) error
+}
+
 // Exporter delivers a flame graph snapshot to an external sink (gateway, file,
 // stdout…).  Implementations must be safe for concurrent use.
 type Exporter interface {
@@ -65,6 +75,7 @@ type Collector struct {
     samplers  []Sampler
     exporters []Exporter
 
+    started   bool
     exportT   *time.Ticker
     quit      chan struct{}
     wg        sync.WaitGroup
@@ -95,14 +106,12 @@ func (c *Collector) Builder() *flamegraph.Builder { return c.builder }
 func (c *Collector) AddSampler(s Sampler) {
     c.mu.Lock()
     c.samplers = append(c.samplers, s)
+    running := c.started && c.quit != nil
     c.mu.Unlock()
 
-    // Start if collector already running.
-    select {
-    case <-c.quit:
-        // collector stopped – do nothing
-    default:
-        // best‑effort start, sampler ensures idempotence
+    // Start now only if the collector is already running; otherwise Start
+    // launches it.  Starting twice would run two sampling loops.
+    if running {
         s.Start()
     }
 }
@@ -119,10 +128,11 @@ func (c *Collector) AddExporter(e Exporter) {
 // Calling Start multiple times is safe but only has effect the first time.
 func (c *Collector) Start() {
     c.mu.Lock()
-    if c.exportT != nil || c.quit == nil {
+    if c.started || c.quit == nil {
         c.mu.Unlock()
         return // already running or collector closed
     }
+    c.started = true
 
     // Start samplers.
     for _, s := range c.samplers {
@@ -133,21 +143,26 @@ func (c *Collector) Start() {
     if c.cfg.ExportEvery > 0 {
         c.exportT = time.NewTicker(c.cfg.ExportEvery)
         c.wg.Add(1)
-        go c.runExportLoop()
+        go c.runExportLoop(c.exportT, c.quit)
     }
     c.mu.Unlock()
 }
 
 // runExportLoop periodically snapshots the builder and pushes to exporters.
-func (c *Collector) runExportLoop() {
+func (c *Collector) runExportLoop(t *time.Ticker, quit chan struct{}) {
     defer c.wg.Done()
 
-    ctx := context.Background()
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    go func() {
+        <-quit // Stop aborts an in‑flight scrape or export
+        cancel()
+    }()
     for {
         select {
-        case <-c.exportT.C:
+        case <-t.C:
             c.pushSnapshot(ctx)
-        case <-c.quit:
+        case <-quit:
             return
         }
     }
@@ -159,14 +174,24 @@ func (c *Collector) TriggerExport(ctx context.Context) error {
     return c.pushSnapshot(ctx)
 }
 
-// pushSnapshot grabs a copy of the current flame graph and iterates exporters.
+// pushSnapshot runs the scrapers, grabs a copy of the current flame graph and
+// iterates exporters.  A failed scrape is logged; whatever it added is still
+// exported.
 func (c *Collector) pushSnapshot(ctx context.Context) error {
-    snapshot := c.builder.Build()
-
     c.mu.Lock()
+    samplers := append([]Sampler(nil), c.samplers...)
     exporters := append([]Exporter(nil), c.exporters...)
     c.mu.Unlock()
 
+    for _, s := range samplers {
+        if sc, ok := s.(Scraper); ok {
+            if err := sc.ScrapeOnce(ctx); err != nil && ctx.Err() == nil {
+                logging.Sugar().Warnw("scrape", "err", err)
+            }
+        }
+    }
+    snapshot := c.builder.Build()
+
     for _, e := range exporters {
         if err := e.Export(ctx, snapshot); err != nil {
             return err
@@ -182,8 +207,7 @@ func (c *Collector) Stop() {
         c.mu.Unlock()
         return // already stopped
     }
-    close(c.quit)
-    quitCh := c.quit
+    close(c.quit) // also releases any goroutines blocked on quit
     c.quit = nil
     t := c.exportT
     c.exportT = nil
@@ -213,7 +237,4 @@ func (c *Collector) Stop() {
     for _, e := range exporters {
         _ = e.Close()
     }
-
-    // ensure we silence any goroutines blocked on quit channel
-    close(quitCh)
 }
@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+// countingScraper adds one sample per scrape.
+type countingScraper struct {
+	b       *flamegraph.Builder
+	scrapes int
+}
+
+func (s *countingScraper) Start() {}
+func (s *countingScraper) Stop()  {}
+
+func (s *countingScraper) ScrapeOnce(context.Context) error {
+	s.scrapes++
+	s.b.Add(flamegraph.Sample{Stack: []string{"scrape"}, Weight: 1})
+	return nil
+}
+
+type lastExporter struct{ root *flamegraph.Frame }
+
+func (e *lastExporter) Export(_ context.Context, root *flamegraph.Frame) error {
+	e.root = root
+	return nil
+}
+func (e *lastExporter) Close() error { return nil }
+
+func TestCollectorScrapesPerExport(t *testing.T) {
+	col := NewCollector(Config{})
+	sc := &countingScraper{b: col.Builder()}
+	exp := &lastExporter{}
+	col.AddSampler(sc)
+	col.AddExporter(exp)
+	col.Start()
+	defer col.Stop()
+
+	for i := 1; i <= 2; i++ {
+		if err := col.TriggerExport(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if sc.scrapes != i {
+			t.Errorf("Expected %d scrapes after %d exports, got %d", i, i, sc.scrapes)
+		}
+		if c := exp.root.Children["scrape"]; c == nil || c.Value != 1 {
+			t.Errorf("Expected each snapshot to hold exactly one scrape, got %+v", c)
+		}
+	}
+}
@@ -3,19 +3,30 @@
 // to transmit aggregated flame graphs to a FlareGo gateway.  The gRPC exporter
 // maintains a persistent bidirectional stream and performs automatic
 // reconnect with jittered exponential back‑off.
+//
+// When the gateway ends the stream with ResourceExhausted (ingest limits or
+// tenant quotas) the exporter drops snapshots locally for the advertised
+// "flarego-retry-after" interval, returning ErrThrottled, instead of
+// reconnecting straight away.
 package exporter
 
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	agentpb "github.com/Voskan/flarego/internal/proto"
+	"github.com/Voskan/flarego/internal/util"
 	"github.com/Voskan/flarego/pkg/flamegraph"
 )
 
@@ -28,22 +39,46 @@ import (
 // ● StreamRetry controls reconnection policy; if nil a sensible default
 //   (max 1 minute, factor 2, jitter) is used.
 // ● FlushTimeout bounds time spent per Export call.
+// ● Labels, comma separated key=value pairs such as "service=api,env=prod",
+//   are sent as "flarego-labels" metadata so subscribers can select agents.
+// ● TLSCertPath/TLSKeyPath present a client certificate (mTLS); TLSCAPath
+//   replaces the system roots for verifying the gateway; ServerName
+//   overrides the name checked against its certificate.  All files are
+//   re‑read when they change, so rotated certificates apply on reconnect.
 type Config struct {
     Addr         string
     AuthToken    string
     Opts         []grpc.DialOption
     StreamRetry  backoff.BackOff
     FlushTimeout time.Duration
+    Labels       string
+
+    TLSCertPath string
+    TLSKeyPath  string
+    TLSCAPath   string
+    ServerName  string
 }
 
+// ErrThrottled is returned (wrapped) by Export while the gateway asked the
+// agent to back off.
+var ErrThrottled = errors.New("gateway throttled agent")
+
+// retryAfterTrailer mirrors gateway.RetryAfterTrailer.
+const retryAfterTrailer = "flarego-retry-after"
+
+// labelsMetadata mirrors gateway.LabelsMetadata.
+const labelsMetadata = "flarego-labels"
+
 // grpcExporter implements agent.Exporter.
 type grpcExporter struct {
     cfg    Config
+    certs  *util.CertReloader
     client agentpb.GatewayServiceClient
     conn   *grpc.ClientConn
     stream agentpb.GatewayService_StreamClient
 
-    closing chan struct{}
+    throttledUntil time.Time // Export drops snapshots until then
+    closing        chan struct{}
 }
 
 // NewGRPCExporter creates and connects an exporter. The call blocks until the
@@ -61,6 +96,13 @@ func NewGRPCExporter(ctx context.Context, cfg Config) (*grpcExporter, error) {
         cfg.StreamRetry = bo
         g.cfg.StreamRetry = bo
     }
+    if cfg.TLSCertPath != "" || cfg.TLSCAPath != "" {
+        r, err := util.NewCertReloader(cfg.TLSCertPath, cfg.TLSKeyPath, cfg.TLSCAPath)
+        if err != nil {
+            return nil, err
+        }
+        g.certs = r
+    }
     if err := g.connect(ctx); err != nil {
         return nil, err
     }
@@ -73,6 +115,9 @@ func (g *grpcExporter) Export(ctx context.Context, root *flamegraph.Frame) error
     if root == nil {
         return nil
     }
+    if wait := time.Until(g.throttledUntil); wait > 0 {
+        return fmt.Errorf("%w for another %s", ErrThrottled, wait.Round(time.Millisecond))
+    }
     // Marshal graph to JSON; the UI and gateway accept raw JSON blob to keep
     // proto schema stable.
     data, err := root.ToJSON()
@@ -96,6 +141,11 @@ func (g *grpcExporter) Export(ctx context.Context, root *flamegraph.Frame) error
     defer cancel()
 
     if err := g.stream.Send(&agentpb.FlamegraphChunk{Payload: data}); err != nil {
+        if wait, ok := g.throttled(err); ok {
+            g.throttledUntil = time.Now().Add(wait)
+            g.dropStream()
+            return fmt.Errorf("%w: retry in %s", ErrThrottled, wait)
+        }
         // Attempt reconnection once; caller may re‑invoke.
         _ = g.reconnect(ctx)
         return err
@@ -129,7 +179,11 @@ func (g *grpcExporter) connect(ctx context.Context) error {
         }
     }
     if !hasCreds {
-        dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})))
+        tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: g.cfg.ServerName}
+        if g.certs != nil {
+            tlsCfg = g.certs.ClientConfig(g.cfg.ServerName, g.cfg.Addr)
+        }
+        dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
     }
     // Block until ready.
     dialOpts = append(dialOpts, grpc.WithBlock())
@@ -144,6 +198,9 @@ func (g *grpcExporter) connect(ctx context.Context) error {
     if g.cfg.AuthToken != "" {
         md.Set("authorization", "Bearer "+g.cfg.AuthToken)
     }
+    if g.cfg.Labels != "" {
+        md.Set(labelsMetadata, g.cfg.Labels)
+    }
     stream, err := client.Stream(metadata.NewOutgoingContext(ctx, md))
     if err != nil {
         _ = conn.Close()
@@ -156,9 +213,27 @@ func (g *grpcExporter) connect(ctx context.Context) error {
     return nil
 }
 
-// reconnect closes existing resources and retries connect() respecting the
-// configured back‑off policy.
-func (g *grpcExporter) reconnect(ctx context.Context) error {
+// throttled inspects a Send error.  The server's status only surfaces via
+// CloseAndRecv (Send reports io.EOF), so fetch it and report the requested
+// back‑off for ResourceExhausted; without a hint one second is assumed.
+func (g *grpcExporter) throttled(err error) (time.Duration, bool) {
+    if errors.Is(err, io.EOF) {
+        _, err = g.stream.CloseAndRecv()
+    }
+    if status.Code(err) != codes.ResourceExhausted {
+        return 0, false
+    }
+    wait := time.Second
+    if v := g.stream.Trailer().Get(retryAfterTrailer); len(v) > 0 {
+        if d, perr := time.ParseDuration(v[0]); perr == nil && d > 0 {
+            wait = d
+        }
+    }
+    return wait, true
+}
+
+// dropStream closes the stream and connection; the next Export reconnects.
+func (g *grpcExporter) dropStream() {
     if g.stream != nil {
         _ = g.stream.CloseSend()
         g.stream = nil
@@ -167,6 +242,12 @@ func (g *grpcExporter) reconnect(ctx context.Context) error {
         _ = g.conn.Close()
         g.conn = nil
     }
+}
+
+// reconnect closes existing resources and retries connect() respecting the
+// configured back‑off policy.
+func (g *grpcExporter) reconnect(ctx context.Context) error {
+    g.dropStream()
 
     bo := g.cfg.StreamRetry
     bo.Reset()
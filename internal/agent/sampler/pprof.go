@@ -0,0 +1,377 @@
+// internal/agent/sampler/pprof.go
+// PprofScraper is a pull‑mode sampler: instead of inspecting the current
+// process it polls the net/http/pprof endpoints of other processes and feeds
+// what it finds into flamegraph.Builder.  Unmodified binaries that already
+// import net/http/pprof can therefore be profiled by a sidecar agent.
+//
+// Per target and scrape the scraper fetches (selectable via Profiles):
+//
+//	goroutine?debug=2 – one sample of weight 1 per goroutine
+//	heap?debug=1      – in‑use bytes per allocation site, unsampled like `go tool pprof`
+//	mutex?debug=1     – contention in nanoseconds since the previous scrape
+//	block?debug=1     – blocking in nanoseconds since the previous scrape
+//
+// The debug text formats are used because they are stable, need no protobuf
+// decoding and carry symbolised stacks.  Every sample is prefixed with the
+// target label and a "(goroutine)" / "(heap)" / … pseudo frame, so one
+// exported tree holds every target side by side:
+//
+//	root → api-7 → (heap) → main.main → …
+//
+// The scraper has no clock of its own: it implements agent.Scraper and the
+// collector calls ScrapeOnce right before every export, so each exported
+// snapshot holds exactly one scrape.  Goroutine and heap values are
+// point‑in‑time; mutex and block profiles are cumulative since the target
+// started, so they are diffed against the previous scrape of the same target
+// and the first scrape only records the baseline.
+package sampler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+// Profiles understood by PprofScraper.
+const (
+    ProfileGoroutine = "goroutine"
+    ProfileHeap      = "heap"
+    ProfileMutex     = "mutex"
+    ProfileBlock     = "block"
+)
+
+// DefaultProfiles is used for targets without an explicit list.
+var DefaultProfiles = []string{ProfileGoroutine, ProfileHeap, ProfileMutex, ProfileBlock}
+
+// PprofTarget is one process to scrape.
+type PprofTarget struct {
+    Label    string   // top frame for this target's samples, e.g. "api-7"
+    URL      string   // pprof base, e.g. "http://10.0.0.7:6060/debug/pprof"
+    Profiles []string // subset of the Profile* constants; nil = DefaultProfiles
+}
+
+// ParsePprofTarget parses "label=url" (or a bare url, labelled by its host).
+func ParsePprofTarget(s string) (PprofTarget, error) {
+    label, url, ok := strings.Cut(s, "=")
+    if !ok {
+        url, label = s, ""
+    }
+    url = strings.TrimRight(url, "/")
+    if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+        return PprofTarget{}, fmt.Errorf("pprof target %q: URL must start with http:// or https://", s)
+    }
+    if !strings.HasSuffix(url, "/debug/pprof") {
+        url += "/debug/pprof"
+    }
+    if label == "" {
+        label = strings.SplitN(strings.SplitN(url, "://", 2)[1], "/", 2)[0]
+    }
+    return PprofTarget{Label: label, URL: url}, nil
+}
+
+// PprofScraper scrapes targets whenever ScrapeOnce is called.
+type PprofScraper struct {
+    builder *flamegraph.Builder
+    targets []PprofTarget
+    client  *http.Client
+
+    ctx    context.Context // cancelled by Stop
+    cancel context.CancelFunc
+
+    mu   sync.Mutex
+    prev map[string]map[string]int64 // "<url> <profile>" → stack → cumulative value
+}
+
+// NewPprofScraper constructs a scraper adding to b.
+func NewPprofScraper(b *flamegraph.Builder, targets []PprofTarget) *PprofScraper {
+    ctx, cancel := context.WithCancel(context.Background())
+    return &PprofScraper{
+        builder: b,
+        targets: targets,
+        client:  &http.Client{Timeout: 10 * time.Second},
+        ctx:     ctx,
+        cancel:  cancel,
+        prev:    make(map[string]map[string]int64),
+    }
+}
+
+// Start implements agent.Sampler.  Scrapes are driven by the collector's
+// export cycle, so there is nothing to launch.
+func (s *PprofScraper) Start() {}
+
+// Stop aborts an in‑flight scrape; later ones fail immediately.
+func (s *PprofScraper) Stop() { s.cancel() }
+
+// ScrapeOnce fetches every profile of every target concurrently and adds the
+// samples to the builder.  It returns the first error; other targets are
+// still scraped.
+func (s *PprofScraper) ScrapeOnce(ctx context.Context) error {
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    stop := context.AfterFunc(s.ctx, cancel)
+    defer stop()
+
+    var (
+        wg       sync.WaitGroup
+        mu       sync.Mutex
+        firstErr error
+    )
+    for _, t := range s.targets {
+        profiles := t.Profiles
+        if len(profiles) == 0 {
+            profiles = DefaultProfiles
+        }
+        for _, p := range profiles {
+            wg.Add(1)
+            go func(t PprofTarget, p string) {
+                defer wg.Done()
+                samples, err := s.fetch(ctx, t.URL, p)
+                if err != nil {
+                    mu.Lock()
+                    if firstErr == nil {
+                        firstErr = fmt.Errorf("%s %s: %w", t.Label, p, err)
+                    }
+                    mu.Unlock()
+                    return
+                }
+                if p == ProfileMutex || p == ProfileBlock {
+                    samples = s.sinceLast(t.URL+" "+p, samples)
+                }
+                prefix := []string{t.Label, "(" + p + ")"}
+                for _, smp := range samples {
+                    smp.Stack = append(append([]string(nil), prefix...), smp.Stack...)
+                    s.builder.Add(smp)
+                }
+            }(t, p)
+        }
+    }
+    wg.Wait()
+    return firstErr
+}
+
+// sinceLast turns the cumulative samples of one target's profile into the
+// growth since the previous call with the same key.  The first call only
+// records the baseline.  When any stack went backwards the target restarted,
+// and its totals are reported as they are.
+func (s *PprofScraper) sinceLast(key string, samples []flamegraph.Sample) []flamegraph.Sample {
+    cur := make(map[string]int64, len(samples))
+    for _, smp := range samples {
+        cur[strings.Join(smp.Stack, "\x00")] += smp.Weight
+    }
+    s.mu.Lock()
+    prev, seen := s.prev[key]
+    s.prev[key] = cur
+    s.mu.Unlock()
+    if !seen {
+        return nil
+    }
+    restarted := false
+    for k, v := range prev {
+        if cur[k] < v {
+            restarted = true
+            break
+        }
+    }
+    var out []flamegraph.Sample
+    for k, v := range cur {
+        if !restarted {
+            v -= prev[k]
+        }
+        if v > 0 {
+            out = append(out, flamegraph.Sample{Stack: strings.Split(k, "\x00"), Weight: v})
+        }
+    }
+    return out
+}
+
+func (s *PprofScraper) fetch(ctx context.Context, base, profile string) ([]flamegraph.Sample, error) {
+    debug := "1"
+    if profile == ProfileGoroutine {
+        debug = "2"
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/"+profile+"?debug="+debug, nil)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+    }
+    switch profile {
+    case ProfileGoroutine:
+        return ParseGoroutineDump(resp.Body)
+    case ProfileHeap:
+        return ParseHeapProfile(resp.Body)
+    case ProfileMutex, ProfileBlock:
+        return ParseContentionProfile(resp.Body)
+    }
+    return nil, fmt.Errorf("unknown profile %q", profile)
+}
+
+//--------------------------------------------------------------------
+// parsers
+//--------------------------------------------------------------------
+
+// ParseGoroutineDump parses goroutine?debug=2 output (the runtime's panic
+// format): blocks of "goroutine N [state]:" followed by function / file line
+// pairs, leaf first.
+func ParseGoroutineDump(r io.Reader) ([]flamegraph.Sample, error) {
+    var (
+        out   []flamegraph.Sample
+        stack []string // leaf first
+        in    bool
+    )
+    flush := func() {
+        if in && len(stack) > 0 {
+            out = append(out, flamegraph.Sample{Stack: reversed(stack), Weight: 1})
+        }
+        stack, in = stack[:0], false
+    }
+    sc := bufio.NewScanner(r)
+    sc.Buffer(make([]byte, 64*1024), 1024*1024)
+    for sc.Scan() {
+        line := sc.Text()
+        switch {
+        case strings.HasPrefix(line, "goroutine "):
+            flush()
+            in = true
+        case line == "":
+            flush()
+        case !in, strings.HasPrefix(line, "\t"), strings.HasPrefix(line, "created by "),
+            strings.HasPrefix(line, "..."):
+            // file:line, creator and elision markers carry no frame
+        default:
+            if name := trimArgs(line); name != "" {
+                stack = append(stack, trimPkgPath(name))
+            }
+        }
+    }
+    flush()
+    return out, sc.Err()
+}
+
+// ParseHeapProfile parses heap?debug=1 output and reports in‑use bytes per
+// stack, scaled for the runtime's sampling rate.
+func ParseHeapProfile(r io.Reader) ([]flamegraph.Sample, error) {
+    var rate float64
+    return parseLegacy(r, func(header string) {
+        // heap profile: 1: 1048576 [1: 1048577] @ heap/524288
+        if i := strings.LastIndex(header, "heap/"); i >= 0 {
+            rate, _ = strconv.ParseFloat(header[i+5:], 64)
+        }
+    }, func(fields []string) int64 {
+        // 3: 4096 [10: 16384] @ 0x… – in‑use objects: bytes [alloc objects: bytes]
+        if len(fields) < 2 {
+            return 0
+        }
+        objs, _ := strconv.ParseFloat(strings.TrimSuffix(fields[0], ":"), 64)
+        bytes, _ := strconv.ParseFloat(fields[1], 64)
+        if objs > 0 && rate > 0 {
+            bytes /= 1 - math.Exp(-(bytes/objs)/rate)
+        }
+        return int64(bytes)
+    })
+}
+
+// ParseContentionProfile parses mutex?debug=1 / block?debug=1 output and
+// reports delay in nanoseconds per stack.
+func ParseContentionProfile(r io.Reader) ([]flamegraph.Sample, error) {
+    cyclesPerSec := 1e9
+    return parseLegacy(r, func(header string) {
+        if v, ok := strings.CutPrefix(header, "cycles/second="); ok {
+            if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+                cyclesPerSec = f
+            }
+        }
+    }, func(fields []string) int64 {
+        // <cycles> <count> @ 0x…
+        cycles, _ := strconv.ParseFloat(fields[0], 64)
+        return int64(cycles / cyclesPerSec * 1e9)
+    })
+}
+
+// parseLegacy walks the shared legacy text layout: header lines, then
+// records "<values> @ <pcs>" each followed by "#\t<pc>\t<func>+0x…\t<file>"
+// lines, leaf first.
+func parseLegacy(r io.Reader, header func(string), weight func([]string) int64) ([]flamegraph.Sample, error) {
+    var (
+        out   []flamegraph.Sample
+        stack []string
+        w     int64
+    )
+    flush := func() {
+        if len(stack) > 0 && w != 0 {
+            out = append(out, flamegraph.Sample{Stack: reversed(stack), Weight: w})
+        }
+        stack, w = stack[:0], 0
+    }
+    sc := bufio.NewScanner(r)
+    sc.Buffer(make([]byte, 64*1024), 1024*1024)
+    for sc.Scan() {
+        line := sc.Text()
+        switch {
+        case strings.HasPrefix(line, "#"):
+            f := strings.Fields(line)
+            if len(f) >= 3 && strings.HasPrefix(f[1], "0x") {
+                name := f[2]
+                if i := strings.LastIndex(name, "+0x"); i > 0 {
+                    name = name[:i]
+                }
+                stack = append(stack, trimPkgPath(name))
+            }
+        case strings.HasPrefix(line, "heap profile:"):
+            header(line) // also contains " @ "
+        case strings.Contains(line, " @ "):
+            flush()
+            w = weight(strings.Fields(line))
+        case line == "":
+            flush()
+        default:
+            header(line)
+        }
+    }
+    flush()
+    return out, sc.Err()
+}
+
+// trimArgs turns "net/http.(*conn).serve(0xc000…, {…})" into
+// "net/http.(*conn).serve".
+func trimArgs(line string) string {
+    if !strings.HasSuffix(line, ")") {
+        return line
+    }
+    depth := 0
+    for i := len(line) - 1; i >= 0; i-- {
+        switch line[i] {
+        case ')':
+            depth++
+        case '(':
+            depth--
+            if depth == 0 {
+                return line[:i]
+            }
+        }
+    }
+    return line
+}
+
+func reversed(s []string) []string {
+    out := make([]string, len(s))
+    for i, v := range s {
+        out[len(s)-1-i] = v
+    }
+    return out
+}
@@ -0,0 +1,144 @@
+package sampler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+var sink [][]byte
+
+func contend() {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				mu.Lock()
+				time.Sleep(100 * time.Microsecond)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPprofScraper(t *testing.T) {
+	runtime.SetMutexProfileFraction(1)
+	runtime.SetBlockProfileRate(1)
+	defer runtime.SetMutexProfileFraction(0)
+	defer runtime.SetBlockProfileRate(0)
+
+	// Produce some contention and a live allocation.
+	contend()
+	sink = append(sink, make([]byte, 4<<20))
+	runtime.GC()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	target, err := ParsePprofTarget("svc=" + srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := flamegraph.NewBuilder("root")
+	s := NewPprofScraper(b, []PprofTarget{target})
+	if err := s.ScrapeOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	root := b.Build()
+
+	svc := root.Children["svc"]
+	if svc == nil {
+		t.Fatalf("Expected target label frame, got children %v", root.Children)
+	}
+	if g := svc.Children["(goroutine)"]; g == nil || g.Value < 1 {
+		t.Errorf("Expected at least one goroutine, got %+v", g)
+	}
+	if h := svc.Children["(heap)"]; h == nil || h.Value < 4<<20 {
+		t.Errorf("Expected heap subtree to hold the 4 MiB allocation, got %+v", h)
+	}
+	if svc.Children["(mutex)"] != nil || svc.Children["(block)"] != nil {
+		t.Error("Expected the first scrape to only record the contention baseline")
+	}
+
+	// The second scrape reports the contention in between, not the totals.
+	contend()
+	if err := s.ScrapeOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	svc = b.Build().Children["svc"]
+	for _, p := range []string{"(mutex)", "(block)"} {
+		if c := svc.Children[p]; c == nil || c.Value <= 0 {
+			t.Errorf("Expected %s growth since the previous scrape, got %+v", p, c)
+		}
+	}
+
+	s.Stop()
+	if err := s.ScrapeOnce(context.Background()); err == nil {
+		t.Error("Expected scrapes after Stop to fail")
+	}
+}
+
+func TestPprofScraperSinceLast(t *testing.T) {
+	s := NewPprofScraper(flamegraph.NewBuilder("root"), nil)
+	scrape := func(a, b int64) map[string]int64 {
+		out := map[string]int64{}
+		for _, smp := range s.sinceLast("t mutex", []flamegraph.Sample{
+			{Stack: []string{"main", "a"}, Weight: a},
+			{Stack: []string{"main", "b"}, Weight: b},
+		}) {
+			out[strings.Join(smp.Stack, ";")] = smp.Weight
+		}
+		return out
+	}
+	if got := scrape(100, 50); len(got) != 0 {
+		t.Errorf("Expected baseline only, got %v", got)
+	}
+	if got := scrape(130, 50); len(got) != 1 || got["main;a"] != 30 {
+		t.Errorf("Expected growth of a only, got %v", got)
+	}
+	if got := scrape(10, 5); got["main;a"] != 10 || got["main;b"] != 5 {
+		t.Errorf("Expected totals after a target restart, got %v", got)
+	}
+}
+
+func TestParseGoroutineDump(t *testing.T) {
+	dump := `goroutine 1 [select]:
+net/http.(*persistConn).roundTrip(0x3288, {0x1, 0x2})
+	/usr/local/go/src/net/http/transport.go:3069 +0x84b
+main.main()
+	/tmp/main.go:10 +0x29
+
+goroutine 7 [chan receive]:
+main.worker(...)
+	/tmp/main.go:20
+created by main.main in goroutine 1
+	/tmp/main.go:9 +0x1c
+`
+	samples, err := ParseGoroutineDump(strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("Expected 2 goroutines, got %d", len(samples))
+	}
+	if got := samples[0].Stack; len(got) != 2 || got[0] != "main.main" || got[1] != "http.(*persistConn).roundTrip" {
+		t.Errorf("Expected root-first stack with trimmed package paths, got %v", got)
+	}
+	if s := samples[1].Stack; len(s) != 1 || s[0] != "main.worker" {
+		t.Errorf("Expected creator line to be skipped, got %v", s)
+	}
+}
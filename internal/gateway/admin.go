@@ -0,0 +1,72 @@
+// internal/gateway/admin.go
+// Operator‑facing HTTP endpoints mounted under /admin on the HTTP listener.
+// All routes require the admin scope and respond with JSON.
+//
+//	GET /admin/agents                – agents currently streaming to the caller's tenant
+//	GET /admin/webhooks/dead-letters – webhook deliveries that exhausted retries
+//
+// Responses only cover the caller's tenant.  Every call is audited.
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Voskan/flarego/internal/gateway/alerts/sinks"
+	"go.uber.org/zap"
+)
+
+// registerAdminRoutes mounts the /admin handlers on mux.
+func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
+    admin := func(h http.HandlerFunc) http.Handler {
+        return s.requireScope(ScopeAdmin, s.auditAdmin(h))
+    }
+    mux.Handle("/admin/agents", admin(s.handleAgents))
+    mux.Handle("/admin/webhooks/dead-letters", admin(s.handleWebhookDeadLetters))
+}
+
+func (s *Server) handleAgents(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    t, err := s.tenantFor(PrincipalFromContext(r.Context()).Tenant)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusForbidden)
+        return
+    }
+    writeJSON(w, http.StatusOK, t.listAgents())
+}
+
+func (s *Server) handleWebhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    tenant := PrincipalFromContext(r.Context()).Tenant
+    if tenant == DefaultTenant {
+        tenant = "" // alerts of the default tenant carry no tenant
+    }
+    dead := []sinks.Delivery{}
+    for _, wh := range s.webhooks {
+        d, err := wh.DeadLetters()
+        if err != nil {
+            s.Logger().Warn("list dead letters", zap.Error(err))
+            http.Error(w, "outbox unavailable", http.StatusInternalServerError)
+            return
+        }
+        for _, dl := range d {
+            if dl.Tenant == tenant {
+                dead = append(dead, dl)
+            }
+        }
+    }
+    writeJSON(w, http.StatusOK, dead)
+}
+
+// writeJSON encodes v with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(code)
+    _ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,193 @@
+// internal/gateway/alerting.go
+// Glue between the gateway and internal/gateway/alerts: builds the rule
+// engine from Config.AlertRules, resolves sink spec strings and serves the
+// evidence artifacts referenced by fired alerts.
+//
+// Sink specs:
+//
+//	log
+//	slack:<incoming-webhook-url>
+//	webhook[:<url>]         – without url the gateway‑wide --webhook-url sink
+//	jira:<base-url>?project=KEY[&issue_type=Bug][&resolve=Resolved][&field=customfield_N]
+package gateway
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/Voskan/flarego/internal/gateway/alerts"
+	"github.com/Voskan/flarego/internal/gateway/alerts/sinks"
+)
+
+// scopedRule is a rule plus the tenant it is restricted to ("" = all).
+type scopedRule struct {
+    tenant string
+    rule   alerts.Rule
+}
+
+// setupAlerts validates configured rules and resolves their sinks once; each
+// tenant then gets its own engine over the applicable rules (newTenantEngine).
+func (s *Server) setupAlerts() error {
+    if len(s.cfg.AlertRules) == 0 {
+        return nil
+    }
+    if s.cfg.ArtifactDir != "" {
+        ev, err := alerts.NewEvidenceStore(s.cfg.ArtifactDir, s.cfg.PublicURL)
+        if err != nil {
+            return err
+        }
+        s.evidence = ev
+    }
+
+    for _, spec := range s.cfg.AlertRules {
+        if spec.Tenant != "" && !ValidTenant(spec.Tenant) {
+            return fmt.Errorf("rule %q: invalid tenant %q", spec.Name, spec.Tenant)
+        }
+        r := alerts.Rule{Name: spec.Name, Expr: spec.Expr, For: spec.For, Labels: spec.Labels}
+        for _, ss := range spec.Sinks {
+            sink, err := s.buildSink(ss)
+            if err != nil {
+                return fmt.Errorf("rule %q: %w", spec.Name, err)
+            }
+            r.Sinks = append(r.Sinks, sink)
+        }
+        if len(r.Sinks) == 0 {
+            r.Sinks = []alerts.Sink{sinks.NewLogSink()}
+        }
+        s.rules = append(s.rules, scopedRule{tenant: spec.Tenant, rule: r})
+    }
+    // Compile once up front so syntax errors surface at boot, not on the
+    // first chunk of some tenant.
+    _, err := s.newTenantEngine(DefaultTenant)
+    return err
+}
+
+// newTenantEngine builds the engine for tenant; nil when no rule applies.
+func (s *Server) newTenantEngine(tenant string) (*alerts.Engine, error) {
+    var rules []alerts.Rule
+    for _, sr := range s.rules {
+        if sr.tenant == "" || sr.tenant == tenant {
+            rules = append(rules, sr.rule)
+        }
+    }
+    if len(rules) == 0 {
+        return nil, nil
+    }
+    ecfg := alerts.EngineConfig{BaselineWindow: s.cfg.BaselineWindow}
+    if tenant != DefaultTenant {
+        ecfg.Tenant = tenant
+    }
+    if s.evidence != nil {
+        ev, err := s.evidence.ForTenant(ecfg.Tenant)
+        if err != nil {
+            return nil, err
+        }
+        ecfg.Evidence = ev
+    }
+    return alerts.NewEngine(ecfg, rules)
+}
+
+// buildSink resolves one sink spec string (see file comment).
+func (s *Server) buildSink(spec string) (alerts.Sink, error) {
+    kind, arg, _ := strings.Cut(spec, ":")
+    switch kind {
+    case "log":
+        return sinks.NewLogSink(), nil
+    case "slack":
+        if arg == "" {
+            return nil, fmt.Errorf("sink %q: missing webhook URL", spec)
+        }
+        return sinks.NewSlackSink(arg), nil
+    case "webhook":
+        if arg == "" {
+            arg = s.cfg.WebhookURL
+        }
+        if arg == "" {
+            return nil, fmt.Errorf("sink %q: no URL and no gateway webhook configured", spec)
+        }
+        // Rules naming the same endpoint share its sink: a second one would
+        // run another worker over the same outbox and deliver twice.
+        for _, wh := range s.webhooks {
+            if wh.URL == arg {
+                return wh, nil
+            }
+        }
+        wh := sinks.NewWebhookSink(arg)
+        wh.Secret = s.cfg.WebhookSecret
+        if s.cfg.WebhookOutboxDir != "" {
+            // One outbox per endpoint so dead letters stay attributable.
+            h := fnv.New32a()
+            _, _ = h.Write([]byte(arg))
+            wh.OutboxDir = filepath.Join(s.cfg.WebhookOutboxDir, fmt.Sprintf("%08x", h.Sum32()))
+        }
+        if err := wh.Start(); err != nil {
+            return nil, err
+        }
+        s.webhooks = append(s.webhooks, wh)
+        return wh, nil
+    case "jira":
+        u, err := url.Parse(arg)
+        if err != nil || u.Host == "" {
+            return nil, fmt.Errorf("sink %q: invalid Jira URL", spec)
+        }
+        q := u.Query()
+        if q.Get("project") == "" {
+            return nil, fmt.Errorf("sink %q: missing project parameter", spec)
+        }
+        u.RawQuery = ""
+        js := sinks.NewJiraSink(u.String(), q.Get("project"), s.cfg.JiraEmail, s.cfg.JiraToken)
+        if it := q.Get("issue_type"); it != "" {
+            js.IssueType = it
+        }
+        js.ResolveTransition = q.Get("resolve")
+        js.DedupField = q.Get("field")
+        s.jiras = append(s.jiras, js)
+        return js, nil
+    default:
+        return nil, fmt.Errorf("unknown sink %q", spec)
+    }
+}
+
+// handleArtifact serves GET /artifacts/[<tenant>/]<name>.  Artifacts of
+// other tenants are reported as missing.
+func (s *Server) handleArtifact(w http.ResponseWriter, r *http.Request) {
+    if s.evidence == nil {
+        http.NotFound(w, r)
+        return
+    }
+    name := strings.TrimPrefix(r.URL.Path, "/artifacts/")
+    owner := DefaultTenant
+    if i := strings.IndexByte(name, '/'); i >= 0 {
+        owner, name = name[:i], name[i+1:]
+    }
+    if p := PrincipalFromContext(r.Context()); p == nil || p.Tenant != owner {
+        http.NotFound(w, r)
+        return
+    }
+    store := s.evidence
+    if owner != DefaultTenant {
+        var err error
+        if store, err = s.evidence.ForTenant(owner); err != nil {
+            http.NotFound(w, r)
+            return
+        }
+    }
+    f, err := store.Open(name)
+    if err != nil {
+        http.NotFound(w, r)
+        return
+    }
+    defer f.Close()
+    st, err := f.Stat()
+    if err != nil {
+        http.NotFound(w, r)
+        return
+    }
+    w.Header().Set("Content-Type", "application/gzip")
+    w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+    http.ServeContent(w, r, name, st.ModTime(), f)
+}
@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/Voskan/flarego/internal/gateway/alerts"
+)
+
+func TestBuildSinkSharesWebhooks(t *testing.T) {
+	s, err := New(Config{
+		WebhookURL: "http://127.0.0.1:1/default",
+		AlertRules: []alerts.RuleSpec{
+			{Name: "a", Expr: "blocked_goroutines > 1", Sinks: []string{"webhook:http://127.0.0.1:1/ops", "webhook"}},
+			{Name: "b", Expr: "heap_bytes > 1", Sinks: []string{"webhook:http://127.0.0.1:1/ops", "webhook:http://127.0.0.1:1/default"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, wh := range s.webhooks {
+			_ = wh.Close()
+		}
+	}()
+
+	if len(s.webhooks) != 2 {
+		t.Fatalf("Expected one sink per endpoint, got %d", len(s.webhooks))
+	}
+	a, b := s.rules[0].rule.Sinks, s.rules[1].rule.Sinks
+	if a[0] != b[0] {
+		t.Error("Expected rules naming the same URL to share a sink")
+	}
+	if a[1] != s.webhooks[0] || b[1] != s.webhooks[0] {
+		t.Error("Expected bare and explicit default URL to use the gateway sink")
+	}
+	if _, err := (&Server{}).buildSink("webhook"); err == nil {
+		t.Error("Expected error for bare webhook without a gateway URL")
+	}
+}
+
+func TestBuildSinkTracksJira(t *testing.T) {
+	s, err := New(Config{
+		AlertRules: []alerts.RuleSpec{
+			{Name: "a", Expr: "heap_bytes > 1", Sinks: []string{"jira:https://jira.example.com?project=OPS"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.jiras) != 1 || s.rules[0].rule.Sinks[0] != s.jiras[0] {
+		t.Fatalf("Expected the rule's Jira sink to be closed on shutdown, got %v", s.jiras)
+	}
+}
@@ -0,0 +1,299 @@
+// internal/gateway/alerts/engine.go
+// Rule engine.  The gateway feeds every decoded flamegraph snapshot to
+// Engine.Observe; each rule's expression (internal/alertsengine syntax) is
+// evaluated against metrics derived from the snapshot's pseudo‑stacks (see
+// Metrics).  A rule fires once its expression has held for the rule's For
+// duration and resolves on the first snapshot where it no longer holds.
+//
+// On firing the engine captures evidence when an EvidenceStore is
+// configured: the triggering snapshot, and the diff between that snapshot and
+// the average of the snapshots observed during BaselineWindow before the rule
+// started matching.  Both are saved as .fgo artifacts and their URLs, plus a
+// short summary of the frames that grew the most, travel with the Alert to
+// every sink.
+//
+// Evaluation is serialised by one mutex; artifact writes happen under it as
+// well, which is acceptable because firings are rare compared to snapshots.
+package alerts
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Voskan/flarego/internal/alertsengine"
+	"github.com/Voskan/flarego/internal/logging"
+	"github.com/Voskan/flarego/internal/util"
+	"github.com/Voskan/flarego/pkg/flamegraph"
+	"go.uber.org/zap"
+)
+
+// RuleSpec is the declarative form of a rule as found in configuration
+// files.  Sinks are spec strings resolved by the gateway, e.g. "log" or
+// "slack:https://hooks.slack.com/...".
+type RuleSpec struct {
+    Name   string            `mapstructure:"name" json:"name"`
+    Expr   string            `mapstructure:"expr" json:"expr"`
+    For    time.Duration     `mapstructure:"for" json:"for"`
+    Labels map[string]string `mapstructure:"labels" json:"labels,omitempty"`
+    Sinks  []string          `mapstructure:"sinks" json:"sinks,omitempty"`
+    Tenant string            `mapstructure:"tenant" json:"tenant,omitempty"` // "" applies to every tenant
+}
+
+// Rule is a compiled, ready‑to‑evaluate rule.
+type Rule struct {
+    Name   string
+    Expr   string
+    For    time.Duration
+    Labels map[string]string
+    Sinks  []Sink
+}
+
+// EngineConfig tunes evidence capture.
+type EngineConfig struct {
+    Evidence       *EvidenceStore // nil disables evidence capture
+    BaselineWindow time.Duration  // history compared against on fire; default 1m
+    TopN           int            // frames listed in Alert.Summary; default 5
+    Tenant         string         // stamped on every Alert
+}
+
+// Engine evaluates rules against incoming snapshots.  Safe for concurrent use.
+type Engine struct {
+    cfg EngineConfig
+
+    mu      sync.Mutex
+    rules   []*ruleState
+    history []snapshot // oldest first, trimmed to horizon
+    horizon time.Duration
+}
+
+type ruleState struct {
+    Rule
+    pred         alertsengine.Predicate
+    pendingSince time.Time
+    active       *Alert
+}
+
+type snapshot struct {
+    ts   time.Time
+    root *flamegraph.Frame
+}
+
+// NewEngine compiles rules and returns an engine.
+func NewEngine(cfg EngineConfig, rules []Rule) (*Engine, error) {
+    if cfg.BaselineWindow <= 0 {
+        cfg.BaselineWindow = time.Minute
+    }
+    if cfg.TopN <= 0 {
+        cfg.TopN = 5
+    }
+    e := &Engine{cfg: cfg, horizon: cfg.BaselineWindow}
+    for _, r := range rules {
+        pred, err := alertsengine.Compile(r.Expr)
+        if err != nil {
+            return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+        }
+        if r.For > 0 && cfg.BaselineWindow+r.For > e.horizon {
+            e.horizon = cfg.BaselineWindow + r.For
+        }
+        e.rules = append(e.rules, &ruleState{Rule: r, pred: pred})
+    }
+    return e, nil
+}
+
+// Metrics derives the metric map rules are evaluated against from the
+// top‑level pseudo‑stacks of a snapshot:
+//
+//	blocked_goroutines – weight of "(Blocked)"
+//	heap_bytes         – weight of "(Heap)" (signed heap delta)
+//	gc_pause_ns        – weight of "(GC)"
+//	total_goroutines   – weight of every other top‑level frame
+func Metrics(root *flamegraph.Frame) map[string]float64 {
+    m := map[string]float64{
+        "blocked_goroutines": 0,
+        "heap_bytes":         0,
+        "gc_pause_ns":        0,
+        "total_goroutines":   0,
+    }
+    if root == nil {
+        return m
+    }
+    for name, c := range root.Children {
+        switch name {
+        case "(Blocked)":
+            m["blocked_goroutines"] += float64(c.Value)
+        case "(Heap)":
+            m["heap_bytes"] += float64(c.Value)
+        case "(GC)":
+            m["gc_pause_ns"] += float64(c.Value)
+        default:
+            m["total_goroutines"] += float64(c.Value)
+        }
+    }
+    return m
+}
+
+// Observe evaluates all rules against root received now.
+func (e *Engine) Observe(root *flamegraph.Frame) { e.ObserveAt(time.Now(), root) }
+
+// ObserveAt evaluates all rules against root received at ts.  Snapshots must
+// be observed in non‑decreasing ts order.
+func (e *Engine) ObserveAt(ts time.Time, root *flamegraph.Frame) {
+    if root == nil {
+        return
+    }
+    m := Metrics(root)
+
+    type dispatch struct {
+        sinks   []Sink
+        alert   Alert
+        resolve bool
+    }
+    var out []dispatch
+
+    e.mu.Lock()
+    for _, r := range e.rules {
+        if !r.pred(m) {
+            r.pendingSince = time.Time{}
+            if r.active != nil {
+                a := *r.active
+                a.Resolved = ts
+                r.active = nil
+                out = append(out, dispatch{sinks: r.Sinks, alert: a, resolve: true})
+            }
+            continue
+        }
+        if r.pendingSince.IsZero() {
+            r.pendingSince = ts
+        }
+        if r.active != nil || ts.Sub(r.pendingSince) < r.For {
+            continue
+        }
+        a := Alert{
+            Rule:    r.Name,
+            Tenant:  e.cfg.Tenant,
+            Labels:  r.Labels,
+            Msg:     describe(r, m),
+            FiredAt: ts,
+        }
+        e.captureEvidence(&a, root, r.pendingSince)
+        r.active = &a
+        out = append(out, dispatch{sinks: r.Sinks, alert: a})
+    }
+    e.history = append(e.history, snapshot{ts: ts, root: root})
+    cutoff := ts.Add(-e.horizon)
+    for len(e.history) > 0 && e.history[0].ts.Before(cutoff) {
+        e.history[0] = snapshot{}
+        e.history = e.history[1:]
+    }
+    e.mu.Unlock()
+
+    for _, d := range out {
+        for _, s := range d.sinks {
+            ls, lifecycle := s.(LifecycleSink)
+            switch {
+            case lifecycle && d.resolve:
+                ls.Resolve(d.alert)
+            case lifecycle:
+                ls.Fire(d.alert)
+            case !d.resolve:
+                s.Notify(d.alert.Rule, d.alert.Text())
+            }
+        }
+    }
+}
+
+// Active returns the currently firing alerts.
+func (e *Engine) Active() []Alert {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    var res []Alert
+    for _, r := range e.rules {
+        if r.active != nil {
+            res = append(res, *r.active)
+        }
+    }
+    return res
+}
+
+// captureEvidence saves the snapshot and baseline diff for a; must hold e.mu.
+func (e *Engine) captureEvidence(a *Alert, root *flamegraph.Frame, since time.Time) {
+    if e.cfg.Evidence == nil {
+        return
+    }
+    id, err := util.New()
+    if err != nil {
+        logging.Logger().Warn("alert evidence id", zap.Error(err))
+        return
+    }
+    snapName := id + "-snapshot.fgo"
+    if err := e.cfg.Evidence.Save(snapName, root); err != nil {
+        logging.Logger().Warn("alert evidence snapshot", zap.String("rule", a.Rule), zap.Error(err))
+        return
+    }
+    a.EvidenceURL = e.cfg.Evidence.URL(snapName)
+
+    from := since.Add(-e.cfg.BaselineWindow)
+    var base []*flamegraph.Frame
+    for _, s := range e.history {
+        if !s.ts.Before(from) && s.ts.Before(since) {
+            base = append(base, s.root)
+        }
+    }
+    if len(base) == 0 {
+        a.Summary = "No baseline snapshots before the alert; diff unavailable."
+        return
+    }
+    diff := flamegraph.Diff(root, average(root.Name, base))
+    diffName := id + "-diff.fgo"
+    if err := e.cfg.Evidence.Save(diffName, diff); err != nil {
+        logging.Logger().Warn("alert evidence diff", zap.String("rule", a.Rule), zap.Error(err))
+        return
+    }
+    a.DiffURL = e.cfg.Evidence.URL(diffName)
+    a.Summary = growthSummary(diff, e.cfg.TopN, len(base))
+}
+
+// describe renders the default alert message.
+func describe(r *ruleState, m map[string]float64) string {
+    msg := fmt.Sprintf("%s: %s", r.Name, r.Expr)
+    if r.For > 0 {
+        msg += fmt.Sprintf(" for %s", r.For)
+    }
+    return fmt.Sprintf("%s (blocked=%.0f heap=%.0f gc_ns=%.0f goroutines=%.0f)", msg,
+        m["blocked_goroutines"], m["heap_bytes"], m["gc_pause_ns"], m["total_goroutines"])
+}
+
+// growthSummary lists the frames whose self weight grew the most.
+func growthSummary(diff *flamegraph.Frame, n, baseCount int) string {
+    grew, _ := flamegraph.TopChanges(diff, n)
+    if len(grew) == 0 {
+        return fmt.Sprintf("No frame grew versus the baseline (%d snapshots).", baseCount)
+    }
+    var b strings.Builder
+    fmt.Fprintf(&b, "Top growth vs baseline (%d snapshots):", baseCount)
+    for _, c := range grew {
+        fmt.Fprintf(&b, "\n  +%d %s", c.Delta, c.Name)
+    }
+    return b.String()
+}
+
+// average merges frames and divides every value by len(frames) so a window
+// of snapshots is comparable to a single one.
+func average(rootName string, frames []*flamegraph.Frame) *flamegraph.Frame {
+    sum := flamegraph.New(rootName)
+    for _, f := range frames {
+        sum.Merge(f)
+    }
+    n := int64(len(frames))
+    var scale func(*flamegraph.Frame)
+    scale = func(f *flamegraph.Frame) {
+        f.Value /= n
+        for _, c := range f.Children {
+            scale(c)
+        }
+    }
+    scale(sum)
+    return sum
+}
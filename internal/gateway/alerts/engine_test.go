@@ -0,0 +1,185 @@
+package alerts
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+// recordingSink is a LifecycleSink remembering what it was told.
+type recordingSink struct {
+	mu       sync.Mutex
+	fired    []Alert
+	resolved []Alert
+}
+
+func (s *recordingSink) Notify(rule, msg string) {}
+
+func (s *recordingSink) Fire(a Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fired = append(s.fired, a)
+}
+
+func (s *recordingSink) Resolve(a Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resolved = append(s.resolved, a)
+}
+
+// plainSink only implements Sink.
+type plainSink struct {
+	mu       sync.Mutex
+	notified []string
+}
+
+func (s *plainSink) Notify(rule, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notified = append(s.notified, msg)
+}
+
+func snap(blocked int64, work map[string]int64) *flamegraph.Frame {
+	root := flamegraph.New("root")
+	root.AddSample([]string{"(Blocked)"}, blocked)
+	for name, w := range work {
+		root.AddSample([]string{"main", name}, w)
+	}
+	return root
+}
+
+func TestEngine_FireResolveWithEvidence(t *testing.T) {
+	ev, err := NewEvidenceStore(t.TempDir(), "https://fg.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordingSink{}
+	plain := &plainSink{}
+	e, err := NewEngine(EngineConfig{Evidence: ev, BaselineWindow: 10 * time.Second, TopN: 2, Tenant: "team-a"}, []Rule{{
+		Name:  "blocked",
+		Expr:  "blocked_goroutines > 100",
+		For:   2 * time.Second,
+		Sinks: []Sink{rec, plain},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t0 := time.Unix(1700000000, 0)
+	at := func(sec int) time.Time { return t0.Add(time.Duration(sec) * time.Second) }
+	for i := 0; i < 3; i++ {
+		e.ObserveAt(at(i), snap(10, map[string]int64{"work": 100, "io": 50}))
+	}
+	hot := snap(200, map[string]int64{"work": 100, "lock": 400})
+	e.ObserveAt(at(3), hot)
+	e.ObserveAt(at(4), hot)
+	if len(rec.fired) != 0 {
+		t.Fatalf("Expected no firing before For elapsed, got %+v", rec.fired)
+	}
+	e.ObserveAt(at(5), hot)
+	e.ObserveAt(at(6), hot)
+	if len(rec.fired) != 1 {
+		t.Fatalf("Expected exactly one firing, got %d", len(rec.fired))
+	}
+	if len(plain.notified) != 1 || !strings.Contains(plain.notified[0], "Diff vs baseline: ") {
+		t.Errorf("Expected plain sink to get the rendered text, got %q", plain.notified)
+	}
+
+	a := rec.fired[0]
+	if a.Rule != "blocked" || a.Tenant != "team-a" || !a.FiredAt.Equal(at(5)) {
+		t.Errorf("Unexpected alert %+v", a)
+	}
+	if !strings.HasPrefix(a.EvidenceURL, "https://fg.example/artifacts/") || !strings.HasSuffix(a.DiffURL, "-diff.fgo") {
+		t.Errorf("Unexpected evidence links %q %q", a.EvidenceURL, a.DiffURL)
+	}
+	if !strings.HasPrefix(a.Summary, "Top growth vs baseline (3 snapshots):\n  +400 lock\n  +190 (Blocked)") {
+		t.Errorf("Unexpected summary %q", a.Summary)
+	}
+	if got := len(e.Active()); got != 1 {
+		t.Errorf("Expected 1 active alert, got %d", got)
+	}
+
+	// The saved diff is the firing snapshot minus the averaged baseline.
+	f, err := ev.Open(path.Base(a.DiffURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diff flamegraph.Frame
+	if err := json.NewDecoder(zr).Decode(&diff); err != nil {
+		t.Fatal(err)
+	}
+	main := diff.Children["main"]
+	if main == nil || main.Children["lock"] == nil || main.Children["lock"].Value != 400 || main.Children["io"].Value != -50 || main.Children["work"] != nil {
+		t.Errorf("Unexpected diff %+v", main)
+	}
+
+	e.ObserveAt(at(7), snap(5, nil))
+	if len(rec.resolved) != 1 || !rec.resolved[0].Resolved.Equal(at(7)) || rec.resolved[0].DiffURL != a.DiffURL {
+		t.Errorf("Expected one resolution carrying the evidence, got %+v", rec.resolved)
+	}
+	if len(plain.notified) != 1 {
+		t.Errorf("Expected plain sinks not to be told about resolution, got %d messages", len(plain.notified))
+	}
+	if got := len(e.Active()); got != 0 {
+		t.Errorf("Expected no active alert, got %d", got)
+	}
+}
+
+func TestEngine_NoBaseline(t *testing.T) {
+	ev, _ := NewEvidenceStore(t.TempDir(), "")
+	rec := &recordingSink{}
+	e, err := NewEngine(EngineConfig{Evidence: ev}, []Rule{{Name: "b", Expr: "blocked_goroutines > 1", Sinks: []Sink{rec}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Observe(snap(5, nil))
+	if len(rec.fired) != 1 {
+		t.Fatalf("Expected immediate firing without For, got %d", len(rec.fired))
+	}
+	a := rec.fired[0]
+	if !strings.HasPrefix(a.EvidenceURL, "/artifacts/") || a.DiffURL != "" || !strings.Contains(a.Summary, "No baseline") {
+		t.Errorf("Expected snapshot only without baseline, got %+v", a)
+	}
+	if _, err := NewEngine(EngineConfig{}, []Rule{{Name: "bad", Expr: "blocked_goroutines >"}}); err == nil {
+		t.Error("Expected compile error for a malformed expression")
+	}
+}
+
+func TestEvidenceStore_Tenants(t *testing.T) {
+	ev, err := NewEvidenceStore(t.TempDir(), "https://fg.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	team, err := ev.ForTenant("team-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := team.URL("x.fgo"); got != "https://fg.example/artifacts/team-a/x.fgo" {
+		t.Errorf("Unexpected tenant URL %q", got)
+	}
+	if err := team.Save("x.fgo", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ev.Open("x.fgo"); err == nil {
+		t.Error("Expected tenant artifact to be invisible at the top level")
+	}
+	for _, bad := range []string{"../x.fgo", ".hidden", "a/b.fgo", ""} {
+		if err := team.Save(bad, nil); err != ErrArtifactName {
+			t.Errorf("Expected ErrArtifactName for %q, got %v", bad, err)
+		}
+	}
+	if _, err := ev.ForTenant("../etc"); err != ErrArtifactName {
+		t.Errorf("Expected ErrArtifactName for tenant escape, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+// internal/gateway/alerts/evidence.go
+// Evidence storage for fired alerts.  When a rule fires the engine persists
+// the triggering flamegraph snapshot plus a diff against the baseline window
+// as `.fgo` artifacts (gzipped JSON Frame, the same format `flarego record`
+// writes) so the person paged can open them straight from the notification
+// with `flarego replay`, `flarego diff` or the web UI's drop zone.
+//
+// Artifacts are plain files in one directory; the gateway's HTTP listener
+// serves them under /artifacts/<name>.  ForTenant derives a store that keeps
+// a tenant's artifacts in <dir>/<tenant> served under /artifacts/<tenant>/.
+package alerts
+
+import (
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+// ErrArtifactName is returned for names that would escape the artifact dir.
+var ErrArtifactName = errors.New("invalid artifact name")
+
+// EvidenceStore writes .fgo artifacts to Dir and builds their public URLs.
+type EvidenceStore struct {
+    Dir     string // destination directory (created if missing)
+    BaseURL string // public gateway URL, e.g. https://flarego.example.com; "" yields relative links
+    Prefix  string // URL path segment between /artifacts/ and the name, e.g. "team-a/"
+}
+
+// NewEvidenceStore validates dir and returns a store.
+func NewEvidenceStore(dir, baseURL string) (*EvidenceStore, error) {
+    if dir == "" {
+        return nil, errors.New("evidence store: empty dir")
+    }
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, err
+    }
+    return &EvidenceStore{Dir: dir, BaseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+// ForTenant returns a store rooted at <Dir>/<tenant>.  tenant must be a bare
+// path component; "" returns s unchanged.
+func (s *EvidenceStore) ForTenant(tenant string) (*EvidenceStore, error) {
+    if tenant == "" {
+        return s, nil
+    }
+    dir, err := s.path(tenant)
+    if err != nil {
+        return nil, err
+    }
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, err
+    }
+    return &EvidenceStore{Dir: dir, BaseURL: s.BaseURL, Prefix: s.Prefix + tenant + "/"}, nil
+}
+
+// Save writes f as <name> (gzipped JSON).  name must be a bare file name.
+func (s *EvidenceStore) Save(name string, f *flamegraph.Frame) error {
+    path, err := s.path(name)
+    if err != nil {
+        return err
+    }
+    if f == nil {
+        f = flamegraph.New("root")
+    }
+    data, err := f.ToJSON()
+    if err != nil {
+        return err
+    }
+    out, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    defer out.Close()
+    gw := gzip.NewWriter(out)
+    if _, err := gw.Write(data); err != nil {
+        _ = gw.Close()
+        return err
+    }
+    return gw.Close()
+}
+
+// Open returns the raw artifact for serving.
+func (s *EvidenceStore) Open(name string) (*os.File, error) {
+    path, err := s.path(name)
+    if err != nil {
+        return nil, err
+    }
+    return os.Open(path)
+}
+
+// URL returns the link under which the gateway serves name.
+func (s *EvidenceStore) URL(name string) string {
+    return s.BaseURL + "/artifacts/" + s.Prefix + name
+}
+
+func (s *EvidenceStore) path(name string) (string, error) {
+    if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+        return "", ErrArtifactName
+    }
+    return filepath.Join(s.Dir, name), nil
+}
@@ -0,0 +1,93 @@
+// internal/gateway/alerts/sink.go
+// Notification contracts between the alert engine and its sinks.  Every sink
+// implements the one‑method Sink interface; sinks that track the state of an
+// incident outside FlareGo (ticket systems, paging tools) additionally
+// implement LifecycleSink so they are told when an alert clears and receive
+// the full structured Alert instead of a pre‑formatted message.
+package alerts
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sink receives a notification every time a rule fires.
+type Sink interface {
+    Notify(ruleName, msg string)
+}
+
+// Alert describes one firing (or clearing) of a rule.
+type Alert struct {
+    Rule     string            // rule name
+    Tenant   string            // owning tenant; "" in single‑tenant setups
+    Labels   map[string]string // identifying labels, e.g. service=api
+    Msg      string            // human‑readable description
+    FiredAt  time.Time         // first evaluation that satisfied the rule
+    Resolved time.Time         // zero while the alert is active
+
+    // Evidence captured by the engine when the rule fired (may be empty).
+    EvidenceURL string // triggering snapshot (.fgo)
+    DiffURL     string // snapshot minus baseline window (.fgo)
+    Summary     string // short text listing the frames that grew the most
+}
+
+// LifecycleSink is an optional extension of Sink.  The engine calls Fire
+// instead of Notify when a sink implements it, and Resolve once the rule
+// stops matching.
+type LifecycleSink interface {
+    Sink
+    Fire(a Alert)
+    Resolve(a Alert)
+}
+
+// Text renders the alert for sinks that only accept a plain message: Msg
+// followed by the evidence links and growth summary when present.
+func (a Alert) Text() string {
+    var b strings.Builder
+    b.WriteString(a.Msg)
+    if a.EvidenceURL != "" {
+        b.WriteString("\nSnapshot: ")
+        b.WriteString(a.EvidenceURL)
+    }
+    if a.DiffURL != "" {
+        b.WriteString("\nDiff vs baseline: ")
+        b.WriteString(a.DiffURL)
+    }
+    if a.Summary != "" {
+        b.WriteString("\n")
+        b.WriteString(a.Summary)
+    }
+    return b.String()
+}
+
+// Fingerprint returns a stable identity for the alert derived from the
+// tenant, rule name and sorted labels, e.g. "high-heap{env=prod,service=api}"
+// or "team-a/high-heap" when a tenant is set.
+func (a Alert) Fingerprint() string {
+    rule := a.Rule
+    if a.Tenant != "" {
+        rule = a.Tenant + "/" + a.Rule
+    }
+    if len(a.Labels) == 0 {
+        return rule
+    }
+    keys := make([]string, 0, len(a.Labels))
+    for k := range a.Labels {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    var b strings.Builder
+    b.WriteString(rule)
+    b.WriteByte('{')
+    for i, k := range keys {
+        if i > 0 {
+            b.WriteByte(',')
+        }
+        b.WriteString(k)
+        b.WriteByte('=')
+        b.WriteString(a.Labels[k])
+    }
+    b.WriteByte('}')
+    return b.String()
+}
@@ -1,33 +1,46 @@
 // internal/gateway/alerts/sinks/jira.go
-// Jira sink creates or comments on issues in Atlassian Jira whenever a FlareGo
-// alert fires.  The implementation talks to the Jira Cloud REST API v3 using
-// basic-auth with an API token (email + token) or OAuth bearer.  To avoid
-// spamming duplicates, the sink keeps an in‑memory LRU of recently‑created
-// issues keyed by alert rule name.
+// Jira sink creates, comments on and resolves issues in Atlassian Jira as a
+// FlareGo alert fires and clears.  The implementation talks to the Jira Cloud
+// REST API v3 using basic-auth with an API token (email + token) or OAuth
+// bearer.
 //
-// Caveats:
-//   - For brevity this sample covers only the “create issue” path; linking to
-//     existing open issues or adding comments can be added later.
-//   - In HA gateway setups each instance may race to create an issue; users
-//     should configure sticky routing or use an external deduplication layer.
+// Issue lifecycle:
+//   - Every alert maps to a deduplication key derived from its rule name and
+//     labels (see DedupKey).  The key is stored on the issue as a label, or in
+//     a custom field when DedupField is set.
+//   - On Fire the sink searches (JQL) for an unresolved issue carrying the
+//     key.  If one exists a comment is added; otherwise a new issue is opened.
+//     Because the state lives in Jira, restarts and HA replicas do not create
+//     duplicates (modulo a narrow create/create race between replicas).
+//   - On Resolve, when ResolveTransition is configured, the open issue is
+//     commented on and moved through the transition with that name (or the
+//     transition leading to a status with that name).
+//
+// Operations for one sink run off the caller's goroutine but strictly in
+// submission order, so a clear never overtakes the firing it belongs to.
 package sinks
 
 import (
 	"bytes"
-	"container/list"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Voskan/flarego/internal/gateway/alerts"
 	"github.com/Voskan/flarego/internal/logging"
 	"github.com/Voskan/flarego/internal/util"
 	"go.uber.org/zap"
 )
 
-// JiraSink posts a new issue for each unique alert rule.
+// JiraSink maintains one Jira issue per active alert.
 type JiraSink struct {
     BaseURL   string // e.g. https://your-domain.atlassian.net
     Project   string // project key, e.g. FLR
@@ -37,17 +50,24 @@ type JiraSink struct {
     APIToken  string // for basic auth (preferred for Cloud)
     Bearer    string // alternative OAuth token
 
+    // DedupField optionally names a custom field (e.g. "customfield_10042")
+    // that stores the dedup key instead of a label.
+    DedupField string
+    // ResolveTransition is the transition (or target status) name applied
+    // when an alert clears, e.g. "Resolved" or "Done".  Empty leaves issues
+    // open.
+    ResolveTransition string
+
     Timeout    time.Duration // HTTP timeout, default 8 s
     MaxRetries int           // attempts on failure, default 3
 
-    // dedup LRU
-    mu  sync.Mutex
-    lru *list.List // list of rule names, newest front
-    set map[string]*list.Element
-    cap int
+    client *http.Client
+    mu     sync.Mutex     // guards tail
+    tail   chan struct{}  // closed when the last submitted operation ends
+    wg     sync.WaitGroup // in‑flight operations
 }
 
-// NewJiraSink builds a sink with 128‑entry dedup cache.
+// NewJiraSink builds a sink with defaults.
 func NewJiraSink(baseURL, project, email, token string) *JiraSink {
     return &JiraSink{
         BaseURL:    baseURL,
@@ -57,89 +77,304 @@ func NewJiraSink(baseURL, project, email, token string) *JiraSink {
         APIToken:   token,
         Timeout:    8 * time.Second,
         MaxRetries: 3,
-        lru:        list.New(),
-        set:        make(map[string]*list.Element),
-        cap:        128,
     }
 }
 
-// Notify implements alerts.Sink.
+// Notify implements alerts.Sink for callers without structured alerts.
 func (s *JiraSink) Notify(rule, msg string) {
-    if s.BaseURL == "" || s.Project == "" {
-        logging.Sugar().Warn("jira sink missing BaseURL or Project; skipping")
+    s.Fire(alerts.Alert{Rule: rule, Msg: msg, FiredAt: time.Now()})
+}
+
+// Fire implements alerts.LifecycleSink: comment on the open issue for the
+// alert or open a new one.
+func (s *JiraSink) Fire(a alerts.Alert) {
+    s.async(a, s.fire)
+}
+
+// Resolve implements alerts.LifecycleSink: transition the open issue when
+// ResolveTransition is configured.
+func (s *JiraSink) Resolve(a alerts.Alert) {
+    if s.ResolveTransition == "" {
         return
     }
+    s.async(a, s.resolve)
+}
 
-    s.mu.Lock()
-    if el, ok := s.set[rule]; ok {
-        // already seen recently; move to front and skip creation
-        s.lru.MoveToFront(el)
-        s.mu.Unlock()
+// Close waits for in‑flight Jira calls to finish.
+func (s *JiraSink) Close() error {
+    s.wg.Wait()
+    return nil
+}
+
+// DedupKey returns the Jira‑safe label identifying a: "flarego-<rule>-<hash>"
+// where the hash covers the alert's fingerprint (tenant, raw rule name and
+// labels).  Jira labels may not contain whitespace, so the rule name is
+// slugified; the hash keeps rules that slugify alike ("a b", "a_b") and the
+// same rule of different tenants apart.
+func DedupKey(a alerts.Alert) string {
+    var b strings.Builder
+    b.WriteString("flarego-")
+    for _, r := range strings.ToLower(a.Rule) {
+        switch {
+        case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+            b.WriteRune(r)
+        default:
+            b.WriteByte('_')
+        }
+    }
+    fmt.Fprintf(&b, "-%08x", fnv32(a.Fingerprint()))
+    return b.String()
+}
+
+//--------------------------------------------------------------------
+// lifecycle
+//--------------------------------------------------------------------
+
+func (s *JiraSink) async(a alerts.Alert, fn func(alerts.Alert) error) {
+    if s.BaseURL == "" || s.Project == "" {
+        logging.Sugar().Warn("jira sink missing BaseURL or Project; skipping")
         return
     }
+    done := make(chan struct{})
+    s.mu.Lock()
+    prev := s.tail
+    s.tail = done
     s.mu.Unlock()
 
-    go s.createIssue(rule, msg)
+    s.wg.Add(1)
+    go func() {
+        defer s.wg.Done()
+        defer close(done)
+        if prev != nil {
+            <-prev
+        }
+        if err := fn(a); err != nil {
+            logging.Logger().Warn("jira sink", zap.String("rule", a.Rule), zap.Error(err))
+        }
+    }()
 }
 
-func (s *JiraSink) createIssue(rule, msg string) {
-    payload := map[string]any{
-        "fields": map[string]any{
-            "project": map[string]string{"key": s.Project},
-            "summary": "FlareGo alert – " + rule,
-            "description": msg,
-            "issuetype": map[string]string{"name": s.IssueType},
-        },
+func (s *JiraSink) fire(a alerts.Alert) error {
+    key := DedupKey(a)
+    issue, err := s.findOpenIssue(key)
+    if err != nil {
+        return err
     }
-    body, _ := json.Marshal(payload)
+    if issue != "" {
+        return s.comment(issue, "Alert fired again: "+a.Text())
+    }
+    return s.createIssue(a, key)
+}
 
-    client := &http.Client{Timeout: s.Timeout}
-    backoff := util.NewBackoff()
+func (s *JiraSink) resolve(a alerts.Alert) error {
+    issue, err := s.findOpenIssue(DedupKey(a))
+    if err != nil || issue == "" {
+        return err
+    }
+    if err := s.comment(issue, "Alert cleared: "+a.Rule); err != nil {
+        return err
+    }
+    return s.transition(issue, s.ResolveTransition)
+}
 
-    url := s.BaseURL + "/rest/api/3/issue"
-    for attempt := 1; attempt <= s.MaxRetries; attempt++ {
-        ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
-        req, _ := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
-        req.Header.Set("Content-Type", "application/json")
-        if s.Bearer != "" {
-            req.Header.Set("Authorization", "Bearer "+s.Bearer)
-        } else {
-            token := base64.StdEncoding.EncodeToString([]byte(s.Email + ":" + s.APIToken))
-            req.Header.Set("Authorization", "Basic "+token)
-        }
+//--------------------------------------------------------------------
+// REST calls
+//--------------------------------------------------------------------
+
+// findOpenIssue returns the key of an unresolved issue carrying dedup key, or
+// "" when none exists.  Custom text fields only support the contains
+// operator (~) in JQL, so in DedupField mode the candidates are fetched with
+// their field value and matched exactly here.
+func (s *JiraSink) findOpenIssue(key string) (string, error) {
+    cond, fields, max := fmt.Sprintf("labels = %q", key), "status", "1"
+    if s.DedupField != "" {
+        cond = fmt.Sprintf("%s ~ %q", jqlField(s.DedupField), `"`+key+`"`)
+        fields, max = "status,"+s.DedupField, "50"
+    }
+    jql := fmt.Sprintf(`project = %q AND %s AND statusCategory != Done ORDER BY created DESC`, s.Project, cond)
+    q := url.Values{}
+    q.Set("jql", jql)
+    q.Set("fields", fields)
+    q.Set("maxResults", max)
 
-        resp, err := client.Do(req)
-        cancel()
-        if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
-            _ = resp.Body.Close()
-            s.updateLRU(rule)
-            return
+    var res struct {
+        Issues []struct {
+            Key    string                     `json:"key"`
+            Fields map[string]json.RawMessage `json:"fields"`
+        } `json:"issues"`
+    }
+    if err := s.do(http.MethodGet, "/rest/api/3/search/jql?"+q.Encode(), nil, &res); err != nil {
+        return "", err
+    }
+    for _, is := range res.Issues {
+        if s.DedupField == "" {
+            return is.Key, nil
         }
-        if err == nil {
-            _ = resp.Body.Close()
+        var v string
+        if json.Unmarshal(is.Fields[s.DedupField], &v) == nil && v == key {
+            return is.Key, nil
         }
-        logging.Logger().Warn("jira create issue failed", zap.String("rule", rule), zap.Int("attempt", attempt), zap.Error(err))
-        if attempt == s.MaxRetries {
-            break
+    }
+    return "", nil
+}
+
+func (s *JiraSink) createIssue(a alerts.Alert, key string) error {
+    fields := map[string]any{
+        "project":     map[string]string{"key": s.Project},
+        "summary":     "FlareGo alert – " + a.Rule,
+        "description": adfDoc(a.Text()),
+        "issuetype":   map[string]string{"name": s.IssueType},
+        "labels":      []string{"flarego"},
+    }
+    if s.DedupField != "" {
+        fields[s.DedupField] = key
+    } else {
+        fields["labels"] = []string{"flarego", key}
+    }
+    var res struct {
+        Key string `json:"key"`
+    }
+    if err := s.do(http.MethodPost, "/rest/api/3/issue", map[string]any{"fields": fields}, &res); err != nil {
+        return err
+    }
+    logging.Logger().Info("jira issue created", zap.String("rule", a.Rule), zap.String("issue", res.Key))
+    return nil
+}
+
+func (s *JiraSink) comment(issue, text string) error {
+    return s.do(http.MethodPost, "/rest/api/3/issue/"+url.PathEscape(issue)+"/comment", map[string]any{"body": adfDoc(text)}, nil)
+}
+
+// transition applies the transition whose name, or target status name,
+// matches want (case‑insensitive).
+func (s *JiraSink) transition(issue, want string) error {
+    path := "/rest/api/3/issue/" + url.PathEscape(issue) + "/transitions"
+    var res struct {
+        Transitions []struct {
+            ID   string `json:"id"`
+            Name string `json:"name"`
+            To   struct {
+                Name string `json:"name"`
+            } `json:"to"`
+        } `json:"transitions"`
+    }
+    if err := s.do(http.MethodGet, path, nil, &res); err != nil {
+        return err
+    }
+    for _, t := range res.Transitions {
+        if strings.EqualFold(t.Name, want) || strings.EqualFold(t.To.Name, want) {
+            return s.do(http.MethodPost, path, map[string]any{"transition": map[string]string{"id": t.ID}}, nil)
         }
-        time.Sleep(backoff.Next())
     }
+    return fmt.Errorf("issue %s has no transition %q", issue, want)
 }
 
-func (s *JiraSink) updateLRU(rule string) {
-    s.mu.Lock()
-    defer s.mu.Unlock()
-    if el, ok := s.set[rule]; ok {
-        s.lru.MoveToFront(el)
-        return
+// errJiraClient marks 4xx responses that must not be retried.
+var errJiraClient = errors.New("jira rejected request")
+
+// do performs one REST call with retry on network errors and 5xx responses.
+// body and out are JSON‑encoded/decoded when non‑nil.
+func (s *JiraSink) do(method, path string, body, out any) error {
+    var payload []byte
+    if body != nil {
+        var err error
+        if payload, err = json.Marshal(body); err != nil {
+            return err
+        }
+    }
+    if s.client == nil {
+        s.client = &http.Client{Timeout: s.Timeout}
     }
-    el := s.lru.PushFront(rule)
-    s.set[rule] = el
-    if s.lru.Len() > s.cap {
-        tail := s.lru.Back()
-        if tail != nil {
-            s.lru.Remove(tail)
-            delete(s.set, tail.Value.(string))
+    attempts := s.MaxRetries
+    if attempts <= 0 {
+        attempts = 1
+    }
+    backoff := util.NewBackoff()
+
+    var lastErr error
+    for attempt := 1; attempt <= attempts; attempt++ {
+        lastErr = s.doOnce(method, path, payload, out)
+        if lastErr == nil || errors.Is(lastErr, errJiraClient) {
+            return lastErr
+        }
+        logging.Logger().Warn("jira request failed", zap.String("method", method), zap.String("path", path), zap.Int("attempt", attempt), zap.Error(lastErr))
+        if attempt < attempts {
+            time.Sleep(backoff.Next())
         }
     }
+    return lastErr
+}
+
+func (s *JiraSink) doOnce(method, path string, payload []byte, out any) error {
+    timeout := s.Timeout
+    if timeout <= 0 {
+        timeout = 8 * time.Second
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+
+    req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(s.BaseURL, "/")+path, bytes.NewReader(payload))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Accept", "application/json")
+    if payload != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    if s.Bearer != "" {
+        req.Header.Set("Authorization", "Bearer "+s.Bearer)
+    } else {
+        token := base64.StdEncoding.EncodeToString([]byte(s.Email + ":" + s.APIToken))
+        req.Header.Set("Authorization", "Basic "+token)
+    }
+
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    switch {
+    case resp.StatusCode >= 500:
+        return fmt.Errorf("jira %s %s: %s", method, path, resp.Status)
+    case resp.StatusCode >= 300:
+        return fmt.Errorf("%w: %s %s: %s", errJiraClient, method, path, resp.Status)
+    }
+    if out == nil {
+        return nil
+    }
+    return json.NewDecoder(resp.Body).Decode(out)
+}
+
+//--------------------------------------------------------------------
+// helpers
+//--------------------------------------------------------------------
+
+// adfDoc wraps plain text in a minimal Atlassian Document Format body, which
+// API v3 requires for descriptions and comments.
+func adfDoc(text string) map[string]any {
+    return map[string]any{
+        "type":    "doc",
+        "version": 1,
+        "content": []any{
+            map[string]any{
+                "type":    "paragraph",
+                "content": []any{map[string]any{"type": "text", "text": text}},
+            },
+        },
+    }
+}
+
+func fnv32(s string) uint32 {
+    h := fnv.New32a()
+    _, _ = h.Write([]byte(s))
+    return h.Sum32()
+}
+
+// jqlField converts "customfield_10042" into the JQL reference cf[10042];
+// other names are quoted verbatim.
+func jqlField(field string) string {
+    if id, ok := strings.CutPrefix(field, "customfield_"); ok {
+        return "cf[" + id + "]"
+    }
+    return fmt.Sprintf("%q", field)
 }
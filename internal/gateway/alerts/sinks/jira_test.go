@@ -0,0 +1,234 @@
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Voskan/flarego/internal/gateway/alerts"
+)
+
+// fakeJira is a tiny in‑memory stand‑in for the Jira REST endpoints used by
+// JiraSink.
+type fakeJira struct {
+	mu       sync.Mutex
+	issues   map[string]*fakeIssue
+	searches []string
+}
+
+type fakeIssue struct {
+	labels   []string
+	field    string // customfield_10042
+	status   string
+	comments int
+}
+
+func newFakeJira() (*fakeJira, *httptest.Server) {
+	fj := &fakeJira{issues: make(map[string]*fakeIssue)}
+	return fj, httptest.NewServer(fj)
+}
+
+func (fj *fakeJira) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fj.mu.Lock()
+	defer fj.mu.Unlock()
+
+	path := r.URL.Path
+	switch {
+	case path == "/rest/api/3/search/jql":
+		jql := r.URL.Query().Get("jql")
+		fj.searches = append(fj.searches, jql)
+		var hits []map[string]any
+		for key, is := range fj.issues {
+			if is.status == "Done" {
+				continue
+			}
+			// Text search is fuzzy: any issue with the field set is a
+			// candidate, as tokenised matching would make it in Jira.
+			if strings.Contains(jql, "cf[10042] ~") && is.field != "" {
+				hits = append(hits, map[string]any{"key": key, "fields": map[string]string{"customfield_10042": is.field}})
+				continue
+			}
+			for _, l := range is.labels {
+				if strings.Contains(jql, fmt.Sprintf("labels = %q", l)) {
+					hits = append(hits, map[string]any{"key": key})
+				}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"issues": hits})
+
+	case path == "/rest/api/3/issue" && r.Method == http.MethodPost:
+		var req struct {
+			Fields struct {
+				Labels []string `json:"labels"`
+				Field  string   `json:"customfield_10042"`
+			} `json:"fields"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		key := fmt.Sprintf("FLR-%d", len(fj.issues)+1)
+		fj.issues[key] = &fakeIssue{labels: req.Fields.Labels, field: req.Fields.Field, status: "Open"}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"key": key})
+
+	case strings.HasSuffix(path, "/comment"):
+		key := strings.Split(path, "/")[5]
+		fj.issues[key].comments++
+		w.WriteHeader(http.StatusCreated)
+
+	case strings.HasSuffix(path, "/transitions"):
+		key := strings.Split(path, "/")[5]
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(map[string]any{"transitions": []map[string]any{
+				{"id": "11", "name": "Start", "to": map[string]string{"name": "In Progress"}},
+				{"id": "31", "name": "Close", "to": map[string]string{"name": "Resolved"}},
+			}})
+			return
+		}
+		var req struct {
+			Transition struct {
+				ID string `json:"id"`
+			} `json:"transition"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Transition.ID == "31" {
+			fj.issues[key].status = "Done"
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestJiraSink_Lifecycle(t *testing.T) {
+	fj, srv := newFakeJira()
+	defer srv.Close()
+
+	s := NewJiraSink(srv.URL, "FLR", "bot@example.com", "token")
+	s.ResolveTransition = "Resolved"
+	a := alerts.Alert{Rule: "high heap", Labels: map[string]string{"service": "api"}, Msg: "heap_bytes > 512MiB"}
+
+	s.Fire(a)
+	s.Fire(a) // re‑fire must comment, not duplicate
+	_ = s.Close()
+
+	if len(fj.issues) != 1 {
+		t.Fatalf("expected 1 issue, got %d", len(fj.issues))
+	}
+	is := fj.issues["FLR-1"]
+	if is.comments != 1 {
+		t.Errorf("expected 1 comment, got %d", is.comments)
+	}
+	if !strings.Contains(strings.Join(is.labels, ","), DedupKey(a)) {
+		t.Errorf("issue labels %v missing dedup key %s", is.labels, DedupKey(a))
+	}
+
+	s.Resolve(a)
+	_ = s.Close()
+	if is.status != "Done" {
+		t.Errorf("expected issue resolved, status %q", is.status)
+	}
+
+	// A new firing after resolution opens a fresh issue.
+	s.Fire(a)
+	_ = s.Close()
+	if len(fj.issues) != 2 {
+		t.Errorf("expected new issue after resolve, got %d issues", len(fj.issues))
+	}
+}
+
+func TestJiraSink_SurvivesRestart(t *testing.T) {
+	fj, srv := newFakeJira()
+	defer srv.Close()
+
+	a := alerts.Alert{Rule: "blocked", Msg: "blocked_goroutines > 150"}
+	first := NewJiraSink(srv.URL, "FLR", "", "")
+	first.Fire(a)
+	_ = first.Close()
+
+	// A fresh sink (no local state) must find the existing issue via JQL.
+	second := NewJiraSink(srv.URL, "FLR", "", "")
+	second.Fire(a)
+	_ = second.Close()
+
+	if len(fj.issues) != 1 {
+		t.Fatalf("expected 1 issue across restarts, got %d", len(fj.issues))
+	}
+	if fj.issues["FLR-1"].comments != 1 {
+		t.Errorf("expected re-fire comment, got %d", fj.issues["FLR-1"].comments)
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	a := alerts.Alert{Rule: "High Heap", Labels: map[string]string{"b": "2", "a": "1"}}
+	b := alerts.Alert{Rule: "High Heap", Labels: map[string]string{"a": "1", "b": "2"}}
+	if DedupKey(a) != DedupKey(b) {
+		t.Errorf("dedup key depends on label order: %s vs %s", DedupKey(a), DedupKey(b))
+	}
+	if strings.ContainsAny(DedupKey(a), " \t") {
+		t.Errorf("dedup key contains whitespace: %q", DedupKey(a))
+	}
+	if k := DedupKey(alerts.Alert{Rule: "x"}); !strings.HasPrefix(k, "flarego-x-") {
+		t.Errorf("unexpected key %q", k)
+	}
+	if DedupKey(alerts.Alert{Rule: "a b"}) == DedupKey(alerts.Alert{Rule: "a_b"}) {
+		t.Errorf("rules that slugify alike share a key: %s", DedupKey(alerts.Alert{Rule: "a b"}))
+	}
+}
+
+func TestJiraSink_TenantsDoNotShareIssues(t *testing.T) {
+	fj, srv := newFakeJira()
+	defer srv.Close()
+
+	// Same rule, no labels: only the tenant tells the alerts apart.
+	teamA := alerts.Alert{Rule: "blocked", Tenant: "team-a"}
+	teamB := alerts.Alert{Rule: "blocked", Tenant: "team-b"}
+	if DedupKey(teamA) == DedupKey(teamB) {
+		t.Fatalf("tenants share dedup key %s", DedupKey(teamA))
+	}
+
+	s := NewJiraSink(srv.URL, "FLR", "", "")
+	s.Fire(teamA)
+	s.Fire(teamB)
+	_ = s.Close()
+
+	if len(fj.issues) != 2 {
+		t.Fatalf("expected one issue per tenant, got %d", len(fj.issues))
+	}
+	for key, is := range fj.issues {
+		if is.comments != 0 {
+			t.Errorf("issue %s was commented on by the other tenant's alert", key)
+		}
+	}
+}
+
+func TestJiraSink_DedupField(t *testing.T) {
+	fj, srv := newFakeJira()
+	defer srv.Close()
+
+	s := NewJiraSink(srv.URL, "FLR", "", "")
+	s.DedupField = "customfield_10042"
+	heap := alerts.Alert{Rule: "heap"}
+	blocked := alerts.Alert{Rule: "blocked"}
+
+	s.Fire(heap)
+	s.Fire(blocked) // the fuzzy search returns heap's issue; it must not match
+	s.Fire(heap)
+	_ = s.Close()
+
+	if len(fj.issues) != 2 {
+		t.Fatalf("expected 2 issues, got %d", len(fj.issues))
+	}
+	if fj.issues["FLR-1"].field != DedupKey(heap) || fj.issues["FLR-1"].comments != 1 {
+		t.Errorf("expected heap re-fire to comment on its own issue, got %+v", fj.issues["FLR-1"])
+	}
+	if fj.issues["FLR-2"].comments != 0 {
+		t.Errorf("expected no comment on blocked issue, got %d", fj.issues["FLR-2"].comments)
+	}
+	if !strings.Contains(fj.searches[0], `cf[10042] ~ "\"`+DedupKey(heap)+`\""`) {
+		t.Errorf("unexpected JQL %s", fj.searches[0])
+	}
+}
@@ -0,0 +1,162 @@
+// internal/gateway/alerts/sinks/outbox.go
+// Persistent outbox used by sinks that must not lose notifications across
+// gateway restarts.  Every queued delivery is stored as one small JSON file:
+//
+//	<dir>/pending/<id>.json   – waiting for (re)delivery
+//	<dir>/dead/<id>.json      – gave up after MaxRetries; kept for inspection
+//
+// Files are written to a temporary name and renamed into place so a crash
+// mid‑write never leaves a truncated record behind.  IDs are ULIDs, therefore
+// lexical order equals creation order and Pending() returns oldest first.
+//
+// An Outbox with an empty dir keeps records in memory only; this preserves
+// the old behaviour for tests and throw‑away setups.
+package sinks
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delivery is one queued outbound request.
+type Delivery struct {
+    ID          string          `json:"id"`   // ULID; doubles as idempotency key
+    Rule        string          `json:"rule"` // alert rule that produced it
+    Tenant      string          `json:"tenant,omitempty"`
+    Body        json.RawMessage `json:"body"` // exact bytes that are POSTed and signed
+    Attempts    int             `json:"attempts"`
+    CreatedAt   time.Time       `json:"created_at"`
+    NextAttempt time.Time       `json:"next_attempt"`
+    LastError   string          `json:"last_error,omitempty"`
+}
+
+const (
+    outboxPending = "pending"
+    outboxDead    = "dead"
+)
+
+// Outbox stores deliveries on disk.  Safe for concurrent use.
+type Outbox struct {
+    dir string
+
+    mu  sync.Mutex
+    mem map[string]map[string]Delivery // in‑memory mode: bucket → id → delivery
+}
+
+// OpenOutbox prepares dir (creating sub‑directories as needed).  An empty dir
+// returns a memory‑only outbox.
+func OpenOutbox(dir string) (*Outbox, error) {
+    o := &Outbox{dir: dir}
+    if dir == "" {
+        o.mem = map[string]map[string]Delivery{
+            outboxPending: {},
+            outboxDead:    {},
+        }
+        return o, nil
+    }
+    for _, sub := range []string{outboxPending, outboxDead} {
+        if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+            return nil, err
+        }
+    }
+    return o, nil
+}
+
+// Put inserts or replaces a pending delivery.
+func (o *Outbox) Put(d Delivery) error { return o.write(outboxPending, d) }
+
+// Remove deletes a pending delivery after successful send.  Missing records
+// are not an error.
+func (o *Outbox) Remove(id string) error {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+    if o.mem != nil {
+        delete(o.mem[outboxPending], id)
+        return nil
+    }
+    err := os.Remove(o.path(outboxPending, id))
+    if errors.Is(err, os.ErrNotExist) {
+        return nil
+    }
+    return err
+}
+
+// Bury moves d from pending to the dead‑letter bucket.
+func (o *Outbox) Bury(d Delivery) error {
+    if err := o.write(outboxDead, d); err != nil {
+        return err
+    }
+    return o.Remove(d.ID)
+}
+
+// Pending lists deliveries awaiting send, oldest first.
+func (o *Outbox) Pending() ([]Delivery, error) { return o.list(outboxPending) }
+
+// Dead lists deliveries that exhausted their retries, oldest first.
+func (o *Outbox) Dead() ([]Delivery, error) { return o.list(outboxDead) }
+
+//--------------------------------------------------------------------
+// helpers
+//--------------------------------------------------------------------
+
+func (o *Outbox) path(bucket, id string) string {
+    return filepath.Join(o.dir, bucket, id+".json")
+}
+
+func (o *Outbox) write(bucket string, d Delivery) error {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+    if o.mem != nil {
+        o.mem[bucket][d.ID] = d
+        return nil
+    }
+    data, err := json.Marshal(d)
+    if err != nil {
+        return err
+    }
+    final := o.path(bucket, d.ID)
+    tmp := final + ".tmp"
+    if err := os.WriteFile(tmp, data, 0o600); err != nil {
+        return err
+    }
+    return os.Rename(tmp, final)
+}
+
+func (o *Outbox) list(bucket string) ([]Delivery, error) {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+
+    var out []Delivery
+    if o.mem != nil {
+        for _, d := range o.mem[bucket] {
+            out = append(out, d)
+        }
+    } else {
+        entries, err := os.ReadDir(filepath.Join(o.dir, bucket))
+        if err != nil {
+            return nil, err
+        }
+        for _, e := range entries {
+            if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+                continue // skips *.tmp leftovers as well
+            }
+            data, err := os.ReadFile(filepath.Join(o.dir, bucket, e.Name()))
+            if err != nil {
+                return nil, err
+            }
+            var d Delivery
+            if err := json.Unmarshal(data, &d); err != nil {
+                continue // corrupt record; leave it for manual inspection
+            }
+            out = append(out, d)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+    return out, nil
+}
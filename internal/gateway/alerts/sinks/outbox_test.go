@@ -0,0 +1,91 @@
+package sinks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutbox_ConcurrentPutRemove(t *testing.T) {
+	dir := t.TempDir()
+	ob, err := OpenOutbox(dir)
+	if err != nil {
+		t.Fatalf("open outbox: %v", err)
+	}
+
+	const workers, perWorker = 8, 24
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker*3)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				id := fmt.Sprintf("%02d-%03d", w, i)
+				if err := ob.Put(Delivery{ID: id, Body: []byte(`{}`), CreatedAt: time.Now()}); err != nil {
+					errs <- err
+				}
+				if _, err := ob.Pending(); err != nil {
+					errs <- err
+				}
+				if i%2 == 0 {
+					if err := ob.Remove(id); err != nil {
+						errs <- err
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	pending, err := ob.Pending()
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	if want := workers * perWorker / 2; len(pending) != want {
+		t.Errorf("expected %d pending deliveries, got %d", want, len(pending))
+	}
+	for i := 1; i < len(pending); i++ {
+		if pending[i-1].ID >= pending[i].ID {
+			t.Errorf("pending not in id order: %s before %s", pending[i-1].ID, pending[i].ID)
+		}
+	}
+	tmp, _ := filepath.Glob(filepath.Join(dir, outboxPending, "*.tmp"))
+	if len(tmp) != 0 {
+		t.Errorf("expected no temporary files left behind, got %v", tmp)
+	}
+}
+
+func TestOutbox_BuryAndCorruptRecords(t *testing.T) {
+	dir := t.TempDir()
+	ob, err := OpenOutbox(dir)
+	if err != nil {
+		t.Fatalf("open outbox: %v", err)
+	}
+	d := Delivery{ID: "01", Body: []byte(`{"a":1}`), Attempts: 3}
+	if err := ob.Put(d); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := ob.Bury(d); err != nil {
+		t.Fatalf("bury: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, outboxPending, "02.json"), []byte("{trunc"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, _ := ob.Pending()
+	dead, _ := ob.Dead()
+	if len(pending) != 0 {
+		t.Errorf("expected corrupt record to be skipped, got %+v", pending)
+	}
+	if len(dead) != 1 || dead[0].ID != "01" || dead[0].Attempts != 3 {
+		t.Errorf("expected buried delivery in dead bucket, got %+v", dead)
+	}
+}
@@ -3,74 +3,328 @@
 // time an alert fires.  It is often used to integrate FlareGo alerts with chat
 // bots, incident managers (PagerDuty, Opsgenie) or custom automation.
 //
-// The sink is synchronous and retries on transient failures with a configurable
-// back‑off (leveraging internal/util/backoff).  To avoid blocking the alert
-// engine, the Notify() method off‑loads network operations to a goroutine.
+// Deliveries are durable: Notify() writes the request to an Outbox (see
+// outbox.go) and returns; a single tracked worker goroutine drains the outbox
+// and retries failures with exponential back‑off (internal/util/backoff).
+// Because the back‑off schedule is persisted with each record, retries resume
+// where they left off after a gateway restart.  Deliveries that exhaust
+// MaxRetries are moved to the dead‑letter bucket and exposed via
+// DeadLetters() for the admin API.
+//
+// Every request carries the following headers so receivers can authenticate
+// and de‑duplicate:
+//
+//	X-FlareGo-Delivery   – ULID, stable across retries (also Idempotency-Key)
+//	X-FlareGo-Timestamp  – unix seconds at send time
+//	X-FlareGo-Signature  – "sha256=" + hex(HMAC‑SHA256(secret, ts + "." + body))
+//
+// The signature header is omitted when no Secret is configured.  Receivers
+// written in Go can use VerifyWebhookSignature.
 package sinks
 
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/Voskan/flarego/internal/gateway/alerts"
 	"github.com/Voskan/flarego/internal/logging"
 	"github.com/Voskan/flarego/internal/util"
 	"go.uber.org/zap"
 )
 
-// WebhookSink posts {rule:"<rule>", msg:"<msg>", ts:<unix>} JSON to URL.
+// Header names set on every webhook request.
+const (
+    HeaderWebhookDelivery  = "X-FlareGo-Delivery"
+    HeaderWebhookTimestamp = "X-FlareGo-Timestamp"
+    HeaderWebhookSignature = "X-FlareGo-Signature"
+)
+
+// WebhookSink posts {id:"<ulid>", rule:"<rule>", msg:"<msg>", ts:<unix>} JSON
+// to URL.  Structured alerts (Fire/Resolve) add status, tenant, labels,
+// fired_at, resolved_at, evidence_url, diff_url and summary.
 type WebhookSink struct {
-    URL       string
-    Timeout   time.Duration // per‑request timeout; default 5 s
-    MaxRetries int          // total attempts incl. first; default 5
+    URL        string
+    Secret     string        // HMAC key; empty disables signing
+    OutboxDir  string        // persistent outbox; empty keeps queue in memory
+    Timeout    time.Duration // per‑request timeout; default 5 s
+    MaxRetries int           // total attempts incl. first; default 8
+    Poll       time.Duration // outbox scan interval; default 1 s
+
+    outbox    *Outbox
+    client    *http.Client
+    startOnce sync.Once
+    startErr  error
+    wake      chan struct{}
+    quit      chan struct{}
+    closeOnce sync.Once
+    wg        sync.WaitGroup
 }
 
 // NewWebhookSink returns a sink with defaults.
 func NewWebhookSink(url string) *WebhookSink {
-    return &WebhookSink{URL: url, Timeout: 5 * time.Second, MaxRetries: 5}
+    return &WebhookSink{URL: url, Timeout: 5 * time.Second, MaxRetries: 8, Poll: time.Second}
+}
+
+// Start opens the outbox and launches the delivery worker.  It is called
+// implicitly by the first Notify; calling it explicitly surfaces outbox errors
+// at boot time.  Subsequent calls return the first result.
+func (s *WebhookSink) Start() error {
+    s.startOnce.Do(func() {
+        if s.Timeout <= 0 {
+            s.Timeout = 5 * time.Second
+        }
+        if s.MaxRetries <= 0 {
+            s.MaxRetries = 8
+        }
+        if s.Poll <= 0 {
+            s.Poll = time.Second
+        }
+        ob, err := OpenOutbox(s.OutboxDir)
+        if err != nil {
+            s.startErr = err
+            return
+        }
+        s.outbox = ob
+        s.client = &http.Client{Timeout: s.Timeout}
+        s.wake = make(chan struct{}, 1)
+        s.quit = make(chan struct{})
+        s.wg.Add(1)
+        go s.run()
+    })
+    return s.startErr
 }
 
-// Notify implements alerts.Sink (see alerts/engine.go).  It spawns a goroutine
-// so the caller returns immediately.
+// Close stops the worker and waits for an in‑flight request to finish.
+// Undelivered records stay in the outbox for the next start.
+func (s *WebhookSink) Close() error {
+    s.closeOnce.Do(func() {
+        if s.quit != nil {
+            close(s.quit)
+        }
+    })
+    s.wg.Wait()
+    return nil
+}
+
+// Notify implements alerts.Sink.  It only persists the delivery; the network
+// round‑trip happens on the worker goroutine so the caller returns immediately.
 func (s *WebhookSink) Notify(ruleName, msg string) {
+    s.enqueue(ruleName, "", map[string]any{
+        "rule": ruleName,
+        "msg":  msg,
+    })
+}
+
+// Fire implements alerts.LifecycleSink; the payload additionally carries
+// status "firing", labels and the evidence links captured by the engine.
+func (s *WebhookSink) Fire(a alerts.Alert) {
+    s.enqueue(a.Rule, a.Tenant, alertPayload(a, "firing"))
+}
+
+// Resolve implements alerts.LifecycleSink with status "resolved".
+func (s *WebhookSink) Resolve(a alerts.Alert) {
+    s.enqueue(a.Rule, a.Tenant, alertPayload(a, "resolved"))
+}
+
+func alertPayload(a alerts.Alert, status string) map[string]any {
+    p := map[string]any{
+        "rule":     a.Rule,
+        "msg":      a.Msg,
+        "status":   status,
+        "labels":   a.Labels,
+        "fired_at": a.FiredAt.Unix(),
+    }
+    if !a.Resolved.IsZero() {
+        p["resolved_at"] = a.Resolved.Unix()
+    }
+    if a.Tenant != "" {
+        p["tenant"] = a.Tenant
+    }
+    if a.EvidenceURL != "" {
+        p["evidence_url"] = a.EvidenceURL
+    }
+    if a.DiffURL != "" {
+        p["diff_url"] = a.DiffURL
+    }
+    if a.Summary != "" {
+        p["summary"] = a.Summary
+    }
+    return p
+}
+
+// enqueue stamps payload with id and ts, persists it and wakes the worker.
+func (s *WebhookSink) enqueue(ruleName, tenant string, payload map[string]any) {
     if s.URL == "" {
         logging.Sugar().Warn("webhook sink configured without URL")
         return
     }
-    go s.doPost(ruleName, msg)
-}
+    if err := s.Start(); err != nil {
+        logging.Logger().Warn("webhook outbox unavailable", zap.Error(err))
+        return
+    }
 
-func (s *WebhookSink) doPost(rule, msg string) {
-    payload := map[string]any{
-        "rule": rule,
-        "msg":  msg,
-        "ts":   time.Now().Unix(),
+    id, err := util.New()
+    if err != nil {
+        logging.Logger().Warn("webhook id", zap.Error(err))
+        return
     }
+    now := time.Now()
+    payload["id"] = id
+    payload["ts"] = now.Unix()
     body, _ := json.Marshal(payload)
+    d := Delivery{ID: id, Rule: ruleName, Tenant: tenant, Body: body, CreatedAt: now, NextAttempt: now}
+    if err := s.outbox.Put(d); err != nil {
+        logging.Logger().Warn("webhook enqueue failed", zap.String("rule", ruleName), zap.Error(err))
+        return
+    }
+    select {
+    case s.wake <- struct{}{}:
+    default:
+    }
+}
 
-    client := &http.Client{Timeout: s.Timeout}
-    backoff := util.NewBackoff()
+// DeadLetters returns deliveries that were given up on, oldest first.
+func (s *WebhookSink) DeadLetters() ([]Delivery, error) {
+    if s.outbox == nil {
+        return nil, nil
+    }
+    return s.outbox.Dead()
+}
+
+// run is the single delivery worker.
+func (s *WebhookSink) run() {
+    defer s.wg.Done()
 
-    for attempt := 1; attempt <= s.MaxRetries; attempt++ {
-        ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
-        req, _ := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
-        req.Header.Set("Content-Type", "application/json")
+    ticker := time.NewTicker(s.Poll)
+    defer ticker.Stop()
+    for {
+        s.drain()
+        select {
+        case <-s.quit:
+            return
+        case <-s.wake:
+        case <-ticker.C:
+        }
+    }
+}
 
-        resp, err := client.Do(req)
-        cancel()
-        if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
-            _ = resp.Body.Close()
+// drain attempts every due delivery once.
+func (s *WebhookSink) drain() {
+    pending, err := s.outbox.Pending()
+    if err != nil {
+        logging.Logger().Warn("webhook outbox scan", zap.Error(err))
+        return
+    }
+    for _, d := range pending {
+        select {
+        case <-s.quit:
             return
+        default:
+        }
+        if time.Now().Before(d.NextAttempt) {
+            continue
         }
+
+        err := s.post(d)
         if err == nil {
-            _ = resp.Body.Close()
+            if err := s.outbox.Remove(d.ID); err != nil {
+                logging.Logger().Warn("webhook outbox remove", zap.String("id", d.ID), zap.Error(err))
+            }
+            continue
+        }
+
+        d.Attempts++
+        d.LastError = err.Error()
+        logging.Logger().Warn("webhook notify failed", zap.String("rule", d.Rule), zap.String("id", d.ID), zap.Int("attempt", d.Attempts), zap.Error(err))
+        if d.Attempts >= s.MaxRetries {
+            if err := s.outbox.Bury(d); err != nil {
+                logging.Logger().Warn("webhook dead-letter", zap.String("id", d.ID), zap.Error(err))
+            }
+            continue
         }
-        logging.Logger().Warn("webhook notify failed", zap.String("rule", rule), zap.Int("attempt", attempt), zap.Error(err))
-        if attempt == s.MaxRetries {
-            break
+        bo := util.NewBackoff()
+        bo.Base = time.Second
+        bo.Max = 5 * time.Minute
+        bo.Attempt = d.Attempts
+        d.NextAttempt = time.Now().Add(bo.Next())
+        if err := s.outbox.Put(d); err != nil {
+            logging.Logger().Warn("webhook outbox update", zap.String("id", d.ID), zap.Error(err))
         }
-        time.Sleep(backoff.Next())
     }
 }
+
+// post performs one signed HTTP attempt.
+func (s *WebhookSink) post(d Delivery) error {
+    ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+    defer cancel()
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(d.Body))
+    if err != nil {
+        return err
+    }
+    ts := time.Now().Unix()
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set(HeaderWebhookDelivery, d.ID)
+    req.Header.Set("Idempotency-Key", d.ID)
+    req.Header.Set(HeaderWebhookTimestamp, strconv.FormatInt(ts, 10))
+    if s.Secret != "" {
+        req.Header.Set(HeaderWebhookSignature, SignWebhook([]byte(s.Secret), ts, d.Body))
+    }
+
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return err
+    }
+    _ = resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return fmt.Errorf("unexpected status %s", resp.Status)
+    }
+    return nil
+}
+
+//--------------------------------------------------------------------
+// signature helpers
+//--------------------------------------------------------------------
+
+var (
+    ErrWebhookSignature = errors.New("webhook signature mismatch")
+    ErrWebhookStale     = errors.New("webhook timestamp outside tolerance")
+)
+
+// SignWebhook returns the X-FlareGo-Signature value for body sent at ts.
+func SignWebhook(secret []byte, ts int64, body []byte) string {
+    mac := hmac.New(sha256.New, secret)
+    mac.Write([]byte(strconv.FormatInt(ts, 10)))
+    mac.Write([]byte{'.'})
+    mac.Write(body)
+    return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the timestamp and signature headers of a
+// received request.  tolerance bounds clock skew and replay age; zero
+// disables the timestamp check.
+func VerifyWebhookSignature(secret []byte, tsHeader, sigHeader string, body []byte, tolerance time.Duration) error {
+    ts, err := strconv.ParseInt(tsHeader, 10, 64)
+    if err != nil {
+        return ErrWebhookStale
+    }
+    if tolerance > 0 {
+        age := time.Since(time.Unix(ts, 0))
+        if age > tolerance || age < -tolerance {
+            return ErrWebhookStale
+        }
+    }
+    if !hmac.Equal([]byte(SignWebhook(secret, ts, body)), []byte(sigHeader)) {
+        return ErrWebhookSignature
+    }
+    return nil
+}
@@ -0,0 +1,162 @@
+package sinks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the requests it gets and answers with status.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []receivedWebhook
+	got      chan struct{}
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(status int) (*webhookReceiver, *httptest.Server) {
+	wr := &webhookReceiver{status: status, got: make(chan struct{}, 64)}
+	return wr, httptest.NewServer(wr)
+}
+
+func (wr *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	wr.mu.Lock()
+	wr.requests = append(wr.requests, receivedWebhook{header: r.Header.Clone(), body: body})
+	wr.mu.Unlock()
+	w.WriteHeader(wr.status)
+	wr.got <- struct{}{}
+}
+
+func (wr *webhookReceiver) wait(t *testing.T) receivedWebhook {
+	t.Helper()
+	select {
+	case <-wr.got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for webhook delivery")
+	}
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	return wr.requests[len(wr.requests)-1]
+}
+
+func TestWebhookSink_Signature(t *testing.T) {
+	wr, srv := newWebhookReceiver(http.StatusOK)
+	defer srv.Close()
+
+	secret := []byte("s3cret")
+	s := NewWebhookSink(srv.URL)
+	s.Secret = string(secret)
+	defer s.Close()
+	s.Notify("high heap", "heap_bytes > 512MiB")
+
+	req := wr.wait(t)
+	ts, sig := req.header.Get(HeaderWebhookTimestamp), req.header.Get(HeaderWebhookSignature)
+	if err := VerifyWebhookSignature(secret, ts, sig, req.body, time.Minute); err != nil {
+		t.Errorf("expected valid signature, got %v", err)
+	}
+	if err := VerifyWebhookSignature([]byte("other"), ts, sig, req.body, time.Minute); err != ErrWebhookSignature {
+		t.Errorf("expected mismatch for wrong secret, got %v", err)
+	}
+	tampered := append([]byte{}, req.body...)
+	tampered[len(tampered)-2] ^= 1
+	if err := VerifyWebhookSignature(secret, ts, sig, tampered, time.Minute); err != ErrWebhookSignature {
+		t.Errorf("expected mismatch for tampered body, got %v", err)
+	}
+	hourAgo := time.Now().Add(-time.Hour).Unix()
+	old := SignWebhook(secret, hourAgo, req.body)
+	if err := VerifyWebhookSignature(secret, strconv.FormatInt(hourAgo, 10), old, req.body, time.Minute); err != ErrWebhookStale {
+		t.Errorf("expected stale timestamp rejected, got %v", err)
+	}
+
+	var payload map[string]any
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if payload["id"] != req.header.Get(HeaderWebhookDelivery) || req.header.Get("Idempotency-Key") != payload["id"] {
+		t.Errorf("expected delivery id in body and headers, got %v / %v", payload["id"], req.header)
+	}
+}
+
+func TestWebhookSink_RedeliversAfterRestart(t *testing.T) {
+	wr, srv := newWebhookReceiver(http.StatusOK)
+	defer srv.Close()
+
+	// A previous gateway run queued a delivery and stopped before sending.
+	dir := t.TempDir()
+	ob, err := OpenOutbox(dir)
+	if err != nil {
+		t.Fatalf("open outbox: %v", err)
+	}
+	queued := Delivery{ID: "01HZX0000000000000000000AA", Rule: "blocked", Body: []byte(`{"rule":"blocked"}`), Attempts: 2, NextAttempt: time.Now().Add(-time.Second)}
+	if err := ob.Put(queued); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	s := NewWebhookSink(srv.URL)
+	s.OutboxDir = dir
+	if err := s.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	req := wr.wait(t)
+	_ = s.Close()
+
+	if got := req.header.Get(HeaderWebhookDelivery); got != queued.ID {
+		t.Errorf("expected queued delivery %s, got %s", queued.ID, got)
+	}
+	if string(req.body) != string(queued.Body) {
+		t.Errorf("expected stored body, got %s", req.body)
+	}
+	if pending, _ := ob.Pending(); len(pending) != 0 {
+		t.Errorf("expected outbox drained, got %+v", pending)
+	}
+}
+
+func TestWebhookSink_DeadLetterAfterMaxRetries(t *testing.T) {
+	wr, srv := newWebhookReceiver(http.StatusInternalServerError)
+	defer srv.Close()
+
+	ob, _ := OpenOutbox("")
+	s := NewWebhookSink(srv.URL)
+	s.MaxRetries = 3
+	s.outbox, s.client = ob, srv.Client()
+	if err := ob.Put(Delivery{ID: "01", Rule: "r", Body: []byte(`{}`)}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	// Drive the worker by hand, skipping the back‑off between attempts.
+	for attempt := 1; attempt <= s.MaxRetries; attempt++ {
+		if dead, _ := s.DeadLetters(); len(dead) != 0 {
+			t.Fatalf("buried after %d attempts, want %d", attempt-1, s.MaxRetries)
+		}
+		s.drain()
+		pending, _ := ob.Pending()
+		for _, d := range pending {
+			d.NextAttempt = time.Time{}
+			_ = ob.Put(d)
+		}
+	}
+
+	dead, _ := s.DeadLetters()
+	if len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastError == "" {
+		t.Fatalf("expected one dead letter after 3 attempts, got %+v", dead)
+	}
+	if pending, _ := ob.Pending(); len(pending) != 0 {
+		t.Errorf("expected nothing pending, got %+v", pending)
+	}
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	if len(wr.requests) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(wr.requests))
+	}
+}
@@ -0,0 +1,153 @@
+// internal/gateway/audit/audit.go
+// Package audit writes the gateway's compliance trail: who authenticated (or
+// failed to), who subscribed to or fetched which tenant's profiles, and who
+// called the /admin operator endpoints.  Agent control and alert rule or
+// silence changes are not audited: the gateway serves no endpoints for them
+// yet.  Events go to a dedicated JSON‑lines file, one object per line,
+// independent of the zap logger so log level or sampling changes can never
+// drop them:
+//
+//	{"time":"…","type":"subscribe","subject":"ui","tenant":"team-a","remote_addr":"10.0.0.7:51234","outcome":"success","action":"/ws","detail":{"filters":{"service":["api"]}}}
+//
+// The file is rotated by size: <path> → <path>.1 → … → <path>.MaxBackups,
+// the oldest being removed.  A nil *Log discards events, so call sites need
+// no "audit enabled?" checks.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+    TypeAuth      = "auth"      // authentication or authorization failure
+    TypeSubscribe = "subscribe" // UI subscription (gRPC or WebSocket)
+    TypeAdmin     = "admin"     // /admin/* call
+    TypeRead      = "read"      // profile data fetched over HTTP (/artifacts, /render.svg, /query)
+)
+
+// Outcomes.
+const (
+    OutcomeSuccess = "success"
+    OutcomeDenied  = "denied"
+    OutcomeError   = "error"
+)
+
+// Event is one audit record.
+type Event struct {
+    Time       time.Time      `json:"time"`
+    Type       string         `json:"type"`
+    Subject    string         `json:"subject,omitempty"`
+    Tenant     string         `json:"tenant,omitempty"`
+    RemoteAddr string         `json:"remote_addr,omitempty"`
+    Outcome    string         `json:"outcome"`
+    Action     string         `json:"action,omitempty"` // gRPC method, HTTP path or command
+    Reason     string         `json:"reason,omitempty"` // failure cause
+    Detail     map[string]any `json:"detail,omitempty"`
+}
+
+// Log is a size‑rotated JSON‑lines audit file.  Safe for concurrent use.
+type Log struct {
+    Path       string
+    MaxBytes   int64 // rotate when exceeded (default 100 MiB)
+    MaxBackups int   // rotated files kept (default 5)
+
+    mu   sync.Mutex
+    f    *os.File
+    size int64
+}
+
+// Open creates or appends to path.
+func Open(path string, maxBytes int64, maxBackups int) (*Log, error) {
+    if maxBytes <= 0 {
+        maxBytes = 100 << 20
+    }
+    if maxBackups <= 0 {
+        maxBackups = 5
+    }
+    l := &Log{Path: path, MaxBytes: maxBytes, MaxBackups: maxBackups}
+    if err := l.open(); err != nil {
+        return nil, err
+    }
+    return l, nil
+}
+
+func (l *Log) open() error {
+    f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+    if err != nil {
+        return err
+    }
+    st, err := f.Stat()
+    if err != nil {
+        _ = f.Close()
+        return err
+    }
+    l.f, l.size = f, st.Size()
+    return nil
+}
+
+// Record appends e, stamping Time when unset.  Write errors are returned but
+// never block the caller for long; the gateway logs and carries on.
+func (l *Log) Record(e Event) error {
+    if l == nil {
+        return nil
+    }
+    if e.Time.IsZero() {
+        e.Time = time.Now().UTC()
+    }
+    line, err := json.Marshal(e)
+    if err != nil {
+        return err
+    }
+    line = append(line, '\n')
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.f == nil {
+        return os.ErrClosed
+    }
+    if l.size+int64(len(line)) > l.MaxBytes && l.size > 0 {
+        if err := l.rotate(); err != nil && l.f == nil {
+            return err
+        }
+    }
+    n, err := l.f.Write(line)
+    l.size += int64(n)
+    return err
+}
+
+// rotate shifts backups up by one and starts a fresh file.  Caller holds mu.
+func (l *Log) rotate() error {
+    if err := l.f.Close(); err != nil {
+        return err
+    }
+    l.f = nil
+    _ = os.Remove(fmt.Sprintf("%s.%d", l.Path, l.MaxBackups))
+    for i := l.MaxBackups - 1; i >= 1; i-- {
+        _ = os.Rename(fmt.Sprintf("%s.%d", l.Path, i), fmt.Sprintf("%s.%d", l.Path, i+1))
+    }
+    renameErr := os.Rename(l.Path, l.Path+".1")
+    if err := l.open(); err != nil {
+        return err
+    }
+    return renameErr // on failure we keep appending to the current file
+}
+
+// Close flushes and closes the file.
+func (l *Log) Close() error {
+    if l == nil {
+        return nil
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.f == nil {
+        return nil
+    }
+    err := l.f.Close()
+    l.f = nil
+    return err
+}
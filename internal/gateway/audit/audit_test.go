@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path, 200, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := l.Record(Event{Type: TypeAuth, Subject: "ui", Outcome: OutcomeDenied, Reason: "invalid auth token"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		st, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("Expected %s to exist: %v", filepath.Base(p), err)
+		}
+		if st.Size() > 200 {
+			t.Errorf("Expected %s to stay under the size limit, got %d bytes", filepath.Base(p), st.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("Expected backups beyond MaxBackups to be removed")
+	}
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("Invalid JSON line %q: %v", sc.Text(), err)
+		}
+		if e.Time.IsZero() || e.Type != TypeAuth {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	}
+}
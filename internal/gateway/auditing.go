@@ -0,0 +1,133 @@
+// internal/gateway/auditing.go
+// Wiring between the gateway and the audit package.  Call sites:
+//
+//   - auth failures  – gRPC interceptors and requireScope (authz.go)
+//   - subscriptions  – StreamFlamegraphs (server.go) and /ws (listener.go)
+//   - admin calls    – every /admin/* route, via auditAdmin
+//   - data reads     – /artifacts, /render.svg and /query, via auditRead
+//
+// Audit write failures are logged but never fail the request.
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/Voskan/flarego/internal/gateway/audit"
+	"github.com/Voskan/flarego/internal/logging"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// record writes e to the audit log, if one is configured.
+func (s *Server) record(e audit.Event) {
+    if err := s.audit.Record(e); err != nil {
+        logging.Sugar().Warnw("audit write", "type", e.Type, "err", err)
+    }
+}
+
+// auditDenied records a failed authentication or authorization.  p is nil
+// when the credential itself was rejected.
+func (s *Server) auditDenied(p *Principal, remote, action string, err error) {
+    e := audit.Event{
+        Type:       audit.TypeAuth,
+        RemoteAddr: remote,
+        Outcome:    audit.OutcomeDenied,
+        Action:     action,
+        Reason:     err.Error(),
+    }
+    if st, ok := status.FromError(err); ok {
+        e.Reason = st.Message()
+    }
+    if p != nil {
+        e.Subject, e.Tenant = p.Subject, p.Tenant
+    }
+    s.record(e)
+}
+
+// auditSubscribe records a subscription attempt.  filters describes what the
+// subscriber asked for (query parameters for /ws); err is the quota or
+// tenant error when the subscription was refused.
+func (s *Server) auditSubscribe(p *Principal, remote, action string, filters url.Values, err error) {
+    e := audit.Event{
+        Type:       audit.TypeSubscribe,
+        Subject:    p.Subject,
+        Tenant:     p.Tenant,
+        RemoteAddr: remote,
+        Outcome:    audit.OutcomeSuccess,
+        Action:     action,
+    }
+    if len(filters) > 0 {
+        e.Detail = map[string]any{"filters": filters}
+    }
+    if err != nil {
+        e.Outcome, e.Reason = audit.OutcomeDenied, err.Error()
+    }
+    s.record(e)
+}
+
+// auditAdmin wraps an admin handler, recording caller, request and result.
+func (s *Server) auditAdmin(next http.Handler) http.Handler {
+    return s.auditHTTP(audit.TypeAdmin, next)
+}
+
+// auditRead wraps a read‑scope handler that returns profile data the same way.
+func (s *Server) auditRead(next http.Handler) http.Handler {
+    return s.auditHTTP(audit.TypeRead, next)
+}
+
+// auditHTTP records one event of type typ per request served by next.
+func (s *Server) auditHTTP(typ string, next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+        next.ServeHTTP(rec, r)
+
+        p := PrincipalFromContext(r.Context())
+        e := audit.Event{
+            Type:       typ,
+            Subject:    p.Subject,
+            Tenant:     p.Tenant,
+            RemoteAddr: r.RemoteAddr,
+            Outcome:    audit.OutcomeSuccess,
+            Action:     r.Method + " " + r.URL.Path,
+            Detail:     map[string]any{"status": rec.code},
+        }
+        if q := redactedQuery(r.URL.Query()); len(q) > 0 {
+            e.Detail["query"] = q
+        }
+        switch {
+        case rec.code >= 500:
+            e.Outcome = audit.OutcomeError
+        case rec.code >= 400:
+            e.Outcome = audit.OutcomeDenied
+        }
+        s.record(e)
+    })
+}
+
+// statusRecorder captures the response code of a handler.
+type statusRecorder struct {
+    http.ResponseWriter
+    code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+    r.code = code
+    r.ResponseWriter.WriteHeader(code)
+}
+
+// redactedQuery drops credentials from query parameters before they are
+// written anywhere.
+func redactedQuery(q url.Values) url.Values {
+    q.Del("access_token")
+    return q
+}
+
+// peerAddr returns the remote address of a gRPC caller ("" if unknown).
+func peerAddr(ctx context.Context) string {
+    if pr, ok := peer.FromContext(ctx); ok && pr.Addr != nil {
+        return pr.Addr.String()
+    }
+    return ""
+}
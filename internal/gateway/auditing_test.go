@@ -0,0 +1,126 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Voskan/flarego/internal/gateway/audit"
+)
+
+func TestAuditTrail(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "tokens.yaml")
+	err := os.WriteFile(tokenFile, []byte(`tokens:
+  - token: ops-tok
+    subject: ops
+    tenant: team-a
+    scopes: [admin]
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "audit.jsonl")
+	s, err := New(Config{TokenFile: tokenFile, AuditLogPath: logPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	s.registerAdminRoutes(mux)
+
+	for _, tok := range []string{"Bearer wrong", "Bearer ops-tok"} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/agents?access_token=secret&verbose=1", nil)
+		req.Header.Set("Authorization", tok)
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	_ = s.audit.Close()
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []audit.Event
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e audit.Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 audit events, got %d", len(events))
+	}
+	if e := events[0]; e.Type != audit.TypeAuth || e.Outcome != audit.OutcomeDenied || e.RemoteAddr == "" {
+		t.Errorf("Unexpected auth failure event %+v", e)
+	}
+	e := events[1]
+	if e.Type != audit.TypeAdmin || e.Subject != "ops" || e.Tenant != "team-a" || e.Action != "GET /admin/agents" {
+		t.Errorf("Unexpected admin event %+v", e)
+	}
+	if q, _ := json.Marshal(e.Detail["query"]); string(q) != `{"verbose":["1"]}` {
+		t.Errorf("Expected access_token to be redacted from query, got %s", q)
+	}
+}
+
+func TestAuditReads(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "tokens.yaml")
+	err := os.WriteFile(tokenFile, []byte(`tokens:
+  - token: ui-tok
+    subject: ui
+    tenant: team-a
+    scopes: [read]
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "audit.jsonl")
+	s, err := New(Config{TokenFile: tokenFile, AuditLogPath: logPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	s.registerReadRoutes(mux)
+
+	paths := []string{"/query?transform=collapse&access_token=secret", "/render.svg", "/artifacts/x-snapshot.fgo"}
+	for _, p := range paths {
+		req := httptest.NewRequest(http.MethodGet, p, nil)
+		req.Header.Set("Authorization", "Bearer ui-tok")
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	_ = s.audit.Close()
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []audit.Event
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var e audit.Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != len(paths) {
+		t.Fatalf("Expected one audit event per read, got %d", len(events))
+	}
+	for i, want := range []string{"GET /query", "GET /render.svg", "GET /artifacts/x-snapshot.fgo"} {
+		if e := events[i]; e.Type != audit.TypeRead || e.Subject != "ui" || e.Tenant != "team-a" || e.Action != want {
+			t.Errorf("Unexpected read event %+v", e)
+		}
+	}
+	if q, _ := json.Marshal(events[0].Detail["query"]); string(q) != `{"transform":["collapse"]}` {
+		t.Errorf("Expected access_token to be redacted from query, got %s", q)
+	}
+	if e := events[2]; e.Outcome != audit.OutcomeDenied {
+		t.Errorf("Expected missing artifact to be recorded as denied, got %+v", e)
+	}
+}
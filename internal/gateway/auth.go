@@ -2,103 +2,118 @@
 // Common authentication helpers for the gateway.  Supports two modes:
 //  1. Static bearer token (shared secret) – very cheap check for internal
 //     clusters.  Enabled when Config.AuthToken is non-empty.
-//  2. JWT HMAC-SHA256 token – validates signature, issuer and expiry via
-//     pkg/auth.Verifier when Config.JWTSecret is set (takes precedence over
-//     plain AuthToken).
+//  2. JWT – HS256 with a shared secret and/or RS256/ES256/EdDSA against a
+//     PEM or JWKS key file (reloaded every RefreshInterval for rotation).
+//     Validates signature, exp, nbf, iss and aud via pkg/auth.Verifier when
+//     Config.JWT has a secret or key file (takes precedence over plain
+//     AuthToken).
 //
-// The gRPC server side registers unary and stream interceptors that call
-// validateBearer().  The HTTP listener attaches a standard middleware to
-// protect /ws and other endpoints.
+//  3. Static token file – many opaque tokens, each with its own subject and
+//     scopes (see LoadTokenFile in authz.go).
+//  4. mTLS client certificates verified against Config.ClientCAPath; the
+//     CN / SANs map to a Principal via the token file's `certificates:`
+//     rules.  Used when a request carries no bearer token.
+//
+// This file only answers "who is calling"; what the caller may do is decided
+// by the authorization layer in authz.go.
 package gateway
 
 import (
 	"context"
-	"net/http"
+	"crypto/subtle"
+	"crypto/x509"
 	"strings"
+	"time"
 
 	"github.com/Voskan/flarego/pkg/auth"
-	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
-// JWTConfig optionally enables JWT auth.
+// JWTConfig optionally enables JWT auth.  JWT auth is disabled when both
+// Secret and KeysFile are empty.
 type JWTConfig struct {
-    Secret []byte // HMAC secret; if nil JWT auth is disabled
-    Issuer string // expected iss claim; empty means any issuer accepted
+    Secret          []byte        // HMAC secret for HS256 tokens
+    KeysFile        string        // PEM or JWKS public keys for RS256/ES256/EdDSA
+    RefreshInterval time.Duration // KeysFile reload period (0 => 5m)
+    Issuer          string        // expected iss claim; empty means any issuer accepted
+    Audience        string        // required aud claim; empty skips the check
+    Leeway          time.Duration // tolerated clock skew for exp/nbf
+    TenantClaim     string        // claim naming the tenant (default "tenant")
+    DenylistFile    string        // revoked jti values (`flarego token revoke`)
 }
 
-// validateBearer validates Authorization header against cfg.AuthToken or JWT.
-func (s *Server) validateBearer(token string) error {
-    if strings.HasPrefix(token, "Bearer ") {
-        token = strings.TrimPrefix(token, "Bearer ")
+// authenticate resolves an Authorization header value (or bare token) to a
+// Principal.  Lookup order: static token file, legacy AuthToken, JWT.  With
+// no credential source configured every caller is the anonymous admin.
+func (s *Server) authenticate(token string) (*Principal, error) {
+    if !s.authEnabled() {
+        return anonymous, nil
     }
-    // Prefer JWT validation when enabled.
-    if len(s.jwt.secret) > 0 {
-        _, err := s.jwt.verifier.ParseAndVerify(token)
-        return err
+    token = strings.TrimPrefix(token, "Bearer ")
+    if token == "" {
+        return nil, ErrUnauthenticated
     }
-    if s.cfg.AuthToken == "" {
-        return nil // auth disabled
+    if p, ok := s.tokens[token]; ok {
+        return p, nil
     }
-    if token != s.cfg.AuthToken {
-        return ErrInvalidToken
+    if s.cfg.AuthToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.AuthToken)) == 1 {
+        return &Principal{Subject: "auth-token", Tenant: DefaultTenant, Scopes: []Scope{ScopeAdmin}}, nil
     }
-    return nil
-}
-
-// installInterceptors attaches auth interceptors to the gRPC server.
-func (s *Server) installInterceptors() {
-    s.grpcSrv = grpc.NewServer(
-        grpc.StreamInterceptor(s.streamAuthInterceptor()),
-        grpc.UnaryInterceptor(s.unaryAuthInterceptor()),
-    )
-}
-
-func (s *Server) unaryAuthInterceptor() grpc.UnaryServerInterceptor {
-    return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-        if err := s.authFromContext(ctx); err != nil {
-            return nil, err
+    if s.jwt.verifier != nil {
+        claims, err := s.jwt.verifier.ParseAndVerify(token)
+        if err != nil {
+            return nil, ErrInvalidToken
         }
-        return handler(ctx, req)
-    }
-}
-
-func (s *Server) streamAuthInterceptor() grpc.StreamServerInterceptor {
-    return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-        if err := s.authFromContext(ss.Context()); err != nil {
-            return err
+        sub, _ := claims["sub"].(string)
+        tenant, _ := claims[s.jwt.tenantClaim].(string)
+        if tenant == "" {
+            tenant = DefaultTenant
+        }
+        if !ValidTenant(tenant) {
+            return nil, ErrInvalidToken
         }
-        return handler(srv, ss)
+        return &Principal{Subject: sub, Tenant: tenant, Scopes: scopesFromClaims(claims)}, nil
     }
+    return nil, ErrInvalidToken
 }
 
-func (s *Server) authFromContext(ctx context.Context) error {
-    md, ok := metadata.FromIncomingContext(ctx)
-    if !ok {
-        return ErrUnauthenticated
+// authFromContext authenticates the bearer token in gRPC metadata.
+func (s *Server) authFromContext(ctx context.Context) (*Principal, error) {
+    if !s.authEnabled() {
+        return anonymous, nil
     }
+    md, _ := metadata.FromIncomingContext(ctx)
     vals := md.Get("authorization")
     if len(vals) == 0 {
-        return ErrUnauthenticated
+        if c := verifiedPeerCert(ctx); c != nil {
+            return s.principalFromCert(c), nil
+        }
+        return nil, ErrUnauthenticated
     }
-    return s.validateBearer(vals[0])
+    return s.authenticate(vals[0])
 }
 
-// HTTPAuthMiddleware wraps an http.Handler and enforces bearer auth.
-func (s *Server) HTTPAuthMiddleware(next http.Handler) http.Handler {
-    if s.cfg.AuthToken == "" && len(s.jwt.secret) == 0 {
-        return next // auth disabled
+// authEnabled reports whether any credential check is configured.
+func (s *Server) authEnabled() bool {
+    return s.cfg.AuthToken != "" || len(s.tokens) > 0 || s.jwt.verifier != nil || s.cfg.ClientCAPath != ""
+}
+
+// verifiedPeerCert returns the client's leaf certificate when the transport
+// verified it against the client CA bundle.
+func verifiedPeerCert(ctx context.Context) *x509.Certificate {
+    pr, ok := peer.FromContext(ctx)
+    if !ok {
+        return nil
     }
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        token := r.Header.Get("Authorization")
-        if err := s.validateBearer(token); err != nil {
-            http.Error(w, "unauthorized", http.StatusUnauthorized)
-            return
-        }
-        next.ServeHTTP(w, r)
-    })
+    ti, ok := pr.AuthInfo.(credentials.TLSInfo)
+    if !ok || len(ti.State.VerifiedChains) == 0 || len(ti.State.VerifiedChains[0]) == 0 {
+        return nil
+    }
+    return ti.State.VerifiedChains[0][0]
 }
 
 // error definitions --------------------------------------------------------
@@ -110,16 +125,56 @@ var (
 // internal JWT helper ------------------------------------------------------
 
 type jwtHelper struct {
-    secret   []byte
-    verifier *auth.Verifier
+    verifier    *auth.Verifier
+    tenantClaim string
+    keys     *auth.KeySet // nil unless KeysFile is set
 }
 
-func newJWTHelper(cfg JWTConfig) jwtHelper {
-    if len(cfg.Secret) == 0 {
-        return jwtHelper{}
+func newJWTHelper(cfg JWTConfig) (jwtHelper, error) {
+    if len(cfg.Secret) == 0 && cfg.KeysFile == "" {
+        return jwtHelper{}, nil
+    }
+    h := jwtHelper{tenantClaim: cfg.TenantClaim}
+    if h.tenantClaim == "" {
+        h.tenantClaim = "tenant"
+    }
+    if cfg.KeysFile != "" {
+        refresh := cfg.RefreshInterval
+        if refresh <= 0 {
+            refresh = 5 * time.Minute
+        }
+        ks, err := auth.LoadKeySet(cfg.KeysFile, refresh)
+        if err != nil {
+            return jwtHelper{}, err
+        }
+        h.keys = ks
     }
-    return jwtHelper{
-        secret:   cfg.Secret,
-        verifier: auth.NewVerifier(cfg.Secret, cfg.Issuer),
+    var deny *auth.Denylist
+    if cfg.DenylistFile != "" {
+        d, err := auth.LoadDenylist(cfg.DenylistFile)
+        if err != nil {
+            return jwtHelper{}, err
+        }
+        deny = d
+    }
+    v, err := auth.NewVerifierWithConfig(auth.VerifierConfig{
+        Secret:   cfg.Secret,
+        Keys:     h.keys,
+        Issuer:   cfg.Issuer,
+        Audience: cfg.Audience,
+        Leeway:   cfg.Leeway,
+        Denylist: deny,
+    })
+    if err != nil {
+        return jwtHelper{}, err
+    }
+    h.verifier = v
+    return h, nil
+}
+
+// close stops the key reload goroutine.
+func (h jwtHelper) close() {
+    if h.keys != nil {
+        h.keys.Close()
     }
 }
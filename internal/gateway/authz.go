@@ -0,0 +1,339 @@
+// internal/gateway/authz.go
+// Authorization layer.  Every credential the gateway accepts resolves to a
+// Principal carrying a set of scopes:
+//
+//	ingest – push profiles (GatewayService.Stream)
+//	read   – consume profiles (UIService, /ws, /artifacts)
+//	admin  – operator endpoints (/admin/*); implies every other scope
+//
+// Scopes come from the JWT `scope` claim (space separated, RFC 8693 style)
+// or `scopes` claim (array), from the static token file (see LoadTokenFile)
+// or, for mTLS clients, from the certificate rules in the same file (see
+// LoadCertIdentities).  The legacy single Config.AuthToken keeps its historical
+// meaning and grants every scope.
+//
+// Checks happen in exactly one place per transport: the gRPC interceptors
+// below map the full method name to a required scope, and requireScope wraps
+// each HTTP route at registration time.  Unknown gRPC methods require admin so
+// new RPCs are closed by default.  Handlers retrieve the caller via
+// PrincipalFromContext.
+package gateway
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	agentpb "github.com/Voskan/flarego/internal/proto"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Scope is a coarse permission.
+type Scope string
+
+const (
+    ScopeIngest Scope = "ingest"
+    ScopeRead   Scope = "read"
+    ScopeAdmin  Scope = "admin"
+)
+
+// Principal is an authenticated caller.
+type Principal struct {
+    Subject string
+    Tenant  string // never empty; DefaultTenant when the credential has none
+    Scopes  []Scope
+}
+
+// Has reports whether p holds sc; admin implies all scopes.
+func (p *Principal) Has(sc Scope) bool {
+    if p == nil {
+        return false
+    }
+    for _, have := range p.Scopes {
+        if have == sc || have == ScopeAdmin {
+            return true
+        }
+    }
+    return false
+}
+
+// anonymous is used when no authentication is configured at all.
+var anonymous = &Principal{Subject: "anonymous", Tenant: DefaultTenant, Scopes: []Scope{ScopeAdmin}}
+
+// grpcMethodScopes maps full gRPC method names to the scope they require.
+var grpcMethodScopes = map[string]Scope{
+    agentpb.GatewayService_Stream_FullMethodName:       ScopeIngest,
+    agentpb.UIService_StreamFlamegraphs_FullMethodName: ScopeRead,
+}
+
+func methodScope(fullMethod string) Scope {
+    if sc, ok := grpcMethodScopes[fullMethod]; ok {
+        return sc
+    }
+    return ScopeAdmin
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the caller attached by the auth layer.
+func PrincipalFromContext(ctx context.Context) *Principal {
+    p, _ := ctx.Value(principalKey{}).(*Principal)
+    return p
+}
+
+func withPrincipal(ctx context.Context, p *Principal) context.Context {
+    return context.WithValue(ctx, principalKey{}, p)
+}
+
+// errMissingScope is returned when a valid credential lacks the needed scope.
+func errMissingScope(sc Scope) error {
+    return status.Errorf(codes.PermissionDenied, "token lacks %q scope", sc)
+}
+
+//--------------------------------------------------------------------
+// gRPC
+//--------------------------------------------------------------------
+
+func (s *Server) unaryAuthInterceptor() grpc.UnaryServerInterceptor {
+    return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+        p, err := s.authorizeContext(ctx, methodScope(info.FullMethod))
+        if err != nil {
+            s.auditDenied(p, peerAddr(ctx), info.FullMethod, err)
+            return nil, err
+        }
+        return handler(withPrincipal(ctx, p), req)
+    }
+}
+
+func (s *Server) streamAuthInterceptor() grpc.StreamServerInterceptor {
+    return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+        p, err := s.authorizeContext(ss.Context(), methodScope(info.FullMethod))
+        if err != nil {
+            s.auditDenied(p, peerAddr(ss.Context()), info.FullMethod, err)
+            return err
+        }
+        return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: withPrincipal(ss.Context(), p)})
+    }
+}
+
+// authorizeContext authenticates the caller and checks sc.  On a missing
+// scope the principal is returned alongside the error for auditing.
+func (s *Server) authorizeContext(ctx context.Context, sc Scope) (*Principal, error) {
+    p, err := s.authFromContext(ctx)
+    if err != nil {
+        return nil, err
+    }
+    if !p.Has(sc) {
+        return p, errMissingScope(sc)
+    }
+    return p, nil
+}
+
+//--------------------------------------------------------------------
+// HTTP
+//--------------------------------------------------------------------
+
+// requireScope wraps next so only callers holding sc reach it.  Browsers
+// cannot set headers on WebSocket upgrades, so the token may also be passed
+// as ?access_token=.
+func (s *Server) requireScope(sc Scope, next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        token := r.Header.Get("Authorization")
+        if token == "" {
+            token = r.URL.Query().Get("access_token")
+        }
+        var (
+            p   *Principal
+            err error
+        )
+        if token == "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+            p = s.principalFromCert(r.TLS.VerifiedChains[0][0])
+        } else {
+            p, err = s.authenticate(token)
+        }
+        if err != nil {
+            s.auditDenied(nil, r.RemoteAddr, r.URL.Path, err)
+            http.Error(w, "unauthorized", http.StatusUnauthorized)
+            return
+        }
+        if !p.Has(sc) {
+            s.auditDenied(p, r.RemoteAddr, r.URL.Path, errMissingScope(sc))
+            http.Error(w, "forbidden: missing scope "+string(sc), http.StatusForbidden)
+            return
+        }
+        next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+    })
+}
+
+//--------------------------------------------------------------------
+// scope parsing & static token file
+//--------------------------------------------------------------------
+
+// ParseScopes parses a space or comma separated scope list, rejecting
+// unknown names.
+func ParseScopes(s string) ([]Scope, error) {
+    var out []Scope
+    for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+        sc := Scope(f)
+        switch sc {
+        case ScopeIngest, ScopeRead, ScopeAdmin:
+            out = append(out, sc)
+        default:
+            return nil, errors.New("unknown scope " + f)
+        }
+    }
+    return out, nil
+}
+
+// scopesFromClaims reads `scope` (string) or `scopes` (array).  Unknown
+// names are ignored so tokens shared with other services stay usable.
+func scopesFromClaims(claims map[string]any) []Scope {
+    var names []string
+    switch v := claims["scope"].(type) {
+    case string:
+        names = strings.Fields(v)
+    }
+    if arr, ok := claims["scopes"].([]any); ok {
+        for _, x := range arr {
+            if s, ok := x.(string); ok {
+                names = append(names, s)
+            }
+        }
+    }
+    var out []Scope
+    for _, n := range names {
+        if sc, err := ParseScopes(n); err == nil {
+            out = append(out, sc...)
+        }
+    }
+    return out
+}
+
+// TokenEntry is one static credential in a token file.
+type TokenEntry struct {
+    Token   string   `mapstructure:"token"`
+    Subject string   `mapstructure:"subject"`
+    Tenant  string   `mapstructure:"tenant"`
+    Scopes  []string `mapstructure:"scopes"`
+}
+
+// LoadTokenFile reads a YAML/TOML/JSON file of the form
+//
+//	tokens:
+//	  - token: "s3cr3t-agent"
+//	    subject: "agents"
+//	    tenant: "team-a"     # optional, defaults to DefaultTenant
+//	    scopes: [ingest]
+//
+// and returns principals keyed by token.
+func LoadTokenFile(path string) (map[string]*Principal, error) {
+    v := viper.New()
+    v.SetConfigFile(path)
+    if err := v.ReadInConfig(); err != nil {
+        return nil, err
+    }
+    var entries []TokenEntry
+    if err := v.UnmarshalKey("tokens", &entries); err != nil {
+        return nil, err
+    }
+    out := make(map[string]*Principal, len(entries))
+    for i, e := range entries {
+        if e.Token == "" {
+            return nil, errors.New("token file: entry without token")
+        }
+        scopes, err := ParseScopes(strings.Join(e.Scopes, " "))
+        if err != nil {
+            return nil, err
+        }
+        subject := e.Subject
+        if subject == "" {
+            subject = fmt.Sprintf("token#%d", i+1)
+        }
+        tenant := e.Tenant
+        if tenant == "" {
+            tenant = DefaultTenant
+        }
+        if !ValidTenant(tenant) {
+            return nil, fmt.Errorf("token file: invalid tenant %q", tenant)
+        }
+        out[e.Token] = &Principal{Subject: subject, Tenant: tenant, Scopes: scopes}
+    }
+    return out, nil
+}
+
+// CertIdentity maps verified client certificates to a Principal.  Match is a
+// path.Match glob tested against the subject CN and every DNS and URI SAN,
+// e.g. "*.team-a.agents.internal" or "spiffe://prod/team-a/*".
+type CertIdentity struct {
+    Match   string   `mapstructure:"match"`
+    Subject string   `mapstructure:"subject"` // default: the matched name
+    Tenant  string   `mapstructure:"tenant"`
+    Scopes  []string `mapstructure:"scopes"`
+}
+
+// LoadCertIdentities reads the `certificates:` list of a token file:
+//
+//	certificates:
+//	  - match: "*.team-a.agents.internal"
+//	    tenant: team-a
+//	    scopes: [ingest]
+func LoadCertIdentities(file string) ([]CertIdentity, error) {
+    v := viper.New()
+    v.SetConfigFile(file)
+    if err := v.ReadInConfig(); err != nil {
+        return nil, err
+    }
+    var out []CertIdentity
+    if err := v.UnmarshalKey("certificates", &out); err != nil {
+        return nil, err
+    }
+    for _, c := range out {
+        if _, err := path.Match(c.Match, ""); err != nil || c.Match == "" {
+            return nil, fmt.Errorf("token file: bad certificate match %q", c.Match)
+        }
+        if _, err := ParseScopes(strings.Join(c.Scopes, " ")); err != nil {
+            return nil, err
+        }
+        if c.Tenant != "" && !ValidTenant(c.Tenant) {
+            return nil, fmt.Errorf("token file: invalid tenant %q", c.Tenant)
+        }
+    }
+    return out, nil
+}
+
+// principalFromCert resolves a verified leaf certificate.  Certificates no
+// rule matches authenticate as their CN but hold no scopes.
+func (s *Server) principalFromCert(c *x509.Certificate) *Principal {
+    names := []string{c.Subject.CommonName}
+    names = append(names, c.DNSNames...)
+    for _, u := range c.URIs {
+        names = append(names, u.String())
+    }
+    for _, rule := range s.certs {
+        for _, n := range names {
+            if n == "" {
+                continue
+            }
+            if ok, _ := path.Match(rule.Match, n); !ok {
+                continue
+            }
+            scopes, _ := ParseScopes(strings.Join(rule.Scopes, " "))
+            p := &Principal{Subject: rule.Subject, Tenant: rule.Tenant, Scopes: scopes}
+            if p.Subject == "" {
+                p.Subject = n
+            }
+            if p.Tenant == "" {
+                p.Tenant = DefaultTenant
+            }
+            return p
+        }
+    }
+    return &Principal{Subject: c.Subject.CommonName, Tenant: DefaultTenant}
+}
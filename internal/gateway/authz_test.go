@@ -0,0 +1,122 @@
+package gateway
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Voskan/flarego/pkg/auth"
+)
+
+func TestRequireScope(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "tokens.yaml")
+	err := os.WriteFile(tokenFile, []byte(`tokens:
+  - token: agent-tok
+    subject: agents
+    scopes: [ingest]
+  - token: ops-tok
+    subject: ops
+    scopes: [admin]
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("jwt-secret")
+	s, err := New(Config{TokenFile: tokenFile, JWT: JWTConfig{Secret: secret}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := auth.NewSigner(secret, "", time.Minute)
+	readJWT, _ := signer.Sign(signer.Claims("ui", map[string]any{"scope": "read"}))
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if PrincipalFromContext(r.Context()) == nil {
+			t.Error("Expected principal in request context")
+		}
+	})
+	cases := []struct {
+		scope Scope
+		token string
+		want  int
+	}{
+		{ScopeRead, "", http.StatusUnauthorized},
+		{ScopeRead, "Bearer nope", http.StatusUnauthorized},
+		{ScopeRead, "Bearer agent-tok", http.StatusForbidden},
+		{ScopeIngest, "Bearer agent-tok", http.StatusOK},
+		{ScopeRead, "Bearer " + readJWT, http.StatusOK},
+		{ScopeAdmin, "Bearer " + readJWT, http.StatusForbidden},
+		{ScopeRead, "Bearer ops-tok", http.StatusOK},
+		{ScopeAdmin, "Bearer ops-tok", http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/x", nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", c.token)
+		}
+		rec := httptest.NewRecorder()
+		s.requireScope(c.scope, ok).ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("scope %s token %.20q: expected %d, got %d", c.scope, c.token, c.want, rec.Code)
+		}
+	}
+}
+
+func TestMethodScope(t *testing.T) {
+	if methodScope("/agentpb.GatewayService/Stream") != ScopeIngest {
+		t.Error("Expected Stream to require ingest")
+	}
+	if methodScope("/agentpb.UIService/StreamFlamegraphs") != ScopeRead {
+		t.Error("Expected StreamFlamegraphs to require read")
+	}
+	if methodScope("/agentpb.Unknown/Method") != ScopeAdmin {
+		t.Error("Expected unknown methods to require admin")
+	}
+}
+
+func TestPrincipalFromCert(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "tokens.yaml")
+	err := os.WriteFile(tokenFile, []byte(`certificates:
+  - match: "*.team-a.agents.internal"
+    tenant: team-a
+    scopes: [ingest]
+  - match: "spiffe://prod/ops/*"
+    subject: ops
+    scopes: [admin]
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(Config{TokenFile: tokenFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := s.principalFromCert(&x509.Certificate{
+		Subject:  pkix.Name{CommonName: "host-1"},
+		DNSNames: []string{"host-1.team-a.agents.internal"},
+	})
+	if p.Tenant != "team-a" || !p.Has(ScopeIngest) || p.Has(ScopeRead) {
+		t.Errorf("Unexpected principal for team-a agent: %+v", p)
+	}
+	if p.Subject != "host-1.team-a.agents.internal" {
+		t.Errorf("Expected matched SAN as subject, got %q", p.Subject)
+	}
+
+	u, _ := url.Parse("spiffe://prod/ops/console")
+	p = s.principalFromCert(&x509.Certificate{URIs: []*url.URL{u}})
+	if p.Subject != "ops" || !p.Has(ScopeAdmin) || p.Tenant != DefaultTenant {
+		t.Errorf("Unexpected principal for ops cert: %+v", p)
+	}
+
+	p = s.principalFromCert(&x509.Certificate{Subject: pkix.Name{CommonName: "stranger"}})
+	if p.Subject != "stranger" || len(p.Scopes) != 0 {
+		t.Errorf("Expected unmapped cert to hold no scopes, got %+v", p)
+	}
+}
@@ -11,7 +11,6 @@
 package gateway
 
 import (
-	"crypto/tls"
 	"time"
 
 	"github.com/spf13/viper"
@@ -45,17 +44,21 @@ func LoadConfig(cfg *Config, filePath, envPrefix string) {
         _ = v.ReadInConfig() // treat missing file as non‐fatal
     }
 
-    // Register custom decode hook for TLS config (expects   tls_cert, tls_key)
+    // TLS material is referenced by path (tls_cert, tls_key, tls_client_ca)
+    // so New can reload it when the files are rotated.
     v.SetDefault("tls_cert", "")
     v.SetDefault("tls_key", "")
+    v.SetDefault("tls_client_ca", "")
 
     _ = v.Unmarshal(&cfg)
 
     certPath := v.GetString("tls_cert")
     keyPath := v.GetString("tls_key")
     if certPath != "" && keyPath != "" {
-        if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
-            cfg.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
-        }
+        cfg.TLSCertPath = certPath
+        cfg.TLSKeyPath = keyPath
+    }
+    if ca := v.GetString("tls_client_ca"); ca != "" {
+        cfg.ClientCAPath = ca
     }
 }
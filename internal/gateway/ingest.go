@@ -0,0 +1,161 @@
+// internal/gateway/ingest.go
+// Ingest limits protect the gateway from agents that sample too fast or ship
+// oversized trees.  Every tenant quota carries two sets of IngestLimits:
+//
+//   - Agent  – applied to each agent stream on its own
+//   - Ingest – applied to the sum of all streams of the tenant
+//
+// A chunk breaking any limit ends the stream with codes.ResourceExhausted and
+// is counted in flarego_gateway_ingest_rejected_total{tenant,reason}.  Rate
+// rejections carry a "flarego-retry-after" trailer (Go duration) which the
+// agent exporter honours before reconnecting, so throttled agents back off
+// instead of hammering the gateway with reconnects.
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Voskan/flarego/internal/metrics"
+	"github.com/Voskan/flarego/internal/util"
+	"github.com/Voskan/flarego/pkg/flamegraph"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RetryAfterTrailer names the trailer carrying the back‑off hint.
+const RetryAfterTrailer = "flarego-retry-after"
+
+// IngestLimits bounds chunk traffic; zero values mean unlimited.
+type IngestLimits struct {
+    ChunksPerSec    float64 `mapstructure:"chunks_per_sec" json:"chunks_per_sec,omitempty"`
+    BytesPerSec     float64 `mapstructure:"bytes_per_sec" json:"bytes_per_sec,omitempty"`
+    MaxPayloadBytes int     `mapstructure:"max_payload_bytes" json:"max_payload_bytes,omitempty"`
+    MaxNodes        int     `mapstructure:"max_nodes" json:"max_nodes,omitempty"`
+}
+
+// ingestLimiter enforces one IngestLimits value.
+type ingestLimiter struct {
+    limits IngestLimits
+    chunks *util.TokenBucket
+    bytes  *util.TokenBucket
+}
+
+func newIngestLimiter(l IngestLimits) *ingestLimiter {
+    return &ingestLimiter{
+        limits: l,
+        chunks: util.NewTokenBucket(l.ChunksPerSec),
+        bytes:  util.NewTokenBucket(l.BytesPerSec),
+    }
+}
+
+// ingestError is a rejected chunk: the metric reason plus the status returned
+// to the agent.
+type ingestError struct {
+    reason     string
+    retryAfter time.Duration
+    msg        string
+}
+
+func (e *ingestError) Error() string { return e.msg }
+
+// GRPCStatus lets status.Code/FromError see ResourceExhausted.
+func (e *ingestError) GRPCStatus() *status.Status {
+    return status.New(codes.ResourceExhausted, e.msg)
+}
+
+// buckets lists the limiter's buckets and what a chunk of size takes from
+// each, in the order rejection reports them.
+func (l *ingestLimiter) buckets(size int) ([]*util.TokenBucket, []float64) {
+    return []*util.TokenBucket{l.chunks, l.bytes}, []float64{1, float64(size)}
+}
+
+// rejection describes the refusal of bucket i (as listed by buckets); scope
+// ("agent" or "tenant") prefixes the reason.
+func (l *ingestLimiter) rejection(scope string, i int, wait time.Duration) *ingestError {
+    if i == 0 {
+        return &ingestError{
+            reason:     scope + "_chunk_rate",
+            retryAfter: wait,
+            msg:        fmt.Sprintf("%s chunk rate above %g/s", scope, l.limits.ChunksPerSec),
+        }
+    }
+    return &ingestError{
+        reason:     scope + "_byte_rate",
+        retryAfter: wait,
+        msg:        fmt.Sprintf("%s byte rate above %g B/s", scope, l.limits.BytesPerSec),
+    }
+}
+
+// admitChunk checks payload against the tenant's size limits, the agent's and
+// then the tenant's rates.  Tokens are only taken once every limit admits the
+// chunk, in one util.TakeAll decision, so a rejection by one bucket does not
+// drain the others and concurrent streams of a tenant cannot overrun it.  It
+// returns the decoded tree when a node limit forced decoding, so handleChunk
+// need not decode again.
+func (s *Server) admitChunk(t *tenant, agent *ingestLimiter, data []byte) (*flamegraph.Frame, error) {
+    var (
+        root *flamegraph.Frame
+        rej  *ingestError
+    )
+    maxPayload := minPositive(t.quota.Agent.MaxPayloadBytes, t.quota.Ingest.MaxPayloadBytes)
+    maxNodes := minPositive(t.quota.Agent.MaxNodes, t.quota.Ingest.MaxNodes)
+
+    switch {
+    case maxPayload > 0 && len(data) > maxPayload:
+        rej = &ingestError{reason: "payload_size", msg: fmt.Sprintf("payload %d bytes exceeds limit %d", len(data), maxPayload)}
+    case maxNodes > 0:
+        var f flamegraph.Frame
+        if err := json.Unmarshal(data, &f); err == nil {
+            root = &f
+            if n := countNodes(root); n > maxNodes {
+                rej = &ingestError{reason: "node_count", msg: fmt.Sprintf("tree has %d nodes, limit %d", n, maxNodes)}
+            }
+        }
+    }
+    if rej == nil {
+        ab, an := agent.buckets(len(data))
+        tb, tn := t.ingest.buckets(len(data))
+        if i, wait := util.TakeAll(append(ab, tb...), append(an, tn...)); i >= len(ab) {
+            rej = t.ingest.rejection("tenant", i-len(ab), wait)
+        } else if i >= 0 {
+            rej = agent.rejection("agent", i, wait)
+        }
+    }
+    if rej != nil {
+        metrics.IngestRejectedTotal.WithLabelValues(t.name, rej.reason).Inc()
+        return nil, rej
+    }
+    return root, nil
+}
+
+// setRetryAfter attaches the back‑off hint of err (if any) to the stream.
+func setRetryAfter(stream grpc.ServerStream, err error) {
+    if rej, ok := err.(*ingestError); ok && rej.retryAfter > 0 {
+        stream.SetTrailer(metadata.Pairs(RetryAfterTrailer, rej.retryAfter.Round(time.Millisecond).String()))
+    }
+}
+
+func countNodes(f *flamegraph.Frame) int {
+    n := 1
+    for _, c := range f.Children {
+        n += countNodes(c)
+    }
+    return n
+}
+
+// minPositive returns the smaller non‑zero value (0 when both are unset).
+func minPositive(a, b int) int {
+    switch {
+    case a <= 0:
+        return b
+    case b <= 0:
+        return a
+    case a < b:
+        return a
+    }
+    return b
+}
@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAdmitChunk(t *testing.T) {
+	s, err := New(Config{DefaultQuota: TenantQuota{
+		Agent:  IngestLimits{ChunksPerSec: 2, MaxPayloadBytes: 128, MaxNodes: 3},
+		Ingest: IngestLimits{ChunksPerSec: 3},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tn, _ := s.tenantFor("team-a")
+	small := []byte(`{"name":"root","children":{"a":{"name":"a"}}}`)
+
+	a1 := newIngestLimiter(tn.quota.Agent)
+	for i := 0; i < 2; i++ {
+		if _, err := s.admitChunk(tn, a1, small); err != nil {
+			t.Fatalf("chunk %d: expected admission, got %v", i, err)
+		}
+	}
+	_, err = s.admitChunk(tn, a1, small)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Expected ResourceExhausted for agent rate, got %v", err)
+	}
+	if rej := err.(*ingestError); rej.reason != "agent_chunk_rate" || rej.retryAfter <= 0 {
+		t.Errorf("Unexpected rejection %+v", rej)
+	}
+
+	// A second agent has its own budget but shares the tenant's.
+	a2 := newIngestLimiter(tn.quota.Agent)
+	if _, err := s.admitChunk(tn, a2, small); err != nil {
+		t.Fatalf("Expected second agent to be admitted, got %v", err)
+	}
+	if _, err := s.admitChunk(tn, a2, small); err == nil || err.(*ingestError).reason != "tenant_chunk_rate" {
+		t.Errorf("Expected tenant_chunk_rate rejection, got %v", err)
+	}
+
+	a3 := newIngestLimiter(tn.quota.Agent)
+	big := make([]byte, 129)
+	if _, err := s.admitChunk(tn, a3, big); err == nil || err.(*ingestError).reason != "payload_size" {
+		t.Errorf("Expected payload_size rejection, got %v", err)
+	}
+	deep := []byte(`{"name":"r","children":{"a":{"name":"a","children":{"b":{"name":"b","children":{"c":{"name":"c"}}}}}}}`)
+	if _, err := s.admitChunk(tn, a3, deep); err == nil || err.(*ingestError).reason != "node_count" {
+		t.Errorf("Expected node_count rejection, got %v", err)
+	}
+}
+
+func TestAdmitChunkRejectionDoesNotCharge(t *testing.T) {
+	s, err := New(Config{DefaultQuota: TenantQuota{
+		Agent:  IngestLimits{ChunksPerSec: 2, BytesPerSec: 100},
+		Ingest: IngestLimits{ChunksPerSec: 1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tn, _ := s.tenantFor("team-a")
+	chunk := []byte(`{"name":"root"}`)
+
+	a1 := newIngestLimiter(tn.quota.Agent)
+	if _, err := s.admitChunk(tn, a1, chunk); err != nil {
+		t.Fatalf("Expected admission, got %v", err)
+	}
+	a2 := newIngestLimiter(tn.quota.Agent)
+	if _, err := s.admitChunk(tn, a2, chunk); err == nil || err.(*ingestError).reason != "tenant_chunk_rate" {
+		t.Fatalf("Expected tenant_chunk_rate rejection, got %v", err)
+	}
+	if ok, _ := a2.chunks.Check(2); !ok {
+		t.Error("Expected the tenant rejection to leave the agent's chunk budget untouched")
+	}
+
+	// A byte rejection must not take a chunk token of the same agent.
+	a3 := newIngestLimiter(tn.quota.Agent)
+	a3.bytes.Take(100)
+	if _, err := s.admitChunk(tn, a3, chunk); err == nil || err.(*ingestError).reason != "agent_byte_rate" {
+		t.Fatalf("Expected agent_byte_rate rejection, got %v", err)
+	}
+	if ok, _ := a3.chunks.Check(2); !ok {
+		t.Error("Expected the byte rejection to leave the chunk budget untouched")
+	}
+}
+
+func TestAdmitChunkConcurrentTenantLimit(t *testing.T) {
+	s, err := New(Config{DefaultQuota: TenantQuota{
+		Agent:  IngestLimits{ChunksPerSec: 10},
+		Ingest: IngestLimits{ChunksPerSec: 5},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tn, _ := s.tenantFor("team-a")
+	chunk := []byte(`{"name":"root"}`)
+
+	var admitted atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			agent := newIngestLimiter(tn.quota.Agent)
+			<-start
+			if _, err := s.admitChunk(tn, agent, chunk); err == nil {
+				admitted.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	if n := admitted.Load(); n != 5 {
+		t.Errorf("Expected 5 chunks admitted by the tenant limit, got %d", n)
+	}
+}
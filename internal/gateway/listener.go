@@ -1,7 +1,15 @@
 // internal/gateway/listener.go
 // HTTP listener that exposes:
-//   - /ws   – WebSocket endpoint streaming flamegraph chunks to UI clients
-//   - /metrics – optional Prometheus scrape endpoint
+//   - /ws   – WebSocket endpoint streaming flamegraph chunks to UI clients;
+//     ?selector= and ?envelope=1 as in selector.go (read)
+//   - /metrics – optional Prometheus scrape endpoint (unauthenticated)
+//   - /admin/* – operator endpoints (see admin.go) (admin)
+//   - /artifacts/<name> – alert evidence .fgo files (see alerting.go) (read)
+//   - /render.svg – SVG flamegraph of the retained data (see render.go) (read)
+//   - /query – the retained data as v2 Frame JSON, ?transform= and ?butterfly=
+//     (see query.go) (read)
+//
+// The scope each route requires is declared where it is registered.
 //
 // The listener is purposely separate from the gRPC server so that deployments
 // can route HTTP and gRPC traffic through different ports or ALBs.
@@ -35,7 +43,9 @@ func (s *Server) StartHTTP(cfg HTTPConfig) *http.Server {
         cfg.WriteTimeout = 10 * time.Second
     }
     mux := http.NewServeMux()
-    mux.HandleFunc("/ws", s.handleWebSocket)
+    mux.Handle("/ws", s.requireScope(ScopeRead, http.HandlerFunc(s.handleWebSocket)))
+    s.registerAdminRoutes(mux)
+    s.registerReadRoutes(mux)
     if cfg.EnableMetrics {
         metrics.Register()
         mux.Handle("/metrics", promhttp.Handler())
@@ -57,6 +67,17 @@ func (s *Server) StartHTTP(cfg HTTPConfig) *http.Server {
     return srv
 }
 
+// registerReadRoutes mounts the read‑scope HTTP handlers returning profile
+// data; each request is audited like an /admin call.
+func (s *Server) registerReadRoutes(mux *http.ServeMux) {
+    read := func(h http.HandlerFunc) http.Handler {
+        return s.requireScope(ScopeRead, s.auditRead(h))
+    }
+    mux.Handle("/artifacts/", read(s.handleArtifact))
+    mux.Handle("/render.svg", read(s.handleRenderSVG))
+    mux.Handle("/query", read(s.handleQuery))
+}
+
 // --------------------------------------------------------------------------------------------------------------------
 // WebSocket streaming
 // --------------------------------------------------------------------------------------------------------------------
@@ -71,13 +92,26 @@ var wsUpgrader = websocket.Upgrader{
 }
 
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+    // Subscribe before upgrading so quota errors are still plain HTTP.
+    p := PrincipalFromContext(r.Context())
+    opt, err := subscribeOptionsFromQuery(r.URL.Query())
+    if err != nil {
+        s.auditSubscribe(p, r.RemoteAddr, r.URL.Path, redactedQuery(r.URL.Query()), err)
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    ch, unregister, err := s.SubscribeWith(p.Tenant, opt)
+    s.auditSubscribe(p, r.RemoteAddr, r.URL.Path, redactedQuery(r.URL.Query()), err)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusTooManyRequests)
+        return
+    }
     conn, err := wsUpgrader.Upgrade(w, r, nil)
     if err != nil {
+        unregister()
         s.Logger().Warn("ws upgrade", zap.Error(err))
         return
     }
-
-    ch, unregister := s.Subscribe()
     metrics.Subscribers.Inc()
     defer func() {
         unregister()
@@ -0,0 +1,92 @@
+// internal/gateway/query.go
+// GET /query returns the caller's tenant as an ordered v2 JSON document
+// (flamegraph.EncodeJSON) merged from every chunk still in the retention
+// store, so scripts can analyse the live fleet without a WebSocket client.
+// Both /query and /render.svg accept repeatable transform parameters in the
+// syntax of `--transform` (flamegraph.ParseTransform), applied in order:
+//
+//	/query?transform=ignore%3D%5Eruntime%5C.&transform=prune%3D1%25
+//
+// transform=invert gives the bottom‑up tree, transform=group=module the tree
+// by Go module.  /query?butterfly=RE returns the flamegraph.ButterflyView of
+// every function matching RE instead of the tree, computed after the
+// transforms.
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"go.uber.org/zap"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    p := PrincipalFromContext(r.Context())
+    t, err := s.tenantFor(p.Tenant)
+    if err != nil {
+        http.NotFound(w, r)
+        return
+    }
+    q := r.URL.Query()
+    var fly *regexp.Regexp
+    if v := q.Get("butterfly"); v != "" {
+        if fly, err = regexp.Compile(v); err != nil {
+            http.Error(w, "butterfly: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+    }
+    root, err := queryTree(t, q)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", "no-store")
+    if fly != nil {
+        err = json.NewEncoder(w).Encode(flamegraph.Butterflies(flamegraph.WithoutBands(root), fly))
+    } else {
+        var opt flamegraph.JSONOptions
+        if p.Tenant != "" {
+            opt.Meta = map[string]string{"tenant": p.Tenant}
+        }
+        err = flamegraph.EncodeJSON(w, root, opt)
+    }
+    if err != nil {
+        s.Logger().Debug("query write", zap.Error(err))
+    }
+}
+
+// queryTree merges the retained chunks of t and applies the transforms of q.
+func queryTree(t *tenant, q url.Values) (*flamegraph.Frame, error) {
+    tr, err := flamegraph.ParseTransforms(q["transform"])
+    if err != nil {
+        return nil, fmt.Errorf("transform: %w", err)
+    }
+    root := t.snapshot()
+    if tr != nil {
+        root = tr(root)
+    }
+    return root, nil
+}
+
+// snapshot merges the retained chunks of t into one tree.
+func (t *tenant) snapshot() *flamegraph.Frame {
+    root := flamegraph.New("root")
+    for _, data := range t.store.ReadAll() {
+        var f flamegraph.Frame
+        if err := json.Unmarshal(data, &f); err != nil {
+            continue
+        }
+        root.Merge(&f)
+    }
+    return root
+}
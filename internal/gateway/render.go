@@ -0,0 +1,68 @@
+// internal/gateway/render.go
+// GET /render.svg renders the caller's tenant as a static SVG flamegraph
+// (pkg/flamegraph.RenderSVG) built from every chunk still in the retention
+// store, so dashboards, chat bots and tickets can embed a current picture
+// without running the web UI.  Query parameters mirror `flarego render`:
+//
+//	width, min_width, title, search, icicle=1
+//
+// plus the transform parameters of /query (see query.go).
+package gateway
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+func (s *Server) handleRenderSVG(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    p := PrincipalFromContext(r.Context())
+    t, err := s.tenantFor(p.Tenant)
+    if err != nil {
+        http.NotFound(w, r)
+        return
+    }
+
+    q := r.URL.Query()
+    opt := flamegraph.SVGOptions{
+        Title:  q.Get("title"),
+        Search: q.Get("search"),
+        Icicle: q.Get("icicle") == "1" || q.Get("icicle") == "true",
+    }
+    if v := q.Get("width"); v != "" {
+        if opt.Width, err = strconv.Atoi(v); err != nil || opt.Width < 100 || opt.Width > 10000 {
+            http.Error(w, "width must be between 100 and 10000", http.StatusBadRequest)
+            return
+        }
+    }
+    if v := q.Get("min_width"); v != "" {
+        if opt.MinWidth, err = strconv.ParseFloat(v, 64); err != nil {
+            http.Error(w, "bad min_width", http.StatusBadRequest)
+            return
+        }
+    }
+
+    root, err := queryTree(t, q)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    var buf bytes.Buffer
+    if err := flamegraph.RenderSVG(&buf, root, opt); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("Content-Type", "image/svg+xml")
+    w.Header().Set("Cache-Control", "no-store")
+    if _, err := buf.WriteTo(w); err != nil {
+        s.Logger().Debug("render write", zap.Error(err))
+    }
+}
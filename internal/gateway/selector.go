@@ -0,0 +1,133 @@
+// internal/gateway/selector.go
+// Subscription options for clients that record rather than display: a label
+// selector restricting the subscription to matching agents, and envelopes
+// that carry the producing agent with every chunk so a recording can tell
+// the fleet's streams apart.
+//
+// gRPC subscribers (UIService.StreamFlamegraphs) pass the options as
+// metadata, WebSocket subscribers as query parameters of /ws:
+//
+//	flarego-selector: service=api,env=prod     ?selector=service%3Dapi
+//	flarego-envelope: 1                        ?envelope=1
+//
+// A selector with a pair that is not key=value is rejected (InvalidArgument,
+// or 400 on /ws) rather than widened to every agent.  Agents label their
+// stream with flarego-labels (LabelsMetadata).  Retained
+// chunks carry no agent, so selected or enveloped subscriptions are live only.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Subscription metadata keys (gRPC) / query parameters (/ws).
+const (
+    SelectorMetadata = "flarego-selector"
+    EnvelopeMetadata = "flarego-envelope"
+)
+
+// SubscribeOptions refine a subscription; the zero value sees every chunk of
+// the tenant as plain Frame JSON.
+type SubscribeOptions struct {
+    Selector map[string]string // all labels must match the agent's
+    Envelope bool              // deliver ChunkEnvelope JSON
+}
+
+// ChunkEnvelope is the payload enveloped subscribers receive.
+type ChunkEnvelope struct {
+    Time       time.Time         `json:"time"` // when the gateway received the chunk
+    Agent      string            `json:"agent,omitempty"`
+    Subject    string            `json:"subject,omitempty"`
+    RemoteAddr string            `json:"remote_addr,omitempty"`
+    Labels     map[string]string `json:"labels,omitempty"`
+    Root       json.RawMessage   `json:"root"`
+}
+
+// live reports whether the subscription must skip retained chunks.
+func (o SubscribeOptions) live() bool { return len(o.Selector) > 0 || o.Envelope }
+
+// matches reports whether chunks of agent pass the selector.
+func (o SubscribeOptions) matches(agent *AgentInfo) bool {
+    if len(o.Selector) == 0 {
+        return true
+    }
+    if agent == nil {
+        return false
+    }
+    for k, v := range o.Selector {
+        if got, ok := agent.Labels[k]; !ok || got != v {
+            return false
+        }
+    }
+    return true
+}
+
+// newEnvelope wraps data.  Only the immutable fields of agent are read.
+func newEnvelope(agent *AgentInfo, data []byte) []byte {
+    env := ChunkEnvelope{Time: time.Now(), Root: data}
+    if agent != nil {
+        env.Agent, env.Subject, env.RemoteAddr, env.Labels = agent.ID, agent.Subject, agent.RemoteAddr, agent.Labels
+    }
+    out, err := json.Marshal(env)
+    if err != nil {
+        return data // data is not valid JSON; pass it through untouched
+    }
+    return out
+}
+
+// ParseSelector parses comma separated key=value lists like ParseLabels but
+// rejects malformed pairs: a selector that silently lost its pairs would
+// match every agent of the tenant.  Blank input yields a nil selector.
+func ParseSelector(lists ...string) (map[string]string, error) {
+    var sel map[string]string
+    for _, v := range lists {
+        for _, pair := range strings.Split(v, ",") {
+            pair = strings.TrimSpace(pair)
+            if pair == "" {
+                continue
+            }
+            k, val, ok := strings.Cut(pair, "=")
+            if !ok || k == "" {
+                return nil, fmt.Errorf("selector %q: want key=value, got %q", v, pair)
+            }
+            if sel == nil {
+                sel = make(map[string]string)
+            }
+            sel[k] = val
+        }
+    }
+    return sel, nil
+}
+
+// subscribeOptionsFromContext reads the options of a gRPC subscriber, also
+// returning them as audit filters.
+func subscribeOptionsFromContext(ctx context.Context) (SubscribeOptions, url.Values, error) {
+    md, _ := metadata.FromIncomingContext(ctx)
+    q := url.Values{}
+    for _, v := range md.Get(SelectorMetadata) {
+        q.Add("selector", v)
+    }
+    for _, v := range md.Get(EnvelopeMetadata) {
+        q.Add("envelope", v)
+    }
+    opt, err := subscribeOptionsFromQuery(q)
+    return opt, q, err
+}
+
+// subscribeOptionsFromQuery reads the options of a /ws subscriber.
+func subscribeOptionsFromQuery(q url.Values) (SubscribeOptions, error) {
+    env, _ := strconv.ParseBool(q.Get("envelope"))
+    sel, err := ParseSelector(q["selector"]...)
+    if err != nil {
+        return SubscribeOptions{}, err
+    }
+    return SubscribeOptions{Selector: sel, Envelope: env}, nil
+}
@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	agentpb "github.com/Voskan/flarego/internal/proto"
+)
+
+func TestParseSelector(t *testing.T) {
+	sel, err := ParseSelector("service=api, env=prod", "", "zone=")
+	if err != nil || len(sel) != 3 || sel["service"] != "api" || sel["env"] != "prod" || sel["zone"] != "" {
+		t.Errorf("Unexpected selector %v, %v", sel, err)
+	}
+	if sel, err := ParseSelector("", " , "); err != nil || sel != nil {
+		t.Errorf("Expected blank input to select everything, got %v, %v", sel, err)
+	}
+	for _, bad := range []string{"service:api", "service=api,env", "=prod"} {
+		if _, err := ParseSelector(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestStreamFlamegraphsRejectsBadSelector(t *testing.T) {
+	s, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Serve(ctx, ln)
+
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sctx := metadata.AppendToOutgoingContext(ctx, SelectorMetadata, "service:api")
+	stream, err := agentpb.NewUIServiceClient(conn).StreamFlamegraphs(sctx, &emptypb.Empty{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a malformed selector, got %v", err)
+	}
+}
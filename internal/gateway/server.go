@@ -8,13 +8,21 @@ package gateway
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
 	"net"
 	"sync"
 	"time"
 
-	"github.com/Voskan/flarego/internal/gateway/retention"
+	"github.com/Voskan/flarego/internal/gateway/alerts"
+	"github.com/Voskan/flarego/internal/gateway/alerts/sinks"
+	"github.com/Voskan/flarego/internal/gateway/audit"
 	"github.com/Voskan/flarego/internal/logging"
+	"github.com/Voskan/flarego/internal/metrics"
+	"github.com/Voskan/flarego/internal/util"
 	agentpb "github.com/Voskan/flarego/internal/proto"
+	"github.com/Voskan/flarego/pkg/flamegraph"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -31,23 +39,58 @@ type Config struct {
     AuthToken    string        // optional static bearer token ("" means open)
     RetentionDur time.Duration // how long to keep a chunk in memory (0 => 15m)
     MaxClients   int           // soft cap for connected subscribers
+    Tenants      []TenantQuota // per‑tenant quotas (see tenants.go)
+    DefaultQuota TenantQuota   // quota for tenants not listed in Tenants
     TLSCertPath  string        // path to TLS certificate (PEM)
     TLSKeyPath   string        // path to TLS key (PEM)
+    JWT          JWTConfig     // optional JWT verification (see auth.go)
+    TokenFile    string        // optional static tokens with scopes (see authz.go)
+
+    // Mutual TLS (optional; requires TLSCertPath/TLSKeyPath, reloaded on change).
+    ClientCAPath      string // CA bundle verifying agent certificates ("" disables)
+    RequireClientCert bool   // reject TLS clients without a verified certificate
+
+    // Alert webhook delivery (optional).
+    WebhookURL       string // endpoint receiving alert POSTs ("" disables)
+    WebhookSecret    string // HMAC key for X-FlareGo-Signature
+    WebhookOutboxDir string // persistent outbox; "" keeps queue in memory
+
+    // Alerting (optional).
+    AlertRules     []alerts.RuleSpec // evaluated against every chunk
+    ArtifactDir    string            // alert evidence (.fgo) directory; "" disables capture
+    PublicURL      string            // external HTTP base URL used in evidence links
+    BaselineWindow time.Duration     // history diffed against on fire (0 => 1m)
+    JiraEmail      string            // credentials for "jira:" sinks
+    JiraToken      string
+
+    // Audit trail (optional, see auditing.go).
+    AuditLogPath    string // JSON-lines file; "" disables auditing
+    AuditMaxBytes   int64  // rotate after this size (0 => 100 MiB)
+    AuditMaxBackups int    // rotated files kept (0 => 5)
 }
 
-// Server implements the generated gRPC service and fans‑out chunks to all
-// attached UI subscribers (via Subscribe()) while writing them to a Retention
-// Store for replay.
+// Server implements the generated gRPC service and fans‑out chunks to the
+// attached UI subscribers of the same tenant (via Subscribe()) while writing
+// them to that tenant's Retention Store for replay.
 type Server struct {
     agentpb.UnimplementedGatewayServiceServer
     agentpb.UnimplementedUIServiceServer
 
     cfg     Config
-    store   retention.Store
-    subsMu  sync.RWMutex
-    subs    map[chan []byte]struct{}
     grpcSrv *grpc.Server
     jwt     jwtHelper
+    tokens  map[string]*Principal // static token file entries
+    certs   []CertIdentity        // client certificate rules from the token file
+
+    tenantsMu sync.RWMutex
+    tenants   map[string]*tenant     // lazily created, see tenants.go
+    quotas    map[string]TenantQuota // cfg.Tenants keyed by name
+
+    webhooks []*sinks.WebhookSink   // default sink (cfg.WebhookURL) first, then per‑rule ones
+    jiras    []*sinks.JiraSink      // per‑rule Jira sinks, closed on shutdown
+    rules    []scopedRule           // compiled alert rules, instantiated per tenant
+    evidence *alerts.EvidenceStore // nil unless cfg.ArtifactDir is set
+    audit    *audit.Log            // nil unless cfg.AuditLogPath is set
 }
 
 // New returns a ready‑to‑serve Gateway.  The caller must invoke ListenAndServe.
@@ -56,9 +99,60 @@ func New(cfg Config) (*Server, error) {
         cfg.RetentionDur = 15 * time.Minute
     }
     s := &Server{
-        cfg:   cfg,
-        store: retention.NewInMem(cfg.RetentionDur),
-        subs:  make(map[chan []byte]struct{}),
+        cfg:     cfg,
+        tenants: make(map[string]*tenant),
+        quotas:  make(map[string]TenantQuota, len(cfg.Tenants)),
+    }
+    for _, q := range cfg.Tenants {
+        s.quotas[q.Name] = q
+    }
+
+    if cfg.AuditLogPath != "" {
+        l, err := audit.Open(cfg.AuditLogPath, cfg.AuditMaxBytes, cfg.AuditMaxBackups)
+        if err != nil {
+            return nil, err
+        }
+        s.audit = l
+    }
+
+    jh, err := newJWTHelper(cfg.JWT)
+    if err != nil {
+        return nil, err
+    }
+    s.jwt = jh
+    if cfg.TokenFile != "" {
+        if s.tokens, err = LoadTokenFile(cfg.TokenFile); err != nil {
+            return nil, err
+        }
+        if s.certs, err = LoadCertIdentities(cfg.TokenFile); err != nil {
+            return nil, err
+        }
+    }
+
+    if cfg.WebhookURL != "" {
+        wh := sinks.NewWebhookSink(cfg.WebhookURL)
+        wh.Secret = cfg.WebhookSecret
+        wh.OutboxDir = cfg.WebhookOutboxDir
+        if err := wh.Start(); err != nil {
+            return nil, err
+        }
+        s.webhooks = append(s.webhooks, wh)
+    }
+    if err := s.setupAlerts(); err != nil {
+        return nil, err
+    }
+
+    // Certificates given as paths are reloaded from disk when they change;
+    // an explicit TLSConfig is used as is.
+    if cfg.TLSConfig == nil && cfg.TLSCertPath != "" {
+        r, err := util.NewCertReloader(cfg.TLSCertPath, cfg.TLSKeyPath, cfg.ClientCAPath)
+        if err != nil {
+            return nil, err
+        }
+        cfg.TLSConfig = r.ServerConfig(cfg.RequireClientCert)
+        s.cfg.TLSConfig = cfg.TLSConfig
+    } else if cfg.ClientCAPath != "" {
+        return nil, errors.New("gateway: ClientCAPath requires TLSCertPath/TLSKeyPath")
     }
 
     var opts []grpc.ServerOption
@@ -93,6 +187,12 @@ func New(cfg Config) (*Server, error) {
         },
     ))
 
+    // Authentication + per‑method scope checks (authz.go).
+    opts = append(opts,
+        grpc.ChainUnaryInterceptor(s.unaryAuthInterceptor()),
+        grpc.ChainStreamInterceptor(s.streamAuthInterceptor()),
+    )
+
     s.grpcSrv = grpc.NewServer(opts...)
     agentpb.RegisterGatewayServiceServer(s.grpcSrv, s)
     agentpb.RegisterUIServiceServer(s.grpcSrv, s)
@@ -115,12 +215,24 @@ func (s *Server) ListenAndServe(ctx context.Context) error {
     if err != nil {
         return err
     }
+    return s.Serve(ctx, ln)
+}
 
+// Serve is ListenAndServe on an existing listener, which it closes.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
     go func() {
         <-ctx.Done()
         // GracefulStop drains existing RPCs; Close closes listener.
         s.grpcSrv.GracefulStop()
         _ = ln.Close()
+        for _, wh := range s.webhooks {
+            _ = wh.Close()
+        }
+        for _, js := range s.jiras {
+            _ = js.Close()
+        }
+        s.jwt.close()
+        _ = s.audit.Close()
     }()
 
     logging.Sugar().Infow("gateway listening", "addr", ln.Addr().String())
@@ -128,116 +240,140 @@ func (s *Server) ListenAndServe(ctx context.Context) error {
 }
 
 // Stream is the hot path: agents push FlamegraphChunk frames continuously.
+// Chunks land in the caller's tenant only; the agent is listed in that
+// tenant's registry for the lifetime of the stream.
 func (s *Server) Stream(stream agentpb.GatewayService_StreamServer) error {
-    // Optional bearer‑token auth.
-    if s.cfg.AuthToken != "" {
-        md, ok := metadata.FromIncomingContext(stream.Context())
-        if !ok || len(md.Get("authorization")) == 0 {
-            return status.Error(codes.Unauthenticated, "missing auth token")
-        }
-        tok := md.Get("authorization")[0]
-        expected := "Bearer " + s.cfg.AuthToken
-        if tok != expected {
-            return status.Error(codes.PermissionDenied, "invalid auth token")
-        }
+    ctx := stream.Context()
+    p := PrincipalFromContext(ctx)
+    t, err := s.tenantFor(p.Tenant)
+    if err != nil {
+        return status.Error(codes.PermissionDenied, err.Error())
+    }
+    remote := peerAddr(ctx)
+    now := time.Now()
+    agent := &AgentInfo{
+        ID:          agentID(),
+        Subject:     p.Subject,
+        Tenant:      t.name,
+        RemoteAddr:  remote,
+        ConnectedAt: now,
+        LastSeen:    now,
+        Labels:      streamLabels(ctx),
+    }
+    unregister, err := t.registerAgent(agent)
+    if err != nil {
+        return err
     }
+    defer unregister()
+    limiter := newIngestLimiter(t.quota.Agent)
 
     // Read chunks until EOF.
     for {
         chunk, err := stream.Recv()
         if err != nil {
-            if status.Code(err) == codes.Canceled || status.Code(err) == codes.Unavailable {
+            if err == io.EOF || status.Code(err) == codes.Canceled || status.Code(err) == codes.Unavailable {
                 return nil // client disconnected
             }
             logging.Sugar().Warnw("stream recv", "err", err)
             return err
         }
-        s.handleChunk(chunk.Payload)
+        metrics.ChunksReceivedTotal.Inc()
+        root, err := s.admitChunk(t, limiter, chunk.Payload)
+        if err != nil {
+            logging.Sugar().Warnw("ingest limit", "tenant", t.name, "subject", p.Subject, "agent", agent.ID, "err", err)
+            setRetryAfter(stream, err)
+            return err
+        }
+        t.touchAgent(agent.ID)
+        s.handleDecoded(t, agent, chunk.Payload, root)
     }
 }
 
-// StreamFlamegraphs is the UI service endpoint that streams flamegraph chunks to clients.
+// StreamFlamegraphs is the UI service endpoint that streams flamegraph chunks
+// of the caller's tenant to clients (see selector.go for the options).
 func (s *Server) StreamFlamegraphs(req *emptypb.Empty, stream agentpb.UIService_StreamFlamegraphsServer) error {
-    // Optional bearer‑token auth.
-    if s.cfg.AuthToken != "" {
-        md, ok := metadata.FromIncomingContext(stream.Context())
-        if !ok || len(md.Get("authorization")) == 0 {
-            return status.Error(codes.Unauthenticated, "missing auth token")
-        }
-        tok := md.Get("authorization")[0]
-        expected := "Bearer " + s.cfg.AuthToken
-        if tok != expected {
-            return status.Error(codes.PermissionDenied, "invalid auth token")
-        }
+    ctx := stream.Context()
+    p := PrincipalFromContext(ctx)
+    opt, filters, err := subscribeOptionsFromContext(ctx)
+    if err != nil {
+        s.auditSubscribe(p, peerAddr(ctx), "/agentpb.UIService/StreamFlamegraphs", filters, err)
+        return status.Error(codes.InvalidArgument, err.Error())
     }
+    ch, unregister, err := s.SubscribeWith(p.Tenant, opt)
+    s.auditSubscribe(p, peerAddr(ctx), "/agentpb.UIService/StreamFlamegraphs", filters, err)
+    if err != nil {
+        return err
+    }
+    t, _ := s.tenantFor(p.Tenant) // exists: Subscribe succeeded
+    defer unregister()
 
-    // Create a channel for this subscriber.
-    ch := make(chan []byte, 100) // buffered to avoid blocking the gateway
-    s.subsMu.Lock()
-    s.subs[ch] = struct{}{}
-    s.subsMu.Unlock()
-
-    // Clean up when the client disconnects.
-    defer func() {
-        s.subsMu.Lock()
-        delete(s.subs, ch)
-        s.subsMu.Unlock()
-        close(ch)
-    }()
-
-    // Send initial data from retention store.
-    for _, data := range s.store.ReadAll() {
-        if err := stream.Send(&agentpb.FlamegraphChunk{Payload: data}); err != nil {
-            return err
+    // Send initial data from the tenant's retention store (not attributable
+    // to agents, so skipped for selected or enveloped subscriptions).
+    if !opt.live() {
+        for _, data := range t.store.ReadAll() {
+            if err := stream.Send(&agentpb.FlamegraphChunk{Payload: data}); err != nil {
+                return err
+            }
         }
     }
 
     // Stream new chunks until client disconnects.
-    for data := range ch {
-        if err := stream.Send(&agentpb.FlamegraphChunk{Payload: data}); err != nil {
-            return err
+    for {
+        select {
+        case <-ctx.Done():
+            return nil
+        case data := <-ch:
+            if err := stream.Send(&agentpb.FlamegraphChunk{Payload: data}); err != nil {
+                return err
+            }
         }
     }
+}
 
-    return nil
+// handleChunk writes to the tenant's store, evaluates its alert rules and
+// broadcasts to its subscribers.
+func (s *Server) handleChunk(t *tenant, data []byte) {
+    s.handleDecoded(t, nil, data, nil)
 }
 
-// handleChunk writes to store and broadcasts to subscribers.
-func (s *Server) handleChunk(data []byte) {
+// handleDecoded is handleChunk for agent streams, which may already hold the
+// decoded tree (root may be nil).
+func (s *Server) handleDecoded(t *tenant, agent *AgentInfo, data []byte, root *flamegraph.Frame) {
     // Persist in ring buffer.
-    if err := s.store.Write(data); err != nil {
-        logging.Sugar().Warnw("retention write", "err", err)
+    if err := t.store.Write(data); err != nil {
+        logging.Sugar().Warnw("retention write", "tenant", t.name, "err", err)
     }
 
-    // Non‑blocking fan‑out.
-    s.subsMu.RLock()
-    for ch := range s.subs {
-        select {
-        case ch <- data:
-        default:
-            // Skip slow consumer to avoid head‑of‑line blocking.
-            logging.Sugar().Debug("dropping chunk to slow subscriber")
+    // Alert evaluation needs the decoded tree; skip the cost when unused.
+    if t.alerts != nil {
+        if root == nil {
+            var f flamegraph.Frame
+            if err := json.Unmarshal(data, &f); err == nil {
+                root = &f
+            }
+        }
+        if root != nil {
+            t.alerts.Observe(root)
         }
     }
-    s.subsMu.RUnlock()
+
+    t.broadcast(agent, data)
 }
 
-// Subscribe registers a UI client.  The caller must drain the returned channel
-// and invoke the unregister func when done.
-func (s *Server) Subscribe() (ch chan []byte, unregister func()) {
-    ch = make(chan []byte, 100) // buffered to avoid blocking the gateway
-    s.subsMu.Lock()
-    s.subs[ch] = struct{}{}
-    s.subsMu.Unlock()
+// Subscribe registers a UI client of tenant.  The caller must drain the
+// returned channel and invoke the unregister func when done.  Retained chunks
+// are not replayed; use the retention store for that.
+func (s *Server) Subscribe(tenant string) (ch chan []byte, unregister func(), err error) {
+    return s.SubscribeWith(tenant, SubscribeOptions{})
+}
 
-    unregister = func() {
-        s.subsMu.Lock()
-        delete(s.subs, ch)
-        s.subsMu.Unlock()
-        close(ch)
+// SubscribeWith is Subscribe with a label selector and/or enveloped chunks.
+func (s *Server) SubscribeWith(tenant string, opt SubscribeOptions) (ch chan []byte, unregister func(), err error) {
+    t, err := s.tenantFor(tenant)
+    if err != nil {
+        return nil, nil, status.Error(codes.PermissionDenied, err.Error())
     }
-
-    return ch, unregister
+    return t.subscribe(opt)
 }
 
 // Logger returns the *zap.Logger used by the server (delegates to global).
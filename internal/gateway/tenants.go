@@ -0,0 +1,282 @@
+// internal/gateway/tenants.go
+// Multi‑tenant isolation.  Every authenticated Principal belongs to exactly
+// one tenant (JWT claim, token file entry, or DefaultTenant for the legacy
+// shared token and unauthenticated setups).  Each tenant owns its own
+//
+//   - retention store  – replayed only to the same tenant's subscribers
+//   - subscriber set   – chunks never fan out across tenants
+//   - agent registry   – connected agents, listed via /admin/agents
+//   - alert engine     – rules scoped to the tenant (RuleSpec.Tenant) plus
+//     global rules, each tenant evaluated independently
+//   - quota            – concurrent agents and subscribers, ingest limits
+//     per agent and for the tenant as a whole (see ingest.go)
+//
+// Tenants are created lazily on first use.  Tenant names double as path
+// components for evidence artifacts, hence the conservative ValidTenant.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Voskan/flarego/internal/gateway/alerts"
+	"github.com/Voskan/flarego/internal/gateway/retention"
+	"github.com/Voskan/flarego/internal/logging"
+	"github.com/Voskan/flarego/internal/util"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultTenant is used when a credential carries no tenant.
+const DefaultTenant = "default"
+
+var tenantRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,63}$`)
+
+// ValidTenant reports whether name is an acceptable tenant identifier.
+func ValidTenant(name string) bool { return tenantRe.MatchString(name) }
+
+// TenantQuota limits one tenant; zero values mean unlimited.
+type TenantQuota struct {
+    Name           string `mapstructure:"name" json:"name"`
+    MaxAgents      int    `mapstructure:"max_agents" json:"max_agents,omitempty"`
+    MaxSubscribers int    `mapstructure:"max_subscribers" json:"max_subscribers,omitempty"`
+
+    Agent  IngestLimits `mapstructure:"agent" json:"agent,omitempty"`   // each agent stream
+    Ingest IngestLimits `mapstructure:"ingest" json:"ingest,omitempty"` // all streams combined
+}
+
+// AgentInfo is one entry of a tenant's agent registry.
+type AgentInfo struct {
+    ID          string    `json:"id"`
+    Subject     string    `json:"subject"`
+    Tenant      string    `json:"tenant"`
+    RemoteAddr  string    `json:"remote_addr"`
+    ConnectedAt time.Time `json:"connected_at"`
+    LastSeen    time.Time `json:"last_seen"`
+    Chunks      uint64    `json:"chunks"`
+
+    // Labels come from the LabelsMetadata header of the stream, e.g.
+    // replay=incident-4711 for `flarego replay --to-gateway`.
+    Labels map[string]string `json:"labels,omitempty"`
+}
+
+// LabelsMetadata is the gRPC metadata key carrying comma separated key=value
+// labels for an agent stream.
+const LabelsMetadata = "flarego-labels"
+
+var (
+    ErrAgentQuota      = status.Error(codes.ResourceExhausted, "tenant agent quota exceeded")
+    ErrSubscriberQuota = status.Error(codes.ResourceExhausted, "tenant subscriber quota exceeded")
+)
+
+// tenant holds all per‑tenant state.
+type tenant struct {
+    name  string
+    quota  TenantQuota
+    store  retention.Store
+    ingest *ingestLimiter
+
+    subsMu sync.RWMutex
+    subs   map[chan []byte]SubscribeOptions
+
+    agentsMu sync.Mutex
+    agents   map[string]*AgentInfo
+
+    alerts *alerts.Engine // nil when no rule applies to the tenant
+}
+
+// tenantFor returns (creating on first use) the state of tenant name.
+func (s *Server) tenantFor(name string) (*tenant, error) {
+    if name == "" {
+        name = DefaultTenant
+    }
+    s.tenantsMu.RLock()
+    t, ok := s.tenants[name]
+    s.tenantsMu.RUnlock()
+    if ok {
+        return t, nil
+    }
+    if !ValidTenant(name) {
+        return nil, fmt.Errorf("invalid tenant %q", name)
+    }
+
+    s.tenantsMu.Lock()
+    defer s.tenantsMu.Unlock()
+    if t, ok := s.tenants[name]; ok {
+        return t, nil
+    }
+    quota, ok := s.quotas[name]
+    if !ok {
+        quota = s.cfg.DefaultQuota
+    }
+    t = &tenant{
+        name:   name,
+        quota:  quota,
+        store:  retention.NewInMem(s.cfg.RetentionDur),
+        ingest: newIngestLimiter(quota.Ingest),
+        subs:   make(map[chan []byte]SubscribeOptions),
+        agents: make(map[string]*AgentInfo),
+    }
+    eng, err := s.newTenantEngine(name)
+    if err != nil {
+        return nil, err
+    }
+    t.alerts = eng
+    s.tenants[name] = t
+    return t, nil
+}
+
+// subscribe registers a subscriber channel subject to the tenant quota.
+func (t *tenant) subscribe(opt SubscribeOptions) (chan []byte, func(), error) {
+    ch := make(chan []byte, 100) // buffered to avoid blocking the gateway
+    t.subsMu.Lock()
+    if t.quota.MaxSubscribers > 0 && len(t.subs) >= t.quota.MaxSubscribers {
+        t.subsMu.Unlock()
+        return nil, nil, ErrSubscriberQuota
+    }
+    t.subs[ch] = opt
+    t.subsMu.Unlock()
+
+    var once sync.Once
+    unregister := func() {
+        once.Do(func() {
+            t.subsMu.Lock()
+            delete(t.subs, ch)
+            t.subsMu.Unlock()
+            close(ch)
+        })
+    }
+    return ch, unregister, nil
+}
+
+// broadcast performs the non‑blocking fan‑out to the tenant's subscribers.
+// agent is the stream the chunk arrived on (nil for chunks injected by the
+// gateway itself); subscribers with a selector only see chunks of agents it
+// matches, and enveloped subscribers receive a ChunkEnvelope instead.
+func (t *tenant) broadcast(agent *AgentInfo, data []byte) {
+    var envelope []byte
+    t.subsMu.RLock()
+    defer t.subsMu.RUnlock()
+    for ch, opt := range t.subs {
+        if !opt.matches(agent) {
+            continue
+        }
+        payload := data
+        if opt.Envelope {
+            if envelope == nil {
+                envelope = newEnvelope(agent, data)
+            }
+            payload = envelope
+        }
+        select {
+        case ch <- payload:
+        default:
+            // Skip slow consumer to avoid head‑of‑line blocking.
+            logging.Sugar().Debugw("dropping chunk to slow subscriber", "tenant", t.name)
+        }
+    }
+}
+
+// registerAgent adds a to the registry subject to the tenant quota.
+func (t *tenant) registerAgent(a *AgentInfo) (func(), error) {
+    t.agentsMu.Lock()
+    defer t.agentsMu.Unlock()
+    if t.quota.MaxAgents > 0 && len(t.agents) >= t.quota.MaxAgents {
+        return nil, ErrAgentQuota
+    }
+    t.agents[a.ID] = a
+    return func() {
+        t.agentsMu.Lock()
+        delete(t.agents, a.ID)
+        t.agentsMu.Unlock()
+    }, nil
+}
+
+// touchAgent records one received chunk.
+func (t *tenant) touchAgent(id string) {
+    t.agentsMu.Lock()
+    if a, ok := t.agents[id]; ok {
+        a.Chunks++
+        a.LastSeen = time.Now()
+    }
+    t.agentsMu.Unlock()
+}
+
+// listAgents returns a snapshot of the registry ordered by connect time.
+func (t *tenant) listAgents() []AgentInfo {
+    t.agentsMu.Lock()
+    out := make([]AgentInfo, 0, len(t.agents))
+    for _, a := range t.agents {
+        out = append(out, *a)
+    }
+    t.agentsMu.Unlock()
+    sort.Slice(out, func(i, j int) bool { return out[i].ConnectedAt.Before(out[j].ConnectedAt) })
+    return out
+}
+
+// LoadTenantsFile reads per‑tenant quotas from a YAML/TOML/JSON file:
+//
+//	tenants:
+//	  - name: team-a
+//	    max_agents: 50
+//	    max_subscribers: 10
+//	    agent:  { chunks_per_sec: 5, bytes_per_sec: 1048576, max_payload_bytes: 524288, max_nodes: 20000 }
+//	    ingest: { chunks_per_sec: 200, bytes_per_sec: 33554432 }
+func LoadTenantsFile(path string) ([]TenantQuota, error) {
+    v := viper.New()
+    v.SetConfigFile(path)
+    if err := v.ReadInConfig(); err != nil {
+        return nil, err
+    }
+    var out []TenantQuota
+    if err := v.UnmarshalKey("tenants", &out); err != nil {
+        return nil, err
+    }
+    for _, q := range out {
+        if !ValidTenant(q.Name) {
+            return nil, errors.New("tenants file: invalid tenant name " + q.Name)
+        }
+    }
+    return out, nil
+}
+
+// streamLabels parses the LabelsMetadata values of an incoming stream.
+func streamLabels(ctx context.Context) map[string]string {
+    md, _ := metadata.FromIncomingContext(ctx)
+    return ParseLabels(md.Get(LabelsMetadata)...)
+}
+
+// ParseLabels parses comma separated key=value lists into one map (nil when
+// empty).  Malformed pairs are ignored.
+func ParseLabels(lists ...string) map[string]string {
+    var labels map[string]string
+    for _, v := range lists {
+        for _, pair := range strings.Split(v, ",") {
+            k, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+            if !ok || k == "" {
+                continue
+            }
+            if labels == nil {
+                labels = make(map[string]string)
+            }
+            labels[k] = val
+        }
+    }
+    return labels
+}
+
+// agentID returns a registry key unique per stream.
+func agentID() string {
+    if id, err := util.New(); err == nil {
+        return id
+    }
+    return fmt.Sprintf("agent-%d", time.Now().UnixNano())
+}
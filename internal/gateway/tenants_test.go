@@ -0,0 +1,104 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	s, err := New(Config{DefaultQuota: TenantQuota{MaxSubscribers: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := s.tenantFor("team-a")
+	b, _ := s.tenantFor("team-b")
+
+	chA, unA, err := s.Subscribe("team-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unA()
+	chB, unB, err := s.Subscribe("team-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unB()
+
+	s.handleChunk(a, []byte(`{"name":"root"}`))
+
+	select {
+	case <-chA:
+	default:
+		t.Error("Expected team-a subscriber to receive its chunk")
+	}
+	select {
+	case <-chB:
+		t.Error("team-b subscriber received a team-a chunk")
+	default:
+	}
+	if n := len(a.store.ReadAll()); n != 1 {
+		t.Errorf("Expected 1 retained chunk for team-a, got %d", n)
+	}
+	if n := len(b.store.ReadAll()); n != 0 {
+		t.Errorf("Expected no retained chunks for team-b, got %d", n)
+	}
+
+	if _, _, err := s.Subscribe("team-a"); err != ErrSubscriberQuota {
+		t.Errorf("Expected ErrSubscriberQuota, got %v", err)
+	}
+	if _, err := s.tenantFor("../etc"); err == nil {
+		t.Error("Expected invalid tenant name to be rejected")
+	}
+}
+
+func TestStreamLabels(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(LabelsMetadata, "replay=inc-1, source=file", LabelsMetadata, "bad,=x"))
+	got := streamLabels(ctx)
+	if len(got) != 2 || got["replay"] != "inc-1" || got["source"] != "file" {
+		t.Errorf("Unexpected labels %v", got)
+	}
+	if l := streamLabels(context.Background()); l != nil {
+		t.Errorf("Expected no labels without metadata, got %v", l)
+	}
+}
+
+func TestSelectedEnvelopeSubscription(t *testing.T) {
+	s, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tn, _ := s.tenantFor("default")
+	ch, un, err := s.SubscribeWith("default", SubscribeOptions{Selector: map[string]string{"service": "api"}, Envelope: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer un()
+
+	api := &AgentInfo{ID: "a1", Labels: map[string]string{"service": "api", "env": "prod"}}
+	web := &AgentInfo{ID: "w1", Labels: map[string]string{"service": "web"}}
+	s.handleDecoded(tn, web, []byte(`{"name":"root"}`), nil)
+	s.handleDecoded(tn, api, []byte(`{"name":"root","value":3}`), nil)
+	s.handleChunk(tn, []byte(`{"name":"root"}`))
+
+	select {
+	case data := <-ch:
+		var env ChunkEnvelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			t.Fatal(err)
+		}
+		if env.Agent != "a1" || env.Labels["env"] != "prod" || string(env.Root) != `{"name":"root","value":3}` {
+			t.Errorf("Unexpected envelope %+v", env)
+		}
+	default:
+		t.Fatal("Expected the api agent's chunk")
+	}
+	select {
+	case data := <-ch:
+		t.Errorf("Expected only the matching agent's chunk, also got %s", data)
+	default:
+	}
+}
@@ -45,6 +45,13 @@ var (
         Help:      "Total number of flamegraph chunks received from agents.",
     })
 
+    IngestRejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Namespace: "flarego",
+        Subsystem: "gateway",
+        Name:      "ingest_rejected_total",
+        Help:      "Agent chunks rejected by ingest limits, by tenant and reason.",
+    }, []string{"tenant", "reason"})
+
     Subscribers = prometheus.NewGauge(prometheus.GaugeOpts{
         Namespace: "flarego",
         Subsystem: "gateway",
@@ -61,6 +68,7 @@ func Register() {
             HeapBytes,
             GcPauseTotalNs,
             ChunksReceivedTotal,
+            IngestRejectedTotal,
             Subscribers,
         )
     })
@@ -0,0 +1,207 @@
+// internal/util/certs.go
+// Hot‑reloading TLS material shared by the gateway (server + client CA
+// verification) and the agent exporter (client certificate + server CA).
+//
+// Files are not watched with inotify; instead every handshake checks, at most
+// once per CheckEvery, whether any file's modification time changed and
+// re‑reads them if so.  A failed reload keeps serving the last good material,
+// so a half‑written file during rotation never breaks live connections.
+//
+// Because tls.Config.RootCAs / ClientCAs are read once per handshake from the
+// config value, the configs returned here verify peers through callbacks that
+// consult the current pool instead.
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a certificate/key pair and an optional CA bundle that
+// are reloaded from disk when they change.  Any of the paths may be empty.
+type CertReloader struct {
+    CertPath   string
+    KeyPath    string
+    CAPath     string
+    CheckEvery time.Duration // default 10 s
+
+    mu        sync.RWMutex
+    cert      *tls.Certificate
+    pool      *x509.CertPool
+    mtimes    [3]time.Time
+    lastCheck time.Time
+}
+
+// NewCertReloader loads the files once and returns the reloader.
+func NewCertReloader(certPath, keyPath, caPath string) (*CertReloader, error) {
+    if (certPath == "") != (keyPath == "") {
+        return nil, errors.New("tls: certificate and key must be set together")
+    }
+    r := &CertReloader{CertPath: certPath, KeyPath: keyPath, CAPath: caPath, CheckEvery: 10 * time.Second}
+    if err := r.Reload(); err != nil {
+        return nil, err
+    }
+    return r, nil
+}
+
+// Reload unconditionally re‑reads all configured files.
+func (r *CertReloader) Reload() error {
+    var (
+        cert *tls.Certificate
+        pool *x509.CertPool
+    )
+    if r.CertPath != "" {
+        c, err := tls.LoadX509KeyPair(r.CertPath, r.KeyPath)
+        if err != nil {
+            return err
+        }
+        cert = &c
+    }
+    if r.CAPath != "" {
+        pem, err := os.ReadFile(r.CAPath)
+        if err != nil {
+            return err
+        }
+        pool = x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(pem) {
+            return fmt.Errorf("%s: no CA certificates found", r.CAPath)
+        }
+    }
+    r.mu.Lock()
+    r.cert, r.pool = cert, pool
+    r.mtimes = r.stat()
+    r.lastCheck = time.Now()
+    r.mu.Unlock()
+    return nil
+}
+
+func (r *CertReloader) stat() [3]time.Time {
+    var out [3]time.Time
+    for i, p := range []string{r.CertPath, r.KeyPath, r.CAPath} {
+        if p == "" {
+            continue
+        }
+        if st, err := os.Stat(p); err == nil {
+            out[i] = st.ModTime()
+        }
+    }
+    return out
+}
+
+// maybeReload re‑reads the files when their mtimes changed since the last
+// load, checking at most once per CheckEvery.
+func (r *CertReloader) maybeReload() {
+    r.mu.Lock()
+    if time.Since(r.lastCheck) < r.CheckEvery {
+        r.mu.Unlock()
+        return
+    }
+    r.lastCheck = time.Now()
+    changed := r.stat() != r.mtimes
+    r.mu.Unlock()
+    if changed {
+        _ = r.Reload() // keep the previous material on error
+    }
+}
+
+// Certificate returns the current key pair (nil when none is configured).
+func (r *CertReloader) Certificate() *tls.Certificate {
+    r.maybeReload()
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    return r.cert
+}
+
+// Pool returns the current CA pool (nil when none is configured).
+func (r *CertReloader) Pool() *x509.CertPool {
+    r.maybeReload()
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    return r.pool
+}
+
+// ServerConfig returns a server tls.Config.  When a CA bundle is configured
+// client certificates are requested and verified against it; requireClient
+// additionally rejects clients without one.
+func (r *CertReloader) ServerConfig(requireClient bool) *tls.Config {
+    return &tls.Config{
+        MinVersion: tls.VersionTLS12,
+        GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+            cfg := &tls.Config{
+                MinVersion: tls.VersionTLS12,
+                GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+                    if c := r.Certificate(); c != nil {
+                        return c, nil
+                    }
+                    return nil, errors.New("tls: no server certificate configured")
+                },
+            }
+            if pool := r.Pool(); pool != nil {
+                cfg.ClientCAs = pool
+                cfg.ClientAuth = tls.VerifyClientCertIfGiven
+                if requireClient {
+                    cfg.ClientAuth = tls.RequireAndVerifyClientCert
+                }
+            }
+            return cfg, nil
+        },
+    }
+}
+
+// ClientConfig returns a client tls.Config presenting the current key pair
+// (if any) and verifying the server against the current CA bundle, or the
+// system roots when none is configured.  The server certificate must be
+// valid for serverName, or for the host of addr when serverName is empty;
+// IP literals are checked against the certificate's IP SANs.
+func (r *CertReloader) ClientConfig(serverName, addr string) *tls.Config {
+    name := serverName
+    if name == "" {
+        name = addr
+        if host, _, err := net.SplitHostPort(addr); err == nil {
+            name = host
+        }
+    }
+    cfg := &tls.Config{
+        MinVersion: tls.VersionTLS12,
+        ServerName: name,
+        GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+            if c := r.Certificate(); c != nil {
+                return c, nil
+            }
+            return &tls.Certificate{}, nil // no client certificate
+        },
+    }
+    if r.CAPath == "" {
+        return cfg
+    }
+    // Verify against the reloadable pool ourselves; the standard check would
+    // pin the pool that was current when the config was built.  The name is
+    // the one captured above: the handshake's ServerName is the SNI, which
+    // is empty for IP literals.
+    cfg.InsecureSkipVerify = true
+    cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+        if len(cs.PeerCertificates) == 0 {
+            return errors.New("tls: server presented no certificate")
+        }
+        if name == "" {
+            return errors.New("tls: no server name to verify the certificate against")
+        }
+        opts := x509.VerifyOptions{
+            Roots:         r.Pool(),
+            DNSName:       name,
+            Intermediates: x509.NewCertPool(),
+        }
+        for _, c := range cs.PeerCertificates[1:] {
+            opts.Intermediates.AddCert(c)
+        }
+        _, err := cs.PeerCertificates[0].Verify(opts)
+        return err
+    }
+    return cfg
+}
@@ -8,7 +8,8 @@
 //   - MustNew()     – like New but panics on entropy errors (rare)
 //
 // To avoid excessive syscalls we keep a process‑global monotonic entropy source
-// (math/rand wrapped by ulid.Monotonic) seeded from crypto/rand.
+// (math/rand wrapped by ulid.Monotonic) seeded from crypto/rand.  The source
+// is not safe for concurrent use, so New serialises access to it.
 package util
 
 import (
@@ -16,12 +17,16 @@ import (
 	"encoding/binary"
 	"io"
 	mrand "math/rand"
+	"sync"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 )
 
-var entropy *ulid.MonotonicEntropy
+var (
+    entropyMu sync.Mutex
+    entropy   *ulid.MonotonicEntropy
+)
 
 func init() {
     // Seed math/rand with crypto‑secure random so that ulid monotonic generator
@@ -33,7 +38,9 @@ func init() {
 
 // New returns a new ULID string or error.
 func New() (string, error) {
+    entropyMu.Lock()
     id, err := ulid.New(ulid.Timestamp(time.Now()), entropy)
+    entropyMu.Unlock()
     if err != nil {
         return "", err
     }
@@ -0,0 +1,28 @@
+package util
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestNewConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				id := MustNew()
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("Expected unique IDs, got %s twice", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
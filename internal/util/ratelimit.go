@@ -0,0 +1,122 @@
+// internal/util/ratelimit.go
+// Minimal token bucket used for gateway ingest limits.  Like backoff.go it is
+// dependency‑free; callers needing reservations or waiting should reach for
+// golang.org/x/time/rate instead.
+//
+// The bucket holds at most one second worth of tokens.  A request larger than
+// that (e.g. a 2 MiB chunk against a 1 MiB/s byte limit) is admitted once the
+// bucket is full and leaves it in debt, so big payloads are slowed down rather
+// than rejected forever.
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket refills at Rate tokens per second.  A nil *TokenBucket admits
+// everything, which keeps "no limit" call sites branch‑free.
+type TokenBucket struct {
+    Rate float64
+
+    mu     sync.Mutex
+    tokens float64
+    last   time.Time
+}
+
+// NewTokenBucket returns a full bucket, or nil when rate <= 0.
+func NewTokenBucket(rate float64) *TokenBucket {
+    if rate <= 0 {
+        return nil
+    }
+    return &TokenBucket{Rate: rate, tokens: rate, last: time.Now()}
+}
+
+// Allow takes n tokens if available.  Otherwise nothing is taken and the
+// returned duration estimates when the request would be admitted.
+func (b *TokenBucket) Allow(n float64) (bool, time.Duration) {
+    ok, wait := b.Check(n)
+    if ok {
+        b.Take(n)
+    }
+    return ok, wait
+}
+
+// Check reports whether n tokens are available without taking them.  A
+// request subject to several buckets should use TakeAll, which decides for
+// all of them at once.
+func (b *TokenBucket) Check(n float64) (bool, time.Duration) {
+    if b == nil {
+        return true, 0
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.refill()
+    return b.admits(n)
+}
+
+// TakeAll takes n[i] tokens from every buckets[i] if each of them admits its
+// request, holding all their locks while deciding so concurrent callers
+// sharing a bucket cannot both pass the check and then both take.  Otherwise
+// nothing is taken and TakeAll returns the index of the first bucket that
+// refused and its wait; it returns -1 on success.  nil buckets admit
+// everything.  Buckets are locked in slice order, so callers sharing buckets
+// must list them in the same order (e.g. narrowest scope first) and a bucket
+// must not appear twice.
+func TakeAll(buckets []*TokenBucket, n []float64) (int, time.Duration) {
+    for _, b := range buckets {
+        if b != nil {
+            b.mu.Lock()
+            defer b.mu.Unlock()
+        }
+    }
+    for i, b := range buckets {
+        if b == nil {
+            continue
+        }
+        b.refill()
+        if ok, wait := b.admits(n[i]); !ok {
+            return i, wait
+        }
+    }
+    for i, b := range buckets {
+        if b != nil {
+            b.tokens -= n[i]
+        }
+    }
+    return -1, 0
+}
+
+// admits reports whether n tokens are available; must hold b.mu.
+func (b *TokenBucket) admits(n float64) (bool, time.Duration) {
+    need := n
+    if need > b.Rate {
+        need = b.Rate // oversized request: wait for a full bucket, then go into debt
+    }
+    if b.tokens >= need {
+        return true, 0
+    }
+    return false, time.Duration((need - b.tokens) / b.Rate * float64(time.Second))
+}
+
+// Take removes n tokens unconditionally, leaving the bucket in debt when it
+// holds fewer.
+func (b *TokenBucket) Take(n float64) {
+    if b == nil {
+        return
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.refill()
+    b.tokens -= n
+}
+
+// refill adds the tokens accrued since the last call; must hold b.mu.
+func (b *TokenBucket) refill() {
+    now := time.Now()
+    b.tokens += now.Sub(b.last).Seconds() * b.Rate
+    if b.tokens > b.Rate {
+        b.tokens = b.Rate
+    }
+    b.last = now
+}
@@ -0,0 +1,137 @@
+// pkg/auth/denylist.go
+// Token revocation.  A Denylist is a JSON‑lines file with one revoked token
+// per line:
+//
+//	{"jti":"4f1c…","sub":"agent-7","exp":1767225600,"revoked_at":1767139200}
+//
+// `flarego token revoke` appends to the file; the gateway's Verifier consults
+// it on every token.  The file is re‑read when its modification time changes
+// (checked at most once per CheckEvery), so revocations apply to running
+// gateways within seconds.  Entries whose token has expired anyway are
+// dropped on load, keeping the set small without manual pruning.
+package auth
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrTokenRevoked is returned for tokens whose jti is on the denylist.
+var ErrTokenRevoked = errors.New("token revoked")
+
+// Revocation is one denylist entry.
+type Revocation struct {
+    JTI       string `json:"jti"`
+    Subject   string `json:"sub,omitempty"`
+    Expires   int64  `json:"exp,omitempty"` // unix seconds; 0 = keep forever
+    RevokedAt int64  `json:"revoked_at"`
+}
+
+// Denylist is a reloadable set of revoked token ids.  Safe for concurrent use.
+type Denylist struct {
+    Path       string
+    CheckEvery time.Duration // default 5 s
+
+    mu        sync.RWMutex
+    jtis      map[string]struct{}
+    mtime     time.Time
+    lastCheck time.Time
+}
+
+// LoadDenylist reads path; a missing file is treated as empty so the gateway
+// can start before the first revocation.
+func LoadDenylist(path string) (*Denylist, error) {
+    d := &Denylist{Path: path, CheckEvery: 5 * time.Second}
+    if err := d.Reload(); err != nil {
+        return nil, err
+    }
+    return d, nil
+}
+
+// Reload re‑reads the file unconditionally.
+func (d *Denylist) Reload() error {
+    jtis := make(map[string]struct{})
+    var mtime time.Time
+    f, err := os.Open(d.Path)
+    switch {
+    case errors.Is(err, os.ErrNotExist):
+    case err != nil:
+        return err
+    default:
+        defer f.Close()
+        if st, err := f.Stat(); err == nil {
+            mtime = st.ModTime()
+        }
+        now := time.Now().Unix()
+        sc := bufio.NewScanner(f)
+        for sc.Scan() {
+            var r Revocation
+            if json.Unmarshal(sc.Bytes(), &r) != nil || r.JTI == "" {
+                continue // tolerate hand edits and partial lines
+            }
+            if r.Expires > 0 && r.Expires < now {
+                continue
+            }
+            jtis[r.JTI] = struct{}{}
+        }
+        if err := sc.Err(); err != nil {
+            return err
+        }
+    }
+    d.mu.Lock()
+    d.jtis, d.mtime, d.lastCheck = jtis, mtime, time.Now()
+    d.mu.Unlock()
+    return nil
+}
+
+// Revoked reports whether jti is denied, reloading the file first if it
+// changed.
+func (d *Denylist) Revoked(jti string) bool {
+    d.maybeReload()
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    _, ok := d.jtis[jti]
+    return ok
+}
+
+func (d *Denylist) maybeReload() {
+    d.mu.Lock()
+    if time.Since(d.lastCheck) < d.CheckEvery {
+        d.mu.Unlock()
+        return
+    }
+    d.lastCheck = time.Now()
+    prev := d.mtime
+    d.mu.Unlock()
+    st, err := os.Stat(d.Path)
+    if err != nil || !st.ModTime().Equal(prev) {
+        _ = d.Reload() // keep the previous set on error
+    }
+}
+
+// AppendRevocation adds r to the denylist file at path, creating it if needed.
+func AppendRevocation(path string, r Revocation) error {
+    if r.JTI == "" {
+        return errors.New("revocation without jti")
+    }
+    if r.RevokedAt == 0 {
+        r.RevokedAt = time.Now().Unix()
+    }
+    line, err := json.Marshal(r)
+    if err != nil {
+        return err
+    }
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+    if err != nil {
+        return err
+    }
+    if _, err := f.Write(append(line, '\n')); err != nil {
+        _ = f.Close()
+        return err
+    }
+    return f.Close()
+}
@@ -1,13 +1,27 @@
 // pkg/auth/jwt.go
-// Lightweight HMAC‑SHA256 JWT signer / verifier used by both agent and gateway
-// for authentication.  The implementation deliberately avoids advanced JWT
-// conventions (kid, JWKs) to keep the dependency surface minimal.
+// Lightweight JWT signer / verifier used by both agent and gateway for
+// authentication.  Two key models are supported:
+//   - HS256 with a shared secret – simplest, but every holder of the secret
+//     can mint tokens.
+//   - Asymmetric RS256/384/512, ES256/384/512 and EdDSA – agents only hold
+//     tokens, the gateway only holds public keys (PEM or JWKS, see keys.go)
+//     selected by the token's `kid` and reloaded periodically for rotation.
+//
+// Verification checks signature, exp, nbf (with optional leeway), iss and,
+// when configured, aud and the jti denylist (see denylist.go).
 //
 // External dependency: github.com/golang-jwt/jwt/v5 (MIT).
 package auth
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 
 	jwt "github.com/golang-jwt/jwt/v5"
@@ -15,28 +29,55 @@ import (
 
 // Signer produces short‑lived tokens for agents.
 type Signer struct {
-    secret     []byte
+    secret     []byte        // HS256 key; nil for asymmetric signers
+    key        crypto.Signer // asymmetric private key
+    kid        string        // optional key id placed in the header
+    method     jwt.SigningMethod
     issuer     string
     ttl        time.Duration
     clock      func() time.Time // injection point for tests
 }
 
-// NewSigner returns a Signer with given secret, issuer claim and TTL.
+// NewSigner returns an HS256 Signer with given secret, issuer claim and TTL.
 func NewSigner(secret []byte, issuer string, ttl time.Duration) *Signer {
     if ttl <= 0 {
         ttl = 15 * time.Minute
     }
-    return &Signer{secret: secret, issuer: issuer, ttl: ttl, clock: time.Now}
+    return &Signer{secret: secret, method: jwt.SigningMethodHS256, issuer: issuer, ttl: ttl, clock: time.Now}
 }
 
-// Claims returns standard claims for a new token.
+// NewKeySigner returns an asymmetric Signer.  The algorithm follows the key
+// type: RSA → RS256, ECDSA P‑256/384/521 → ES256/384/512, Ed25519 → EdDSA.
+// kid, when non‑empty, is written to the token header so verifiers holding a
+// JWKS can select the matching public key.
+func NewKeySigner(key crypto.Signer, kid, issuer string, ttl time.Duration) (*Signer, error) {
+    method, err := methodForKey(key)
+    if err != nil {
+        return nil, err
+    }
+    if ttl <= 0 {
+        ttl = 15 * time.Minute
+    }
+    return &Signer{key: key, kid: kid, method: method, issuer: issuer, ttl: ttl, clock: time.Now}, nil
+}
+
+// Algorithm reports the JWS alg the signer uses, e.g. "RS256".
+func (s *Signer) Algorithm() string { return s.method.Alg() }
+
+// TTL reports the default token lifetime.
+func (s *Signer) TTL() time.Duration { return s.ttl }
+
+// Claims returns standard claims for a new token.  extra may add or
+// override any claim, e.g. "aud" or "exp".
 func (s *Signer) Claims(subject string, extra map[string]any) jwt.MapClaims {
     now := s.clock()
     claims := jwt.MapClaims{
         "iss": s.issuer,
         "sub": subject,
         "iat": now.Unix(),
+        "nbf": now.Unix(),
         "exp": now.Add(s.ttl).Unix(),
+        "jti": newJTI(),
     }
     for k, v := range extra {
         claims[k] = v
@@ -46,40 +87,99 @@ func (s *Signer) Claims(subject string, extra map[string]any) jwt.MapClaims {
 
 // Sign produces a JWT string.
 func (s *Signer) Sign(claims jwt.MapClaims) (string, error) {
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+    token := jwt.NewWithClaims(s.method, claims)
+    if s.kid != "" {
+        token.Header["kid"] = s.kid
+    }
+    if s.key != nil {
+        return token.SignedString(s.key)
+    }
     return token.SignedString(s.secret)
 }
 
-// Verifier validates HMAC‑signed tokens.
+// Verifier validates signed tokens.
 type Verifier struct {
-    secret []byte
-    issuer string
-    clock  func() time.Time
+    secret   []byte
+    keys     *KeySet
+    denylist *Denylist
+    issuer   string
+    audience string
+    leeway   time.Duration
+    clock    func() time.Time
 }
 
-// NewVerifier constructs a verifier with expected issuer.
+// VerifierConfig configures NewVerifierWithConfig.  At least one of Secret
+// (HS256) or Keys (asymmetric) must be set; both may be set during a
+// migration from shared secrets to key pairs.
+type VerifierConfig struct {
+    Secret   []byte
+    Keys     *KeySet
+    Issuer   string        // expected iss; empty accepts any
+    Audience string        // required aud; empty skips the check
+    Leeway   time.Duration // clock skew tolerated on exp/nbf
+    Denylist *Denylist     // revoked jti values; nil disables the check
+}
+
+// NewVerifier constructs an HS256 verifier with expected issuer.
 func NewVerifier(secret []byte, issuer string) *Verifier {
     return &Verifier{secret: secret, issuer: issuer, clock: time.Now}
 }
 
+// NewVerifierWithConfig constructs a verifier for HS256 and/or asymmetric
+// tokens.
+func NewVerifierWithConfig(cfg VerifierConfig) (*Verifier, error) {
+    if len(cfg.Secret) == 0 && cfg.Keys == nil {
+        return nil, errors.New("auth: verifier needs a secret or a key set")
+    }
+    return &Verifier{
+        secret:   cfg.Secret,
+        keys:     cfg.Keys,
+        denylist: cfg.Denylist,
+        issuer:   cfg.Issuer,
+        audience: cfg.Audience,
+        leeway:   cfg.Leeway,
+        clock:    time.Now,
+    }, nil
+}
+
 var (
-    ErrInvalidToken  = errors.New("invalid token")
-    ErrExpiredToken  = errors.New("token expired")
-    ErrIssuerMismatch = errors.New("issuer mismatch")
+    ErrInvalidToken     = errors.New("invalid token")
+    ErrExpiredToken     = errors.New("token expired")
+    ErrIssuerMismatch   = errors.New("issuer mismatch")
+    ErrAudienceMismatch = errors.New("audience mismatch")
+    ErrTokenNotYetValid = errors.New("token not valid yet")
 )
 
-// ParseAndVerify parses tokenStr and returns claims after validating signature,
-// expiry and issuer.
+var asymmetricAlgs = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// ParseAndVerify parses tokenStr and returns claims after validating
+// signature, expiry, not‑before, issuer, audience and revocation.
 func (v *Verifier) ParseAndVerify(tokenStr string) (jwt.MapClaims, error) {
-    token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
-        if t.Method != jwt.SigningMethodHS256 {
-            return nil, ErrInvalidToken
-        }
-        return v.secret, nil
-    }, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+    var algs []string
+    if len(v.secret) > 0 {
+        algs = append(algs, jwt.SigningMethodHS256.Alg())
+    }
+    if v.keys != nil {
+        algs = append(algs, asymmetricAlgs...)
+    }
+    opts := []jwt.ParserOption{
+        jwt.WithValidMethods(algs),
+        jwt.WithLeeway(v.leeway),
+        jwt.WithTimeFunc(v.clock),
+    }
+    if v.audience != "" {
+        opts = append(opts, jwt.WithAudience(v.audience))
+    }
+
+    token, err := jwt.Parse(tokenStr, v.keyFunc, opts...)
     if err != nil {
-        if errors.Is(err, jwt.ErrTokenExpired) {
+        switch {
+        case errors.Is(err, jwt.ErrTokenExpired):
             return nil, ErrExpiredToken
+        case errors.Is(err, jwt.ErrTokenNotValidYet):
+            return nil, ErrTokenNotYetValid
+        case errors.Is(err, jwt.ErrTokenInvalidAudience):
+            return nil, ErrAudienceMismatch
         }
         return nil, ErrInvalidToken
     }
@@ -91,5 +191,83 @@ func (v *Verifier) ParseAndVerify(tokenStr string) (jwt.MapClaims, error) {
     if v.issuer != "" && claims["iss"] != v.issuer {
         return nil, ErrIssuerMismatch
     }
+    if jti, _ := claims["jti"].(string); jti != "" && v.denylist != nil && v.denylist.Revoked(jti) {
+        return nil, ErrTokenRevoked
+    }
     return claims, nil
 }
+
+// keyFunc selects verification key(s) for t.
+func (v *Verifier) keyFunc(t *jwt.Token) (any, error) {
+    if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+        if len(v.secret) == 0 {
+            return nil, ErrInvalidToken
+        }
+        return v.secret, nil
+    }
+    if v.keys == nil {
+        return nil, ErrInvalidToken
+    }
+    kid, _ := t.Header["kid"].(string)
+    keys, err := v.keys.Keys(kid)
+    if err != nil {
+        return nil, err
+    }
+    set := jwt.VerificationKeySet{}
+    for _, k := range keys {
+        if keyMatchesMethod(k, t.Method) {
+            set.Keys = append(set.Keys, k)
+        }
+    }
+    if len(set.Keys) == 0 {
+        return nil, ErrUnknownKey
+    }
+    return set, nil
+}
+
+//--------------------------------------------------------------------
+// helpers
+//--------------------------------------------------------------------
+
+func methodForKey(key crypto.Signer) (jwt.SigningMethod, error) {
+    switch k := key.(type) {
+    case *rsa.PrivateKey:
+        return jwt.SigningMethodRS256, nil
+    case *ecdsa.PrivateKey:
+        switch k.Curve.Params().BitSize {
+        case 256:
+            return jwt.SigningMethodES256, nil
+        case 384:
+            return jwt.SigningMethodES384, nil
+        case 521:
+            return jwt.SigningMethodES512, nil
+        }
+        return nil, fmt.Errorf("auth: unsupported ECDSA curve %s", k.Curve.Params().Name)
+    case ed25519.PrivateKey:
+        return jwt.SigningMethodEdDSA, nil
+    default:
+        return nil, fmt.Errorf("auth: unsupported private key type %T", key)
+    }
+}
+
+func keyMatchesMethod(k crypto.PublicKey, m jwt.SigningMethod) bool {
+    switch m.(type) {
+    case *jwt.SigningMethodRSA:
+        _, ok := k.(*rsa.PublicKey)
+        return ok
+    case *jwt.SigningMethodECDSA:
+        ek, ok := k.(*ecdsa.PublicKey)
+        return ok && ek.Curve.Params().BitSize == m.(*jwt.SigningMethodECDSA).CurveBits
+    case *jwt.SigningMethodEd25519:
+        _, ok := k.(ed25519.PublicKey)
+        return ok
+    }
+    return false
+}
+
+// newJTI returns a random 128‑bit token id.
+func newJTI() string {
+    var b [16]byte
+    _, _ = rand.Read(b[:])
+    return hex.EncodeToString(b[:])
+}
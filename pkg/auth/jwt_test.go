@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeJWKS(t *testing.T, path string, keys map[string]*ecdsa.PublicKey) {
+	t.Helper()
+	var doc struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for kid, k := range keys {
+		doc.Keys = append(doc.Keys, map[string]string{
+			"kty": "EC",
+			"crv": "P-256",
+			"kid": kid,
+			"x":   base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, 32))),
+		})
+	}
+	data, _ := json.Marshal(doc)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifier_JWKSRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	k1, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	k2, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	writeJWKS(t, path, map[string]*ecdsa.PublicKey{"k1": &k1.PublicKey})
+
+	ks, err := LoadKeySet(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVerifierWithConfig(VerifierConfig{Keys: ks, Issuer: "flarego", Audience: "gateway"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s1, err := NewKeySigner(k1, "k1", "flarego", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1.Algorithm() != "ES256" {
+		t.Fatalf("Expected ES256, got %s", s1.Algorithm())
+	}
+	tok, _ := s1.Sign(s1.Claims("agent-1", map[string]any{"aud": "gateway"}))
+	if _, err := v.ParseAndVerify(tok); err != nil {
+		t.Fatalf("Expected valid token, got %v", err)
+	}
+
+	// Wrong audience is rejected.
+	tok, _ = s1.Sign(s1.Claims("agent-1", map[string]any{"aud": "other"}))
+	if _, err := v.ParseAndVerify(tok); !errors.Is(err, ErrAudienceMismatch) {
+		t.Errorf("Expected ErrAudienceMismatch, got %v", err)
+	}
+
+	// nbf in the future is rejected.
+	tok, _ = s1.Sign(s1.Claims("agent-1", map[string]any{"aud": "gateway", "nbf": time.Now().Add(time.Hour).Unix()}))
+	if _, err := v.ParseAndVerify(tok); !errors.Is(err, ErrTokenNotYetValid) {
+		t.Errorf("Expected ErrTokenNotYetValid, got %v", err)
+	}
+
+	// Rotate: k2 replaces k1 in the file.
+	writeJWKS(t, path, map[string]*ecdsa.PublicKey{"k2": &k2.PublicKey})
+	if err := ks.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	s2, _ := NewKeySigner(k2, "k2", "flarego", time.Minute)
+	tok, _ = s2.Sign(s2.Claims("agent-1", map[string]any{"aud": "gateway"}))
+	if _, err := v.ParseAndVerify(tok); err != nil {
+		t.Errorf("Expected rotated key to verify, got %v", err)
+	}
+	tok, _ = s1.Sign(s1.Claims("agent-1", map[string]any{"aud": "gateway"}))
+	if _, err := v.ParseAndVerify(tok); err == nil {
+		t.Error("Expected token signed by retired key to fail")
+	}
+}
+
+func TestVerifier_PEMEd25519(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	path := filepath.Join(t.TempDir(), "pub.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ks, err := LoadKeySet(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := NewVerifierWithConfig(VerifierConfig{Keys: ks})
+
+	s, err := NewKeySigner(priv, "", "flarego", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, _ := s.Sign(s.Claims("agent-1", nil))
+	if _, err := v.ParseAndVerify(tok); err != nil {
+		t.Fatalf("Expected valid EdDSA token, got %v", err)
+	}
+
+	// HS256 tokens must not be accepted by a key-only verifier.
+	hs := NewSigner([]byte("secret"), "flarego", time.Minute)
+	tok, _ = hs.Sign(hs.Claims("agent-1", nil))
+	if _, err := v.ParseAndVerify(tok); err == nil {
+		t.Error("Expected HS256 token to be rejected")
+	}
+}
+
+func TestVerifier_Denylist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.jsonl")
+	dl, err := LoadDenylist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl.CheckEvery = 0
+
+	secret := []byte("s3cr3t")
+	v, err := NewVerifierWithConfig(VerifierConfig{Secret: secret, Denylist: dl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewSigner(secret, "", time.Minute)
+	claims := signer.Claims("agent-7", nil)
+	tok, _ := signer.Sign(claims)
+	if _, err := v.ParseAndVerify(tok); err != nil {
+		t.Fatalf("Expected token to verify before revocation, got %v", err)
+	}
+
+	jti, _ := claims["jti"].(string)
+	if err := AppendRevocation(path, Revocation{JTI: jti, Subject: "agent-7"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.ParseAndVerify(tok); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+	other, _ := signer.Sign(signer.Claims("agent-8", nil))
+	if _, err := v.ParseAndVerify(other); err != nil {
+		t.Errorf("Expected unrelated token to stay valid, got %v", err)
+	}
+}
+
+func TestKeySetThrottlesMissReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	k1, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	k2, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	writeJWKS(t, path, map[string]*ecdsa.PublicKey{"k1": &k1.PublicKey})
+
+	ks, err := LoadKeySet(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys reads the clock once per miss and a successful Reload once more.
+	now := time.Now().Add(time.Minute)
+	var calls atomic.Int32
+	ks.clock = func() time.Time {
+		calls.Add(1)
+		return now
+	}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if _, err := ks.Keys("k2"); !errors.Is(err, ErrUnknownKey) {
+				t.Errorf("Expected ErrUnknownKey, got %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	if n := calls.Load(); n != 21 {
+		t.Errorf("Expected one reload for 20 concurrent misses, got %d", n-20)
+	}
+
+	// The new key is only picked up once the gap has passed.
+	writeJWKS(t, path, map[string]*ecdsa.PublicKey{"k2": &k2.PublicKey})
+	if _, err := ks.Keys("k2"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected the reload to be throttled, got %v", err)
+	}
+	now = now.Add(minReloadGap)
+	if keys, err := ks.Keys("k2"); err != nil || len(keys) != 1 {
+		t.Errorf("Expected k2 after the gap, got %v, %v", keys, err)
+	}
+}
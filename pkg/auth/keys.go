@@ -0,0 +1,326 @@
+// pkg/auth/keys.go
+// Public‑key material for asymmetric JWT verification (RS256, ES256, EdDSA).
+// A KeySet is loaded from a single file which may be either
+//   - a JWKS document ({"keys":[...]}) – keys are selected by `kid`, or
+//   - one or more PEM blocks (PUBLIC KEY, RSA PUBLIC KEY, CERTIFICATE) – keys
+//     carry no kid and are tried in turn for tokens without one.
+//
+// The file is re‑read every RefreshEvery so keys can be rotated by replacing
+// the file (e.g. a mounted Kubernetes secret or a JWKS synced by a sidecar).
+// An unknown kid additionally triggers an early reload, throttled so a flood
+// of forged tokens cannot turn into a flood of disk reads.
+//
+// Only the standard library is used for JWK parsing.
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned when no key matches a token's kid.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySet is a reloadable set of verification keys.  Safe for concurrent use.
+type KeySet struct {
+    path         string
+    refreshEvery time.Duration
+    clock        func() time.Time
+
+    mu         sync.RWMutex
+    byKID      map[string]crypto.PublicKey
+    anonymous  []crypto.PublicKey // PEM keys without kid
+    lastReload time.Time
+    lastMiss   time.Time // last kid‑miss reload, claimed under mu
+
+    quit chan struct{}
+    done chan struct{}
+}
+
+// minReloadGap throttles kid‑miss reloads.
+const minReloadGap = 10 * time.Second
+
+// LoadKeySet reads path once and, when refreshEvery > 0, starts a background
+// goroutine that reloads it periodically.  Call Close to stop it.
+func LoadKeySet(path string, refreshEvery time.Duration) (*KeySet, error) {
+    ks := &KeySet{path: path, refreshEvery: refreshEvery, clock: time.Now}
+    if err := ks.Reload(); err != nil {
+        return nil, err
+    }
+    if refreshEvery > 0 {
+        ks.quit = make(chan struct{})
+        ks.done = make(chan struct{})
+        go ks.loop()
+    }
+    return ks, nil
+}
+
+// Reload re‑reads the key file.  On error the previous keys stay in place.
+func (ks *KeySet) Reload() error {
+    data, err := os.ReadFile(ks.path)
+    if err != nil {
+        return err
+    }
+    byKID, anon, err := parseKeys(data)
+    if err != nil {
+        return fmt.Errorf("%s: %w", ks.path, err)
+    }
+    ks.mu.Lock()
+    ks.byKID, ks.anonymous = byKID, anon
+    ks.lastReload = ks.clock()
+    ks.mu.Unlock()
+    return nil
+}
+
+// Close stops the background reload goroutine.
+func (ks *KeySet) Close() {
+    if ks.quit == nil {
+        return
+    }
+    select {
+    case <-ks.quit:
+    default:
+        close(ks.quit)
+        <-ks.done
+    }
+}
+
+// Keys returns the candidate keys for kid.  An empty kid yields every key;
+// an unknown kid triggers a throttled reload before giving up.
+func (ks *KeySet) Keys(kid string) ([]crypto.PublicKey, error) {
+    if keys := ks.lookup(kid); len(keys) > 0 {
+        return keys, nil
+    }
+    // Claim the reload under the write lock so concurrent misses (or a
+    // reload that keeps failing) read the file at most once per gap.
+    ks.mu.Lock()
+    now := ks.clock()
+    stale := now.Sub(ks.lastReload) >= minReloadGap && now.Sub(ks.lastMiss) >= minReloadGap
+    if stale {
+        ks.lastMiss = now
+    }
+    ks.mu.Unlock()
+    if stale && ks.Reload() == nil {
+        if keys := ks.lookup(kid); len(keys) > 0 {
+            return keys, nil
+        }
+    }
+    return nil, ErrUnknownKey
+}
+
+func (ks *KeySet) lookup(kid string) []crypto.PublicKey {
+    ks.mu.RLock()
+    defer ks.mu.RUnlock()
+    if kid != "" {
+        if k, ok := ks.byKID[kid]; ok {
+            return []crypto.PublicKey{k}
+        }
+        // PEM keys carry no kid; accept them for any kid so a PEM file can
+        // verify tokens minted by signers that set one.
+        return append([]crypto.PublicKey(nil), ks.anonymous...)
+    }
+    keys := append([]crypto.PublicKey(nil), ks.anonymous...)
+    for _, k := range ks.byKID {
+        keys = append(keys, k)
+    }
+    return keys
+}
+
+func (ks *KeySet) loop() {
+    defer close(ks.done)
+    t := time.NewTicker(ks.refreshEvery)
+    defer t.Stop()
+    for {
+        select {
+        case <-t.C:
+            _ = ks.Reload() // keep serving the last good keys
+        case <-ks.quit:
+            return
+        }
+    }
+}
+
+//--------------------------------------------------------------------
+// parsing
+//--------------------------------------------------------------------
+
+func parseKeys(data []byte) (map[string]crypto.PublicKey, []crypto.PublicKey, error) {
+    if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+        byKID, err := parseJWKS(trimmed)
+        return byKID, nil, err
+    }
+    anon, err := parsePEMPublicKeys(data)
+    return map[string]crypto.PublicKey{}, anon, err
+}
+
+// jwk covers the members FlareGo understands (RFC 7517/7518/8037).
+type jwk struct {
+    Kty string `json:"kty"`
+    Kid string `json:"kid"`
+    Use string `json:"use"`
+    Crv string `json:"crv"`
+    N   string `json:"n"`
+    E   string `json:"e"`
+    X   string `json:"x"`
+    Y   string `json:"y"`
+}
+
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+    var doc struct {
+        Keys []jwk `json:"keys"`
+    }
+    if err := json.Unmarshal(data, &doc); err != nil {
+        return nil, err
+    }
+    out := make(map[string]crypto.PublicKey, len(doc.Keys))
+    for i, k := range doc.Keys {
+        if k.Use != "" && k.Use != "sig" {
+            continue
+        }
+        pub, err := k.publicKey()
+        if err != nil {
+            return nil, fmt.Errorf("jwks key %d (%s): %w", i, k.Kid, err)
+        }
+        out[k.Kid] = pub
+    }
+    if len(out) == 0 {
+        return nil, errors.New("jwks contains no signing keys")
+    }
+    return out, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+    switch k.Kty {
+    case "RSA":
+        n, err := b64Int(k.N)
+        if err != nil {
+            return nil, err
+        }
+        e, err := b64Int(k.E)
+        if err != nil {
+            return nil, err
+        }
+        return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+    case "EC":
+        var curve elliptic.Curve
+        switch k.Crv {
+        case "P-256":
+            curve = elliptic.P256()
+        case "P-384":
+            curve = elliptic.P384()
+        case "P-521":
+            curve = elliptic.P521()
+        default:
+            return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+        }
+        x, err := b64Int(k.X)
+        if err != nil {
+            return nil, err
+        }
+        y, err := b64Int(k.Y)
+        if err != nil {
+            return nil, err
+        }
+        return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+    case "OKP":
+        if k.Crv != "Ed25519" {
+            return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+        }
+        x, err := base64.RawURLEncoding.DecodeString(k.X)
+        if err != nil || len(x) != ed25519.PublicKeySize {
+            return nil, errors.New("invalid Ed25519 key")
+        }
+        return ed25519.PublicKey(x), nil
+    default:
+        return nil, fmt.Errorf("unsupported kty %q", k.Kty)
+    }
+}
+
+func b64Int(s string) (*big.Int, error) {
+    b, err := base64.RawURLEncoding.DecodeString(s)
+    if err != nil || len(b) == 0 {
+        return nil, errors.New("invalid base64url integer")
+    }
+    return new(big.Int).SetBytes(b), nil
+}
+
+func parsePEMPublicKeys(data []byte) ([]crypto.PublicKey, error) {
+    var keys []crypto.PublicKey
+    for {
+        var block *pem.Block
+        block, data = pem.Decode(data)
+        if block == nil {
+            break
+        }
+        switch block.Type {
+        case "PUBLIC KEY":
+            k, err := x509.ParsePKIXPublicKey(block.Bytes)
+            if err != nil {
+                return nil, err
+            }
+            keys = append(keys, k)
+        case "RSA PUBLIC KEY":
+            k, err := x509.ParsePKCS1PublicKey(block.Bytes)
+            if err != nil {
+                return nil, err
+            }
+            keys = append(keys, k)
+        case "CERTIFICATE":
+            c, err := x509.ParseCertificate(block.Bytes)
+            if err != nil {
+                return nil, err
+            }
+            keys = append(keys, c.PublicKey)
+        }
+    }
+    if len(keys) == 0 {
+        return nil, errors.New("no PEM public keys found")
+    }
+    return keys, nil
+}
+
+// LoadPrivateKeyPEM reads an RSA, ECDSA or Ed25519 private key (PKCS#8,
+// PKCS#1 or SEC 1) for use with NewKeySigner.
+func LoadPrivateKeyPEM(path string) (crypto.Signer, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    for {
+        var block *pem.Block
+        block, data = pem.Decode(data)
+        if block == nil {
+            return nil, fmt.Errorf("%s: no PEM private key found", path)
+        }
+        switch block.Type {
+        case "PRIVATE KEY":
+            k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+            if err != nil {
+                return nil, err
+            }
+            signer, ok := k.(crypto.Signer)
+            if !ok {
+                return nil, fmt.Errorf("%s: unsupported private key type %T", path, k)
+            }
+            return signer, nil
+        case "RSA PRIVATE KEY":
+            return x509.ParsePKCS1PrivateKey(block.Bytes)
+        case "EC PRIVATE KEY":
+            return x509.ParseECPrivateKey(block.Bytes)
+        }
+    }
+}
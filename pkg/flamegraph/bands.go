@@ -0,0 +1,38 @@
+// pkg/flamegraph/bands.go
+// Runtime samplers add pseudo frames directly below the root – "(GC)" with
+// pause nanoseconds, "(Heap)" with signed byte deltas and "(Blocked)" with
+// goroutine counts.  They share the tree with CPU stacks but not the unit, so
+// anything that computes shares of a total (diffs, terminal views) strips
+// them first.
+package flamegraph
+
+// Root‑level pseudo frames written by internal/agent/sampler.
+const (
+    BandGC      = "(GC)"
+    BandHeap    = "(Heap)"
+    BandBlocked = "(Blocked)"
+)
+
+// RuntimeBands lists the pseudo frames in display order.
+var RuntimeBands = []string{BandGC, BandHeap, BandBlocked}
+
+// WithoutBands returns a shallow copy of root without the RuntimeBands
+// children.  Subtrees are shared with root, not copied.
+func WithoutBands(root *Frame) *Frame {
+    if root == nil {
+        return nil
+    }
+    out := &Frame{Name: root.Name, Value: root.Value, Children: make(map[string]*Frame, len(root.Children))}
+    for name, c := range root.Children {
+        out.Children[name] = c
+    }
+    for _, b := range RuntimeBands {
+        if c, ok := out.Children[b]; ok {
+            delete(out.Children, b)
+            if out.Value != 0 {
+                out.Value -= c.Value
+            }
+        }
+    }
+    return out
+}
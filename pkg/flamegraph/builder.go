@@ -59,7 +59,7 @@ func (b *Builder) Build() *Frame {
     b.root = New(oldRoot.Name) // preserve root name
     b.mu.Unlock()
 
-    return oldRoot.sortedCopy() // deep copy with deterministic ordering
+    return deepCopy(oldRoot) // isolates late AddSample calls on the old root
 }
 
 
@@ -0,0 +1,122 @@
+// pkg/flamegraph/butterfly.go
+// Bottom‑up views of a tree.  Invert turns every stack around so the leaves
+// become the outermost frames: the children of the root are the functions
+// that consumed the weight, and below each one hang its callers.  A
+// Butterfly centres one function: all of its call paths merged into a tree
+// of callers (the inverted view restricted to that function) above it and a
+// tree of callees below it.
+//
+// Both are built from self weights like the transforms in transform.go, so
+// their totals match the input.  A recursive function is cut at its
+// outermost occurrence on each stack, the same rule Hotspots uses for Cum.
+package flamegraph
+
+import (
+	"encoding/json"
+	"regexp"
+)
+
+// Invert returns the bottom‑up tree of root.  Runtime bands are copied as
+// they are.  Invert is a Transform.
+func Invert(root *Frame) *Frame {
+    return rewriteStacks(root, func(stack []string) []string {
+        out := make([]string, len(stack))
+        for i, name := range stack {
+            out[len(stack)-1-i] = name
+        }
+        return out
+    })
+}
+
+// ButterflyView is one function with its merged callers and callees.  Both
+// trees are rooted at the function itself with Value = Cum; Callers grows
+// outwards (children are direct callers, their children the callers'
+// callers), Callees downwards as in the normal tree.  Its JSON form uses
+// the ordered v2 nodes of schema.go.
+type ButterflyView struct {
+    Hotspot
+    Callers *Frame `json:"callers"`
+    Callees *Frame `json:"callees"`
+}
+
+// MarshalJSON writes the caller and callee trees as ordered v2 nodes (see
+// schema.go).
+func (v ButterflyView) MarshalJSON() ([]byte, error) {
+    callers, err := encodeNode(v.Callers)
+    if err != nil {
+        return nil, err
+    }
+    callees, err := encodeNode(v.Callees)
+    if err != nil {
+        return nil, err
+    }
+    return json.Marshal(struct {
+        Hotspot
+        Callers json.RawMessage `json:"callers"`
+        Callees json.RawMessage `json:"callees"`
+    }{v.Hotspot, callers, callees})
+}
+
+// Butterfly returns the view of the function called name, or nil when it
+// does not occur below root.
+func Butterfly(root *Frame, name string) *ButterflyView {
+    vs := butterflies(root, func(n string) bool { return n == name })
+    if len(vs) == 0 {
+        return nil
+    }
+    return &vs[0]
+}
+
+// Butterflies returns a view for every function matching re, ordered by Cum
+// like Hotspots.
+func Butterflies(root *Frame, re *regexp.Regexp) []ButterflyView {
+    return butterflies(root, re.MatchString)
+}
+
+func butterflies(root *Frame, match func(string) bool) []ButterflyView {
+    if root == nil {
+        return nil
+    }
+    views := make(map[string]*ButterflyView)
+    var callers []string
+    WalkSelf(root, func(stack []string, self int64) {
+        if isBand(stack[0]) {
+            return
+        }
+        seen := make(map[string]bool)
+        for i, name := range stack {
+            if seen[name] || !match(name) {
+                continue
+            }
+            seen[name] = true
+            v, ok := views[name]
+            if !ok {
+                v = &ButterflyView{Hotspot: Hotspot{Name: name}, Callers: New(name), Callees: New(name)}
+                views[name] = v
+            }
+            v.Cum += self
+            if stack[len(stack)-1] == name {
+                v.Self += self
+            }
+            v.Callers.Value += self
+            v.Callees.Value += self
+            callers = callers[:0]
+            for j := i - 1; j >= 0; j-- {
+                callers = append(callers, stack[j])
+            }
+            v.Callers.AddSample(callers, self)
+            v.Callees.AddSample(stack[i+1:], self)
+        }
+    })
+
+    hs := make([]Hotspot, 0, len(views))
+    for _, v := range views {
+        hs = append(hs, v.Hotspot)
+    }
+    sortHotspots(hs)
+    out := make([]ButterflyView, 0, len(hs))
+    for _, h := range hs {
+        out = append(out, *views[h.Name])
+    }
+    return out
+}
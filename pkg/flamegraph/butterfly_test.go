@@ -0,0 +1,49 @@
+package flamegraph
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestInvertAndButterfly(t *testing.T) {
+	b := NewBuilder("root")
+	b.Add(Sample{Stack: []string{"main", "handle", "json.Marshal", "mallocgc"}, Weight: 8})
+	b.Add(Sample{Stack: []string{"main", "flush", "mallocgc"}, Weight: 4})
+	b.Add(Sample{Stack: []string{"main", "handle", "json.Marshal"}, Weight: 3})
+	b.Add(Sample{Stack: []string{BandGC}, Weight: 100})
+	root := b.Build()
+
+	inv := Invert(root)
+	m := inv.Children["mallocgc"]
+	if m == nil || m.Value != 12 || m.Children["json.Marshal"].Value != 8 || m.Children["flush"].Value != 4 {
+		t.Fatalf("Expected mallocgc with its callers at the top of the inverted tree, got %+v", m)
+	}
+	if got := Total(WithoutBands(inv)); got != 15 {
+		t.Errorf("Expected inverted total 15, got %d", got)
+	}
+	if inv.Children[BandGC] == nil {
+		t.Error("Expected bands to survive inversion")
+	}
+
+	v := Butterfly(root, "json.Marshal")
+	if v == nil || v.Cum != 11 || v.Self != 3 {
+		t.Fatalf("Unexpected butterfly %+v", v)
+	}
+	if c := v.Callers.Children["handle"]; c == nil || c.Value != 11 || c.Children["main"] == nil {
+		t.Errorf("Expected handle←main above json.Marshal, got %+v", c)
+	}
+	if c := v.Callees.Children["mallocgc"]; c == nil || c.Value != 8 {
+		t.Errorf("Expected mallocgc below json.Marshal, got %+v", c)
+	}
+	if Butterfly(root, "missing") != nil {
+		t.Error("Expected nil butterfly for unknown function")
+	}
+
+	rec := NewBuilder("root")
+	rec.Add(Sample{Stack: []string{"main", "walk", "walk", "leaf"}, Weight: 5})
+	rec.Add(Sample{Stack: []string{"main", "walk", "walk"}, Weight: 2})
+	vs := Butterflies(rec.Build(), regexp.MustCompile(`^walk$`))
+	if len(vs) != 1 || vs[0].Cum != 7 || vs[0].Self != 2 || vs[0].Callees.Children["walk"].Value != 7 {
+		t.Errorf("Expected recursion cut at the outermost walk, got %+v", vs)
+	}
+}
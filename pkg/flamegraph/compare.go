@@ -0,0 +1,309 @@
+// pkg/flamegraph/compare.go
+// Normalised comparison of two recordings for regression gating.  Diff works
+// on absolute weights, which is misleading when the recordings differ in
+// length or load; Compare instead expresses every frame as a share of its
+// recording's total and reports how that share moved:
+//
+//   • SelfDelta / CumDelta – change in percentage points (head% − base%)
+//   • Growth               – relative change of the cumulative share in %,
+//                            +Inf for frames that only exist in head
+//
+// Thresholds are parsed from compact rules such as
+//
+//	frame=^encoding/json\.,growth>10%
+//	cum>+2%
+//	frame=compress,self>=1.5
+//
+// A rule is violated by every frame that matches its pattern (all frames when
+// omitted) and satisfies all of its conditions.  A frame that appears by
+// sampling noise has infinite growth, so growth conditions only apply to
+// frames holding at least MinGrowthShare of head unless the rule sets its
+// own share condition.
+package flamegraph
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FrameChange is one frame name's share of total in both recordings.
+type FrameChange struct {
+    Name     string  `json:"name"`
+    BaseSelf float64 `json:"base_self_pct"`
+    HeadSelf float64 `json:"head_self_pct"`
+    BaseCum  float64 `json:"base_cum_pct"`
+    HeadCum  float64 `json:"head_cum_pct"`
+}
+
+// SelfDelta is the change of the self share in percentage points.
+func (c FrameChange) SelfDelta() float64 { return c.HeadSelf - c.BaseSelf }
+
+// CumDelta is the change of the cumulative share in percentage points.
+func (c FrameChange) CumDelta() float64 { return c.HeadCum - c.BaseCum }
+
+// Growth is the relative change of the cumulative share in percent; +Inf when
+// the frame is new in head.
+func (c FrameChange) Growth() float64 {
+    if c.BaseCum == 0 {
+        if c.HeadCum == 0 {
+            return 0
+        }
+        return math.Inf(1)
+    }
+    return (c.HeadCum - c.BaseCum) / c.BaseCum * 100
+}
+
+// Comparison is the result of Compare.
+type Comparison struct {
+    BaseTotal int64         `json:"base_total"`
+    HeadTotal int64         `json:"head_total"`
+    Changes   []FrameChange `json:"changes"` // ordered by SelfDelta descending
+}
+
+// Compare aggregates both trees by frame name (see Hotspots) and normalises
+// each row by its tree's total.  RuntimeBands are excluded because they are
+// not measured in the same unit as the stacks.
+func Compare(base, head *Frame) *Comparison {
+    base, head = WithoutBands(base), WithoutBands(head)
+    cmp := &Comparison{BaseTotal: Total(base), HeadTotal: Total(head)}
+    byName := make(map[string]*FrameChange)
+    get := func(name string) *FrameChange {
+        c, ok := byName[name]
+        if !ok {
+            c = &FrameChange{Name: name}
+            byName[name] = c
+        }
+        return c
+    }
+    for _, h := range Hotspots(base) {
+        c := get(h.Name)
+        c.BaseSelf = share(h.Self, cmp.BaseTotal)
+        c.BaseCum = share(h.Cum, cmp.BaseTotal)
+    }
+    for _, h := range Hotspots(head) {
+        c := get(h.Name)
+        c.HeadSelf = share(h.Self, cmp.HeadTotal)
+        c.HeadCum = share(h.Cum, cmp.HeadTotal)
+    }
+    for _, c := range byName {
+        cmp.Changes = append(cmp.Changes, *c)
+    }
+    sort.Slice(cmp.Changes, func(i, j int) bool {
+        a, b := cmp.Changes[i], cmp.Changes[j]
+        if a.SelfDelta() != b.SelfDelta() {
+            return a.SelfDelta() > b.SelfDelta()
+        }
+        if a.CumDelta() != b.CumDelta() {
+            return a.CumDelta() > b.CumDelta()
+        }
+        return a.Name < b.Name
+    })
+    return cmp
+}
+
+// Ranked returns up to n frames whose self share grew the most and up to n
+// whose self share shrank the most.  Unchanged frames are omitted.
+func (c *Comparison) Ranked(n int) (grew, shrank []FrameChange) {
+    for _, ch := range c.Changes {
+        if len(grew) < n && ch.SelfDelta() > 0 {
+            grew = append(grew, ch)
+        }
+    }
+    for i := len(c.Changes) - 1; i >= 0 && len(shrank) < n; i-- {
+        if ch := c.Changes[i]; ch.SelfDelta() < 0 {
+            shrank = append(shrank, ch)
+        }
+    }
+    return grew, shrank
+}
+
+func share(v, total int64) float64 {
+    if total == 0 {
+        return 0
+    }
+    return float64(v) * 100 / float64(total)
+}
+
+//--------------------------------------------------------------------
+// thresholds
+//--------------------------------------------------------------------
+
+// Threshold metrics.
+const (
+    MetricGrowth = "growth" // relative change of cumulative share, %
+    MetricCum    = "cum"    // cumulative share delta, percentage points
+    MetricSelf   = "self"   // self share delta, percentage points
+    MetricShare  = "share"  // cumulative share in head, %
+)
+
+// MinGrowthShare is the cumulative share of head, in percent, below which
+// growth conditions ignore a frame when the rule has no share condition.
+const MinGrowthShare = 0.1
+
+// Condition compares one metric against a limit.
+type Condition struct {
+    Metric string  `json:"metric"`
+    Op     string  `json:"op"`
+    Value  float64 `json:"value"`
+}
+
+// Threshold is a parsed gating rule.
+type Threshold struct {
+    Rule       string      `json:"rule"`
+    Frame      string      `json:"frame,omitempty"`
+    Conditions []Condition `json:"conditions"`
+
+    re *regexp.Regexp
+}
+
+// ParseThreshold parses "frame=<regex>,<metric><op><value>[%],…".  Supported
+// metrics are growth, cum, self and share; operators >, >=, < and <=.  Commas
+// inside (), [] or {} or escaped with a backslash belong to the regex, and so
+// does any later field that is not a condition, so "frame=x{1,3}" and
+// "frame=a,b,growth>1" keep their patterns whole.
+func ParseThreshold(rule string) (Threshold, error) {
+    t := Threshold{Rule: rule}
+    fields := splitRule(rule)
+    for i := 0; i < len(fields); i++ {
+        part := strings.TrimSpace(fields[i])
+        if part == "" {
+            continue
+        }
+        if pat, ok := strings.CutPrefix(part, "frame="); ok {
+            for i+1 < len(fields) {
+                if _, err := parseCondition(strings.TrimSpace(fields[i+1])); err == nil {
+                    break
+                }
+                i++
+                pat += "," + fields[i]
+            }
+            re, err := regexp.Compile(pat)
+            if err != nil {
+                return t, fmt.Errorf("threshold %q: %w", rule, err)
+            }
+            t.Frame, t.re = pat, re
+            continue
+        }
+        cond, err := parseCondition(part)
+        if err != nil {
+            return t, fmt.Errorf("threshold %q: %w", rule, err)
+        }
+        t.Conditions = append(t.Conditions, cond)
+    }
+    if len(t.Conditions) == 0 {
+        return t, fmt.Errorf("threshold %q: no condition", rule)
+    }
+    return t, nil
+}
+
+// splitRule splits rule at commas that are neither escaped nor nested in
+// brackets.
+func splitRule(rule string) []string {
+    var out []string
+    depth, start := 0, 0
+    for i := 0; i < len(rule); i++ {
+        switch rule[i] {
+        case '\\':
+            i++
+        case '(', '[', '{':
+            depth++
+        case ')', ']', '}':
+            if depth > 0 {
+                depth--
+            }
+        case ',':
+            if depth == 0 {
+                out = append(out, rule[start:i])
+                start = i + 1
+            }
+        }
+    }
+    return append(out, rule[start:])
+}
+
+// parseCondition parses one "<metric><op><value>[%]" field.
+func parseCondition(part string) (Condition, error) {
+    i := strings.IndexAny(part, "<>")
+    if i <= 0 {
+        return Condition{}, fmt.Errorf("expected frame=<regex> or <metric><op><value>, got %q", part)
+    }
+    cond := Condition{Metric: part[:i], Op: part[i : i+1]}
+    rest := part[i+1:]
+    if strings.HasPrefix(rest, "=") {
+        cond.Op += "="
+        rest = rest[1:]
+    }
+    switch cond.Metric {
+    case MetricGrowth, MetricCum, MetricSelf, MetricShare:
+    default:
+        return cond, fmt.Errorf("unknown metric %q", cond.Metric)
+    }
+    v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(rest), "%"), 64)
+    if err != nil {
+        return cond, fmt.Errorf("bad value %q", rest)
+    }
+    cond.Value = v
+    return cond, nil
+}
+
+// Violations returns the frames of cmp that break t.
+func (t Threshold) Violations(cmp *Comparison) []FrameChange {
+    var out []FrameChange
+    for _, ch := range cmp.Changes {
+        if t.re != nil && !t.re.MatchString(ch.Name) {
+            continue
+        }
+        if t.matches(ch) {
+            out = append(out, ch)
+        }
+    }
+    return out
+}
+
+func (t Threshold) matches(ch FrameChange) bool {
+    if ch.HeadCum < MinGrowthShare && t.has(MetricGrowth) && !t.has(MetricShare) {
+        return false
+    }
+    for _, c := range t.Conditions {
+        var v float64
+        switch c.Metric {
+        case MetricGrowth:
+            v = ch.Growth()
+        case MetricCum:
+            v = ch.CumDelta()
+        case MetricSelf:
+            v = ch.SelfDelta()
+        case MetricShare:
+            v = ch.HeadCum
+        }
+        var ok bool
+        switch c.Op {
+        case ">":
+            ok = v > c.Value
+        case ">=":
+            ok = v >= c.Value
+        case "<":
+            ok = v < c.Value
+        case "<=":
+            ok = v <= c.Value
+        }
+        if !ok {
+            return false
+        }
+    }
+    return true
+}
+
+// has reports whether t has a condition on metric.
+func (t Threshold) has(metric string) bool {
+    for _, c := range t.Conditions {
+        if c.Metric == metric {
+            return true
+        }
+    }
+    return false
+}
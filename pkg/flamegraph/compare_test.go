@@ -0,0 +1,95 @@
+package flamegraph
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompareThresholds(t *testing.T) {
+	base := NewBuilder("root")
+	base.Add(Sample{Stack: []string{"main", "parse"}, Weight: 20})
+	base.Add(Sample{Stack: []string{"main", "serve"}, Weight: 80})
+	base.Add(Sample{Stack: []string{BandHeap}, Weight: 1 << 20})
+
+	// Twice as long a recording: absolute weights double, only parse's
+	// share actually grows (20% → 40%).
+	head := NewBuilder("root")
+	head.Add(Sample{Stack: []string{"main", "parse"}, Weight: 80})
+	head.Add(Sample{Stack: []string{"main", "serve"}, Weight: 110})
+	head.Add(Sample{Stack: []string{"main", "gzip"}, Weight: 10})
+
+	cmp := Compare(base.Build(), head.Build())
+	if cmp.BaseTotal != 100 || cmp.HeadTotal != 200 {
+		t.Errorf("Expected totals 100/200 without bands, got %d/%d", cmp.BaseTotal, cmp.HeadTotal)
+	}
+	grew, shrank := cmp.Ranked(5)
+	if len(grew) != 2 || grew[0].Name != "parse" || grew[0].SelfDelta() != 20 {
+		t.Errorf("Expected parse to grow by 20pp first, got %+v", grew)
+	}
+	if len(shrank) != 1 || shrank[0].Name != "serve" {
+		t.Errorf("Expected serve to shrink, got %+v", shrank)
+	}
+	if g := grew[1].Growth(); grew[1].Name != "gzip" || !math.IsInf(g, 1) {
+		t.Errorf("Expected gzip to be new, got %s %v", grew[1].Name, g)
+	}
+
+	th, err := ParseThreshold("frame=^pa,growth>10%")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if v := th.Violations(cmp); len(v) != 1 || v[0].Name != "parse" {
+		t.Errorf("Expected parse to violate %q, got %+v", th.Rule, v)
+	}
+	th, _ = ParseThreshold("frame=serve,cum>=0")
+	if v := th.Violations(cmp); len(v) != 0 {
+		t.Errorf("Expected no violation for shrinking serve, got %+v", v)
+	}
+	for _, bad := range []string{"frame=x", "speed>1", "growth~3", "frame=(,self>1"} {
+		if _, err := ParseThreshold(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestParseThreshold_RegexCommas(t *testing.T) {
+	for rule, want := range map[string]string{
+		`frame=x{1,3},growth>10%`:      `x{1,3}`,
+		`frame=(a|b,c),self>=1`:        `(a|b,c)`,
+		`frame=[,;]x,cum<5`:            `[,;]x`,
+		`frame=a\,b,share>1`:           `a\,b`,
+		`frame=a,b,growth>1,self>2`:    `a,b`,
+		`growth>1,frame=^json\.,cum>0`: `^json\.`,
+	} {
+		th, err := ParseThreshold(rule)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got %v", rule, err)
+			continue
+		}
+		if th.Frame != want {
+			t.Errorf("Expected frame %q for %q, got %q", want, rule, th.Frame)
+		}
+	}
+	th, _ := ParseThreshold("frame=a,b,growth>1,self>2")
+	if len(th.Conditions) != 2 || th.Conditions[1].Metric != MetricSelf {
+		t.Errorf("Expected two conditions, got %+v", th.Conditions)
+	}
+}
+
+func TestThresholdIgnoresNoiseGrowth(t *testing.T) {
+	base := NewBuilder("root")
+	base.Add(Sample{Stack: []string{"main", "json.Marshal"}, Weight: 10000})
+	head := NewBuilder("root")
+	head.Add(Sample{Stack: []string{"main", "json.Marshal"}, Weight: 10000})
+	head.Add(Sample{Stack: []string{"main", "json.valid"}, Weight: 1}) // new, 0.01%
+	head.Add(Sample{Stack: []string{"main", "json.indent"}, Weight: 100})
+	cmp := Compare(base.Build(), head.Build())
+
+	th, _ := ParseThreshold("frame=^json,growth>10%")
+	if v := th.Violations(cmp); len(v) != 1 || v[0].Name != "json.indent" {
+		t.Errorf("Expected only the new frame above %.1f%% to violate, got %+v", MinGrowthShare, v)
+	}
+	th, _ = ParseThreshold("frame=^json,growth>10%,share>0")
+	if v := th.Violations(cmp); len(v) != 2 {
+		t.Errorf("Expected an explicit share condition to lift the minimum, got %+v", v)
+	}
+}
@@ -0,0 +1,438 @@
+// pkg/flamegraph/container.go
+// The .fgo v2 container: a recording as a sequence of timestamped snapshots
+// plus metadata, instead of the single gzipped Frame JSON of v1.
+//
+// Layout (all integers little endian / uvarint):
+//
+//	magic    "FLAREGO" 0x02
+//	block*   kind byte, uvarint payload length, payload
+//	trailer  uint64 offset of the index block, "FGOINDEX"
+//
+// Block kinds:
+//
+//	'H' Header JSON (exactly one, first)
+//	'S' Snapshot JSON, gzip compressed
+//	's' Snapshot JSON, uncompressed (record --no-compress)
+//	'I' Index JSON: end time and offset/length/time of every snapshot
+//
+// Each snapshot holds the samples taken since the previous one, so merging a
+// range of snapshots yields the profile of that time window.  The index and
+// trailer allow random access (OpenContainer); files cut short before the
+// index was written are recovered by scanning the blocks, and ReadContainer
+// reads any container sequentially from a plain io.Reader.
+package flamegraph
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// ContainerVersion is the format version written into Header.Version.
+const ContainerVersion = 2
+
+var (
+    containerMagic = []byte("FLAREGO\x02")
+    trailerMagic   = []byte("FGOINDEX")
+)
+
+const trailerSize = 16
+
+// Block kinds.
+const (
+    blockHeader   = 'H'
+    blockSnapshot = 'S'
+    blockRawSnap  = 's'
+    blockIndex    = 'I'
+)
+
+// Header describes a recording.
+type Header struct {
+    Version   int               `json:"version"`
+    Host      string            `json:"host,omitempty"`
+    PID       int               `json:"pid,omitempty"`
+    GoVersion string            `json:"go_version,omitempty"`
+    Build     string            `json:"build,omitempty"`    // main module path@version (vcs revision)
+    Samplers  []string          `json:"samplers,omitempty"` // e.g. goroutine, gc
+    Hz        int               `json:"hz,omitempty"`
+    Start     time.Time         `json:"start"`
+    End       time.Time         `json:"end"` // filled from the index when reading
+    Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// Snapshot is one timestamped tree.
+type Snapshot struct {
+    Time   time.Time         `json:"time"`
+    Labels map[string]string `json:"labels,omitempty"` // e.g. the agent that produced it
+    Root   *Frame            `json:"root"`
+}
+
+// IndexEntry locates one snapshot block.
+type IndexEntry struct {
+    Time   time.Time `json:"time"`
+    Offset int64     `json:"offset"` // of the block's kind byte
+    Length int64     `json:"length"` // whole block
+}
+
+type containerIndex struct {
+    End       time.Time    `json:"end"`
+    Snapshots []IndexEntry `json:"snapshots"`
+}
+
+// IsContainer reports whether head starts with the v2 magic.
+func IsContainer(head []byte) bool {
+    return bytes.HasPrefix(head, containerMagic)
+}
+
+//--------------------------------------------------------------------
+// writing
+//--------------------------------------------------------------------
+
+// ContainerWriter appends snapshots to a v2 container.  Close must be called
+// to write the index.
+type ContainerWriter struct {
+    w        io.Writer
+    off      int64
+    index    containerIndex
+    compress bool
+}
+
+// NewContainerWriter writes the magic and h (Version is set).  Snapshots are
+// gzip compressed unless compress is false.
+func NewContainerWriter(w io.Writer, h Header, compress bool) (*ContainerWriter, error) {
+    h.Version = ContainerVersion
+    cw := &ContainerWriter{w: w, compress: compress}
+    if err := cw.write(containerMagic); err != nil {
+        return nil, err
+    }
+    data, err := json.Marshal(h)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := cw.block(blockHeader, data); err != nil {
+        return nil, err
+    }
+    return cw, nil
+}
+
+// WriteSnapshot appends s.
+func (cw *ContainerWriter) WriteSnapshot(s Snapshot) error {
+    if s.Root == nil {
+        s.Root = New("root")
+    }
+    data, err := json.Marshal(s)
+    if err != nil {
+        return err
+    }
+    kind := byte(blockRawSnap)
+    if cw.compress {
+        var buf bytes.Buffer
+        zw := gzip.NewWriter(&buf)
+        if _, err := zw.Write(data); err != nil {
+            return err
+        }
+        if err := zw.Close(); err != nil {
+            return err
+        }
+        data, kind = buf.Bytes(), blockSnapshot
+    }
+    off := cw.off
+    n, err := cw.block(kind, data)
+    if err != nil {
+        return err
+    }
+    cw.index.Snapshots = append(cw.index.Snapshots, IndexEntry{Time: s.Time, Offset: off, Length: n})
+    return nil
+}
+
+// Close writes the index (recording end) and trailer.  It does not close the
+// underlying writer.
+func (cw *ContainerWriter) Close(end time.Time) error {
+    cw.index.End = end
+    data, err := json.Marshal(cw.index)
+    if err != nil {
+        return err
+    }
+    off := cw.off
+    if _, err := cw.block(blockIndex, data); err != nil {
+        return err
+    }
+    var tr [trailerSize]byte
+    binary.LittleEndian.PutUint64(tr[:8], uint64(off))
+    copy(tr[8:], trailerMagic)
+    return cw.write(tr[:])
+}
+
+func (cw *ContainerWriter) block(kind byte, payload []byte) (int64, error) {
+    hdr := binary.AppendUvarint([]byte{kind}, uint64(len(payload)))
+    if err := cw.write(hdr); err != nil {
+        return 0, err
+    }
+    if err := cw.write(payload); err != nil {
+        return 0, err
+    }
+    return int64(len(hdr) + len(payload)), nil
+}
+
+func (cw *ContainerWriter) write(b []byte) error {
+    n, err := cw.w.Write(b)
+    cw.off += int64(n)
+    return err
+}
+
+//--------------------------------------------------------------------
+// random access
+//--------------------------------------------------------------------
+
+// Container gives random access to the snapshots of a v2 file.
+type Container struct {
+    Header Header
+    Index  []IndexEntry // ordered by time
+
+    r io.ReaderAt
+}
+
+// OpenContainer reads the header and index of a container of the given size.
+// Without a valid trailer (e.g. a recording that was interrupted) the blocks
+// are scanned to rebuild the index.
+func OpenContainer(r io.ReaderAt, size int64) (*Container, error) {
+    magic := make([]byte, len(containerMagic))
+    if _, err := r.ReadAt(magic, 0); err != nil || !IsContainer(magic) {
+        return nil, fmt.Errorf("not a .fgo v2 container")
+    }
+    c := &Container{r: r}
+    br := bufio.NewReader(io.NewSectionReader(r, int64(len(magic)), size-int64(len(magic))))
+    kind, payload, n, err := readBlock(br)
+    if err != nil || kind != blockHeader {
+        return nil, fmt.Errorf("container header: %v", errOr(err, "missing"))
+    }
+    if err := json.Unmarshal(payload, &c.Header); err != nil {
+        return nil, fmt.Errorf("container header: %w", err)
+    }
+
+    var idx containerIndex
+    if ok := c.readIndex(size, &idx); !ok {
+        // Scan: offsets start after the magic and header block.
+        idx = containerIndex{}
+        off := int64(len(magic)) + n
+        for {
+            kind, payload, n, err := readBlock(br)
+            if err != nil {
+                break // truncated tail: keep what is complete
+            }
+            if kind == blockSnapshot || kind == blockRawSnap {
+                s, err := decodeSnapshot(kind, payload)
+                if err != nil {
+                    break
+                }
+                idx.Snapshots = append(idx.Snapshots, IndexEntry{Time: s.Time, Offset: off, Length: n})
+                if s.Time.After(idx.End) {
+                    idx.End = s.Time
+                }
+            }
+            off += n
+        }
+    }
+    c.Index = idx.Snapshots
+    sort.SliceStable(c.Index, func(i, j int) bool { return c.Index[i].Time.Before(c.Index[j].Time) })
+    if c.Header.End.IsZero() {
+        c.Header.End = idx.End
+    }
+    return c, nil
+}
+
+func (c *Container) readIndex(size int64, idx *containerIndex) bool {
+    if size < int64(len(containerMagic))+trailerSize {
+        return false
+    }
+    var tr [trailerSize]byte
+    if _, err := c.r.ReadAt(tr[:], size-trailerSize); err != nil || !bytes.Equal(tr[8:], trailerMagic) {
+        return false
+    }
+    off := int64(binary.LittleEndian.Uint64(tr[:8]))
+    if off <= 0 || off >= size-trailerSize {
+        return false
+    }
+    kind, payload, _, err := readBlock(bufio.NewReader(io.NewSectionReader(c.r, off, size-trailerSize-off)))
+    if err != nil || kind != blockIndex {
+        return false
+    }
+    return json.Unmarshal(payload, idx) == nil
+}
+
+// Len returns the number of snapshots.
+func (c *Container) Len() int { return len(c.Index) }
+
+// Snapshot decodes snapshot i (in time order).
+func (c *Container) Snapshot(i int) (*Snapshot, error) {
+    if i < 0 || i >= len(c.Index) {
+        return nil, fmt.Errorf("snapshot %d out of range [0,%d)", i, len(c.Index))
+    }
+    e := c.Index[i]
+    kind, payload, _, err := readBlock(bufio.NewReader(io.NewSectionReader(c.r, e.Offset, e.Length)))
+    if err != nil {
+        return nil, err
+    }
+    return decodeSnapshot(kind, payload)
+}
+
+// At returns the index of the last snapshot taken at or before t (the first
+// one when t precedes the recording).
+func (c *Container) At(t time.Time) int {
+    i := sort.Search(len(c.Index), func(i int) bool { return c.Index[i].Time.After(t) })
+    return max(i-1, 0)
+}
+
+// Window merges the snapshots taken within [from, to]; zero times leave that
+// side open.  It also returns how many snapshots were merged.
+func (c *Container) Window(from, to time.Time) (*Frame, int, error) {
+    root := New("root")
+    n := 0
+    for i, e := range c.Index {
+        if (!from.IsZero() && e.Time.Before(from)) || (!to.IsZero() && e.Time.After(to)) {
+            continue
+        }
+        s, err := c.Snapshot(i)
+        if err != nil {
+            return nil, n, err
+        }
+        root.Merge(s.Root)
+        n++
+    }
+    return root, n, nil
+}
+
+//--------------------------------------------------------------------
+// sequential access
+//--------------------------------------------------------------------
+
+// ReadContainer reads a whole container from r without seeking.
+func ReadContainer(r io.Reader) (Header, []Snapshot, error) {
+    var h Header
+    br := bufio.NewReader(r)
+    magic := make([]byte, len(containerMagic))
+    if _, err := io.ReadFull(br, magic); err != nil || !IsContainer(magic) {
+        return h, nil, fmt.Errorf("not a .fgo v2 container")
+    }
+    var snaps []Snapshot
+    for {
+        kind, payload, _, err := readBlock(br)
+        if err != nil {
+            if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+                break
+            }
+            return h, snaps, err
+        }
+        switch kind {
+        case blockHeader:
+            if err := json.Unmarshal(payload, &h); err != nil {
+                return h, nil, fmt.Errorf("container header: %w", err)
+            }
+        case blockSnapshot, blockRawSnap:
+            s, err := decodeSnapshot(kind, payload)
+            if err != nil {
+                return h, snaps, err
+            }
+            snaps = append(snaps, *s)
+        case blockIndex:
+            var idx containerIndex
+            if json.Unmarshal(payload, &idx) == nil && h.End.IsZero() {
+                h.End = idx.End
+            }
+            return h, snaps, nil // the trailer follows
+        default:
+            return h, snaps, fmt.Errorf("unknown block kind %q", kind)
+        }
+    }
+    return h, snaps, nil
+}
+
+// readFGO backs the fgo codec: a v2 container has all of its snapshots merged
+// into one tree, anything else is read as v1 Frame JSON.
+func readFGO(r io.Reader) (*Frame, error) {
+    br := bufio.NewReader(r)
+    if head, _ := br.Peek(len(containerMagic)); !IsContainer(head) {
+        return readFrameJSON(br)
+    }
+    _, snaps, err := ReadContainer(br)
+    if err != nil {
+        return nil, err
+    }
+    root := New("root")
+    for _, s := range snaps {
+        root.Merge(s.Root)
+    }
+    return root, nil
+}
+
+// writeFGO writes root as a container with a single snapshot.
+func writeFGO(w io.Writer, root *Frame) (Loss, error) {
+    now := time.Now()
+    cw, err := NewContainerWriter(w, Header{Start: now}, true)
+    if err != nil {
+        return nil, err
+    }
+    if err := cw.WriteSnapshot(Snapshot{Time: now, Root: root}); err != nil {
+        return nil, err
+    }
+    return nil, cw.Close(now)
+}
+
+// readBlock returns the kind, payload and total size of the next block.
+func readBlock(br *bufio.Reader) (byte, []byte, int64, error) {
+    kind, err := br.ReadByte()
+    if err != nil {
+        return 0, nil, 0, err
+    }
+    n, err := binary.ReadUvarint(br)
+    if err != nil {
+        return 0, nil, 0, io.ErrUnexpectedEOF
+    }
+    if n > 1<<30 {
+        return 0, nil, 0, fmt.Errorf("block of %d bytes too large", n)
+    }
+    payload := make([]byte, n)
+    if _, err := io.ReadFull(br, payload); err != nil {
+        return 0, nil, 0, io.ErrUnexpectedEOF
+    }
+    return kind, payload, int64(1+uvarintLen(n)) + int64(n), nil
+}
+
+func decodeSnapshot(kind byte, payload []byte) (*Snapshot, error) {
+    if kind == blockSnapshot {
+        zr, err := gzip.NewReader(bytes.NewReader(payload))
+        if err != nil {
+            return nil, err
+        }
+        if payload, err = io.ReadAll(zr); err != nil {
+            return nil, err
+        }
+    }
+    var s Snapshot
+    if err := json.Unmarshal(payload, &s); err != nil {
+        return nil, fmt.Errorf("snapshot: %w", err)
+    }
+    if s.Root == nil {
+        s.Root = New("root")
+    }
+    fixChildren(s.Root)
+    return &s, nil
+}
+
+func uvarintLen(x uint64) int {
+    return len(binary.AppendUvarint(nil, x))
+}
+
+func errOr(err error, msg string) string {
+    if err != nil {
+        return err.Error()
+    }
+    return msg
+}
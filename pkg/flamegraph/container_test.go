@@ -0,0 +1,91 @@
+package flamegraph
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+)
+
+func writeTestContainer(t *testing.T, close bool) ([]byte, time.Time) {
+	t.Helper()
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	cw, err := NewContainerWriter(&buf, Header{Host: "h", PID: 42, Hz: 100, Start: start}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"a", "b", "c"} {
+		root := New("root")
+		root.AddSample([]string{"main", name}, int64(10*(i+1)))
+		if err := cw.WriteSnapshot(Snapshot{Time: start.Add(time.Duration(i+1) * time.Second), Root: root}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if close {
+		if err := cw.Close(start.Add(4 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes(), start
+}
+
+func TestContainerRandomAccess(t *testing.T) {
+	data, start := writeTestContainer(t, true)
+	c, err := OpenContainer(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Header.Version != ContainerVersion || c.Header.PID != 42 || !c.Header.End.Equal(start.Add(4*time.Second)) {
+		t.Errorf("Unexpected header %+v", c.Header)
+	}
+	if c.Len() != 3 {
+		t.Fatalf("Expected 3 snapshots, got %d", c.Len())
+	}
+	if i := c.At(start.Add(2500 * time.Millisecond)); i != 1 {
+		t.Errorf("Expected snapshot 1 at +2.5s, got %d", i)
+	}
+	root, n, err := c.Window(start.Add(2*time.Second), time.Time{})
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 snapshots in window, got %d (%v)", n, err)
+	}
+	if got := Total(root); got != 50 {
+		t.Errorf("Expected window total 50, got %d", got)
+	}
+}
+
+func TestContainerTruncatedAndSequential(t *testing.T) {
+	data, _ := writeTestContainer(t, false)
+	c, err := OpenContainer(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 3 {
+		t.Errorf("Expected index to be rebuilt with 3 snapshots, got %d", c.Len())
+	}
+
+	root, f, err := Read(bytes.NewReader(data))
+	if err != nil || f != FormatFGO {
+		t.Fatalf("Read: format %s, err %v", f, err)
+	}
+	if got := Total(root); got != 60 {
+		t.Errorf("Expected merged total 60, got %d", got)
+	}
+}
+
+func TestReadLegacyFGO(t *testing.T) {
+	want := New("root")
+	want.AddSample([]string{"main"}, 7)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	writeFrameJSON(zw, want)
+	zw.Close()
+
+	got, f, err := Read(&buf)
+	if err != nil || f != FormatFGO {
+		t.Fatalf("Read: format %s, err %v", f, err)
+	}
+	if d := Diff(got, want); d != nil {
+		t.Errorf("v1 file changed on read: %+v", d)
+	}
+}
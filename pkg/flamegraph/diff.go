@@ -6,6 +6,8 @@
 // colour‑coding: positive (growth) vs negative (shrink).
 package flamegraph
 
+import "sort"
+
 // Diff computes the difference between head and base flame graphs.  The
 // returned *Frame has Value = head.Value - base.Value.  Children present in
 // either tree are diffed recursively; unchanged subtrees (delta == 0 and no
@@ -56,3 +58,60 @@ func Diff(head, base *Frame) *Frame {
     }
     return node
 }
+
+// Change is the aggregated self‑weight delta of one frame name inside a diff
+// tree (see TopChanges).
+type Change struct {
+    Name  string `json:"name"`
+    Delta int64  `json:"delta"`
+}
+
+// TopChanges walks a tree produced by Diff and returns up to n frame names
+// whose self delta (node delta minus the deltas of its children) grew the
+// most and shrank the most.  Deltas of the same name at different depths are
+// summed.  The root itself is ignored.
+func TopChanges(diff *Frame, n int) (grew, shrank []Change) {
+    if diff == nil || n <= 0 {
+        return nil, nil
+    }
+    byName := make(map[string]int64)
+    var walk func(*Frame)
+    walk = func(f *Frame) {
+        self := f.Value
+        for _, c := range f.Children {
+            self -= c.Value
+            walk(c)
+        }
+        byName[f.Name] += self
+    }
+    for _, c := range diff.Children {
+        walk(c)
+    }
+    for name, d := range byName {
+        switch {
+        case d > 0:
+            grew = append(grew, Change{Name: name, Delta: d})
+        case d < 0:
+            shrank = append(shrank, Change{Name: name, Delta: d})
+        }
+    }
+    sort.Slice(grew, func(i, j int) bool {
+        if grew[i].Delta != grew[j].Delta {
+            return grew[i].Delta > grew[j].Delta
+        }
+        return grew[i].Name < grew[j].Name
+    })
+    sort.Slice(shrank, func(i, j int) bool {
+        if shrank[i].Delta != shrank[j].Delta {
+            return shrank[i].Delta < shrank[j].Delta
+        }
+        return shrank[i].Name < shrank[j].Name
+    })
+    if len(grew) > n {
+        grew = grew[:n]
+    }
+    if len(shrank) > n {
+        shrank = shrank[:n]
+    }
+    return grew, shrank
+}
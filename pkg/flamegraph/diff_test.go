@@ -0,0 +1,29 @@
+package flamegraph
+
+import "testing"
+
+func TestTopChanges(t *testing.T) {
+	base := NewBuilder("root")
+	base.Add(Sample{Stack: []string{"main", "work"}, Weight: 100})
+	base.Add(Sample{Stack: []string{"main", "io"}, Weight: 50})
+	head := NewBuilder("root")
+	head.Add(Sample{Stack: []string{"main", "work"}, Weight: 100})
+	head.Add(Sample{Stack: []string{"main", "lock"}, Weight: 30})
+	head.Add(Sample{Stack: []string{"other", "lock"}, Weight: 10})
+	head.Add(Sample{Stack: []string{"main", "io"}, Weight: 20})
+
+	diff := Diff(head.Build(), base.Build())
+	if diff.Children["main"].Children["work"] != nil {
+		t.Errorf("Expected unchanged frames pruned from the diff")
+	}
+	grew, shrank := TopChanges(diff, 1)
+	if len(grew) != 1 || grew[0] != (Change{Name: "lock", Delta: 40}) {
+		t.Errorf("Expected lock +40 summed across callers, got %+v", grew)
+	}
+	if len(shrank) != 1 || shrank[0] != (Change{Name: "io", Delta: -30}) {
+		t.Errorf("Expected io -30, got %+v", shrank)
+	}
+	if g, s := TopChanges(nil, 3); g != nil || s != nil {
+		t.Errorf("Expected nothing for a nil diff")
+	}
+}
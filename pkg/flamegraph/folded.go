@@ -0,0 +1,110 @@
+// pkg/flamegraph/folded.go
+// Brendan Gregg's folded ("collapsed") stack format: one line per unique
+// stack, frames joined by ';' outermost first, followed by a space and the
+// sample count:
+//
+//	main;net/http.(*conn).serve;runtime.mallocgc 42
+//
+// The format has no root label, no units and only non‑negative integer
+// counts, which is what WriteFolded reports as Loss.
+package flamegraph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ReadFolded parses folded stacks into a tree rooted at "root".  Blank lines
+// and lines starting with '#' are ignored.
+func ReadFolded(r io.Reader) (*Frame, error) {
+    root := New("root")
+    sc := bufio.NewScanner(r)
+    sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+    n := 0
+    for sc.Scan() {
+        n++
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        stack, weight, err := parseFoldedLine(line)
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %w", n, err)
+        }
+        root.AddSample(stack, weight)
+    }
+    return root, sc.Err()
+}
+
+func parseFoldedLine(line string) ([]string, int64, error) {
+    i := strings.LastIndexAny(line, " \t")
+    if i <= 0 {
+        return nil, 0, fmt.Errorf("missing sample count")
+    }
+    // perf/dtrace collapsers may emit fractional counts; round them.
+    f, err := strconv.ParseFloat(line[i+1:], 64)
+    if err != nil {
+        return nil, 0, fmt.Errorf("bad sample count %q", line[i+1:])
+    }
+    return strings.Split(strings.TrimSpace(line[:i]), ";"), int64(f + 0.5), nil
+}
+
+// WriteFolded writes one line per stack with non‑zero self weight, sorted.
+func WriteFolded(w io.Writer, root *Frame) (Loss, error) {
+    var (
+        loss      Loss
+        negative  int
+        semicolon = map[string]bool{}
+    )
+    bw := bufio.NewWriter(w)
+    WalkSelf(root, func(stack []string, self int64) {
+        if self < 0 {
+            negative++
+            return
+        }
+        names := make([]string, len(stack))
+        for i, s := range stack {
+            if strings.ContainsAny(s, ";\n") {
+                semicolon[s] = true
+                s = strings.NewReplacer(";", ":", "\n", " ").Replace(s)
+            }
+            names[i] = s
+        }
+        fmt.Fprintf(bw, "%s %d\n", strings.Join(names, ";"), self)
+    })
+    if root != nil && root.Name != "root" && root.Name != "" {
+        loss = append(loss, fmt.Sprintf("root label %q dropped (folded stacks have no root)", root.Name))
+    }
+    if negative > 0 {
+        loss = append(loss, fmt.Sprintf("%d stack(s) with negative weight dropped", negative))
+    }
+    if len(semicolon) > 0 {
+        loss = append(loss, fmt.Sprintf("%d frame name(s) containing ';' rewritten to ':'", len(semicolon)))
+    }
+    return loss, bw.Flush()
+}
+
+// detectFolded accepts text whose first meaningful line ends in a number.
+func detectFolded(head []byte) bool {
+    if !utf8.Valid(head[:max(0, len(head)-utf8.UTFMax)]) {
+        return false
+    }
+    for _, c := range head {
+        if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+            return false // binary, e.g. an uncompressed profile.proto
+        }
+    }
+    for _, line := range strings.Split(string(head), "\n") {
+        line = strings.TrimSpace(line)
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        _, _, err := parseFoldedLine(line)
+        return err == nil
+    }
+    return false
+}
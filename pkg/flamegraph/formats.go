@@ -0,0 +1,266 @@
+// pkg/flamegraph/formats.go
+// Readers and writers for the on‑disk formats FlareGo exchanges with other
+// tools.  Each format is a Codec; the built‑in ones are
+//
+//	fgo        – the v2 container of `flarego record` (container.go), read as
+//	             the merge of its snapshots; v1 gzipped Frame JSON is still read
+//	json       – Frame JSON, written as the ordered v2 document (schema.go)
+//	folded     – Brendan Gregg collapsed stacks ("a;b;c 42")
+//	speedscope – speedscope file format (sampled and evented profiles)
+//
+// and pkg/flamegraph/pprof registers "pprof" from its init function, the same
+// way image decoders register with package image.  Read detects the format
+// from the content (after transparently removing gzip), so callers rarely need
+// to name it.
+//
+// Writers return a Loss describing anything the target format cannot
+// represent, e.g. negative weights in folded stacks; an empty Loss means the
+// conversion round‑trips.
+package flamegraph
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Format names a Codec.
+type Format string
+
+// Built‑in formats.
+const (
+    FormatFGO        Format = "fgo"
+    FormatJSON       Format = "json"
+    FormatFolded     Format = "folded"
+    FormatSpeedscope Format = "speedscope"
+    FormatPprof      Format = "pprof" // provided by pkg/flamegraph/pprof
+)
+
+// Loss lists, in human terms, what a writer had to drop or alter.
+type Loss []string
+
+// Codec reads and writes one format.
+type Codec struct {
+    Format     Format
+    Extensions []string // e.g. ".folded"; used to pick a writer from a file name
+    Gzipped    bool     // Write output is gzip compressed (detection strips it first)
+
+    // Detect reports whether head (the first bytes, already decompressed)
+    // looks like this format.
+    Detect func(head []byte) bool
+    Read   func(r io.Reader) (*Frame, error)
+    Write  func(w io.Writer, root *Frame) (Loss, error)
+}
+
+var (
+    codecsMu sync.RWMutex
+    codecs   []Codec
+)
+
+// RegisterCodec adds c.  Codecs are tried by Read in registration order, so
+// formats with precise signatures register before loose ones.
+func RegisterCodec(c Codec) {
+    codecsMu.Lock()
+    defer codecsMu.Unlock()
+    for i, old := range codecs {
+        if old.Format == c.Format {
+            codecs[i] = c
+            return
+        }
+    }
+    codecs = append(codecs, c)
+}
+
+// Codecs returns the registered codecs.
+func Codecs() []Codec {
+    codecsMu.RLock()
+    defer codecsMu.RUnlock()
+    return append([]Codec(nil), codecs...)
+}
+
+// LookupCodec returns the codec for f.
+func LookupCodec(f Format) (Codec, bool) {
+    for _, c := range Codecs() {
+        if c.Format == f {
+            return c, true
+        }
+    }
+    return Codec{}, false
+}
+
+// FormatForPath picks a format from a file name suffix (".gz" ignored); ok
+// is false when no codec claims it.
+func FormatForPath(path string) (Format, bool) {
+    path = strings.TrimSuffix(strings.ToLower(path), ".gz")
+    var best Format
+    n := 0
+    for _, c := range Codecs() {
+        for _, e := range c.Extensions {
+            if strings.HasSuffix(path, e) && len(e) > n {
+                best, n = c.Format, len(e)
+            }
+        }
+    }
+    return best, n > 0
+}
+
+// Read detects the format of r and decodes it.  Gzip compression is removed
+// first whatever the format; a gzipped Frame JSON is reported as fgo.
+func Read(r io.Reader) (*Frame, Format, error) {
+    br := bufio.NewReaderSize(r, 64*1024)
+    zipped := false
+    if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+        zr, err := gzip.NewReader(br)
+        if err != nil {
+            return nil, "", err
+        }
+        defer zr.Close()
+        br, zipped = bufio.NewReaderSize(zr, 64*1024), true
+    }
+    head, _ := br.Peek(4096)
+    for _, c := range Codecs() {
+        if c.Detect == nil || !c.Detect(head) {
+            continue
+        }
+        root, err := c.Read(br)
+        if err != nil {
+            return nil, c.Format, fmt.Errorf("%s: %w", c.Format, err)
+        }
+        f := c.Format
+        if f == FormatJSON && zipped {
+            f = FormatFGO
+        }
+        return root, f, nil
+    }
+    return nil, "", fmt.Errorf("unrecognised profile format")
+}
+
+// ReadFormat decodes r as format f, removing gzip compression if present.
+func ReadFormat(r io.Reader, f Format) (*Frame, error) {
+    c, ok := LookupCodec(f)
+    if !ok {
+        return nil, fmt.Errorf("unknown format %q", f)
+    }
+    br := bufio.NewReader(r)
+    if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+        zr, err := gzip.NewReader(br)
+        if err != nil {
+            return nil, err
+        }
+        defer zr.Close()
+        return c.Read(zr)
+    }
+    return c.Read(br)
+}
+
+// Write encodes root as format f.
+func Write(w io.Writer, root *Frame, f Format) (Loss, error) {
+    c, ok := LookupCodec(f)
+    if !ok {
+        return nil, fmt.Errorf("unknown format %q", f)
+    }
+    return c.Write(w, root)
+}
+
+// WalkSelf calls fn for every node with non‑zero self weight (its value minus
+// its children's) with the path below root, outermost first.  The stack slice
+// is reused between calls.
+func WalkSelf(root *Frame, fn func(stack []string, self int64)) {
+    if root == nil {
+        return
+    }
+    var stack []string
+    var walk func(*Frame)
+    walk = func(f *Frame) {
+        stack = append(stack, f.Name)
+        if self := SelfValue(f); self != 0 {
+            fn(stack, self)
+        }
+        for _, name := range sortedNames(f) {
+            walk(f.Children[name])
+        }
+        stack = stack[:len(stack)-1]
+    }
+    for _, name := range sortedNames(root) {
+        walk(root.Children[name])
+    }
+}
+
+//--------------------------------------------------------------------
+// fgo / json
+//--------------------------------------------------------------------
+
+func init() {
+    RegisterCodec(Codec{
+        Format:     FormatSpeedscope,
+        Extensions: []string{".speedscope", ".speedscope.json"},
+        Detect:     detectSpeedscope,
+        Read:       ReadSpeedscope,
+        Write:      WriteSpeedscope,
+    })
+    RegisterCodec(Codec{
+        Format:     FormatJSON,
+        Extensions: []string{".json"},
+        Detect:     detectFrameJSON,
+        Read:       readFrameJSON,
+        Write:      writeFrameJSON,
+    })
+    RegisterCodec(Codec{
+        Format:     FormatFGO,
+        Extensions: []string{".fgo"},
+        Detect:     IsContainer, // v1 files are detected as json inside gzip
+        Read:       readFGO,
+        Write:      writeFGO,
+    })
+    RegisterCodec(Codec{
+        Format:     FormatFolded,
+        Extensions: []string{".folded", ".collapsed", ".txt"},
+        Detect:     detectFolded,
+        Read:       ReadFolded,
+        Write:      WriteFolded,
+    })
+}
+
+func detectFrameJSON(head []byte) bool {
+    head = bytes.TrimSpace(head)
+    return len(head) > 0 && head[0] == '{' && (bytes.Contains(head, []byte(`"name"`)) || bytes.Contains(head, []byte(`"version"`)))
+}
+
+func readFrameJSON(r io.Reader) (*Frame, error) {
+    var root Frame
+    if err := json.NewDecoder(r).Decode(&root); err != nil {
+        return nil, err
+    }
+    fixChildren(&root)
+    return &root, nil
+}
+
+func writeFrameJSON(w io.Writer, root *Frame) (Loss, error) {
+    return nil, EncodeJSON(w, root, JSONOptions{})
+}
+
+// fixChildren allocates the Children maps JSON leaves omit so decoded trees
+// can be merged into.
+func fixChildren(f *Frame) {
+    if f.Children == nil {
+        f.Children = make(map[string]*Frame)
+    }
+    for _, c := range f.Children {
+        fixChildren(c)
+    }
+}
+
+func sortedNames(f *Frame) []string {
+    names := make([]string, 0, len(f.Children))
+    for k := range f.Children {
+        names = append(names, k)
+    }
+    sort.Strings(names)
+    return names
+}
@@ -0,0 +1,50 @@
+package flamegraph
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFormatsRoundTrip(t *testing.T) {
+	b := NewBuilder("root")
+	b.Add(Sample{Stack: []string{"main", "parse"}, Weight: 20})
+	b.Add(Sample{Stack: []string{"main", "serve"}, Weight: 80})
+	b.Add(Sample{Stack: []string{"main"}, Weight: 5})
+	want := b.Build()
+
+	for _, f := range []Format{FormatFGO, FormatJSON, FormatFolded, FormatSpeedscope} {
+		var buf bytes.Buffer
+		loss, err := Write(&buf, want, f)
+		if err != nil || len(loss) != 0 {
+			t.Fatalf("%s: write err=%v loss=%v", f, err, loss)
+		}
+		got, detected, err := Read(&buf)
+		if err != nil {
+			t.Fatalf("%s: read: %v", f, err)
+		}
+		if detected != f {
+			t.Errorf("Expected %s to be detected, got %s", f, detected)
+		}
+		if d := Diff(got, want); d != nil {
+			t.Errorf("%s: round trip changed the tree: %+v", f, d)
+		}
+	}
+}
+
+func TestWriteFoldedLoss(t *testing.T) {
+	root := New("heap")
+	root.AddSample([]string{"alloc;free"}, 5)
+	root.AddSample([]string{"release"}, -3)
+
+	var buf bytes.Buffer
+	loss, err := WriteFolded(&buf, root)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if buf.String() != "alloc:free 5\n" {
+		t.Errorf("Expected only the positive stack, got %q", buf.String())
+	}
+	if len(loss) != 3 {
+		t.Errorf("Expected root label, negative weight and ';' losses, got %v", loss)
+	}
+}
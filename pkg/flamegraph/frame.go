@@ -9,7 +9,6 @@ package flamegraph
 
 import (
 	"encoding/json"
-	"sort"
 	"sync"
 )
 
@@ -80,54 +79,39 @@ func (dst *Frame) Merge(src *Frame) {
     }
 }
 
-// ToJSON marshals Frame (and subtree) sorted by descending Value so that the
-// UI can assume stable ordering.
+// ToJSON marshals f in the version 1 form (children keyed by name) that
+// agents, the gateway and the web UI exchange.  Tools should prefer the
+// ordered version 2 document written by EncodeJSON (see schema.go).
 func (f *Frame) ToJSON() ([]byte, error) {
-    ordered := f.sortedCopy()
-    return json.Marshal(ordered)
-}
-
-// UnmarshalJSON populates f; implements json.Unmarshaler so that replay command
-// can read saved .fgo files back into Frame.
-func (f *Frame) UnmarshalJSON(b []byte) error {
-    type alias Frame // avoid recursion
-    var tmp alias
-    if err := json.Unmarshal(b, &tmp); err != nil {
-        return err
-    }
-    *f = Frame(tmp)
-    return nil
+    return json.Marshal(f)
 }
 
 // Flatten returns slice of rows useful for CLI summaries.
 type Row struct {
     Name       string
     Depth      int
-    Self       int64
-    Cumulative int64
+    Self       int64 // value minus the children's values
+    Cumulative int64 // the node's (inclusive) value
 }
 
-// Flatten depth‐first traverses tree accumulating cumulative values.
+// Flatten lists the tree depth first, root first, children by name.  Node
+// values are inclusive already, so Cumulative is the value itself and Self
+// what remains after subtracting the children.  A zero root (as builders
+// leave it) counts as the sum of its children.
 func (f *Frame) Flatten() []Row {
     var rows []Row
-    var dfs func(*Frame, int) int64
-    dfs = func(n *Frame, depth int) int64 {
-        cum := n.Value
-        // sort child names for deterministic output
-        names := make([]string, 0, len(n.Children))
-        for k := range n.Children { names = append(names, k) }
-        sort.Strings(names)
-        for _, k := range names {
-            cum += dfs(n.Children[k], depth+1)
+    var dfs func(*Frame, int)
+    dfs = func(n *Frame, depth int) {
+        row := Row{Name: n.Name, Depth: depth, Self: SelfValue(n), Cumulative: n.Value}
+        if depth == 0 && n.Value == 0 {
+            row.Self, row.Cumulative = 0, Total(n)
+        }
+        rows = append(rows, row)
+        for _, k := range sortedNames(n) {
+            dfs(n.Children[k], depth+1)
         }
-        rows = append(rows, Row{Name: n.Name, Depth: depth, Self: n.Value, Cumulative: cum})
-        return cum
     }
     dfs(f, 0)
-    // reverse rows so root first
-    for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
-        rows[i], rows[j] = rows[j], rows[i]
-    }
     return rows
 }
 
@@ -143,21 +127,3 @@ func deepCopy(src *Frame) *Frame {
     }
     return dst
 }
-
-// sortedCopy returns deepcopy of tree where each Children map is replaced by a
-// slice sorted by Value descending for deterministic UI traversal.
-func (f *Frame) sortedCopy() *Frame {
-    out := &Frame{Name: f.Name, Value: f.Value}
-    if len(f.Children) == 0 {
-        return out
-    }
-    type kv struct{ k string; v *Frame }
-    var arr []kv
-    for k, v := range f.Children { arr = append(arr, kv{k,v}) }
-    sort.Slice(arr, func(i, j int) bool { return arr[i].v.Value > arr[j].v.Value })
-    out.Children = make(map[string]*Frame, len(arr))
-    for _, kv := range arr {
-        out.Children[kv.k] = kv.v.sortedCopy()
-    }
-    return out
-}
@@ -0,0 +1,168 @@
+// pkg/flamegraph/granularity.go
+// Frame names are Go function names, either fully qualified as pprof reports
+// them ("github.com/org/svc/internal/db.(*Pool).Get") or trimmed to
+// "pkg.Func" by the agent's samplers.  PackageOf and ModuleOf map a name to
+// a coarser unit so analyses can answer "how much is grpc vs. our code vs.
+// database/sql" rather than list thousands of functions.
+//
+// Trimmed names keep only the last package path element, so their module
+// cannot be recovered: top‑level standard library packages ("sync",
+// "runtime") map to "std", anything else to the package itself.  That
+// includes nested standard packages such as "http" or "sql", which a trimmed
+// name cannot tell apart from a third‑party package of the same name.  Names
+// that are not Go functions (runtime bands, addresses, "root") map to
+// themselves.
+//
+// GroupBy turns the mapping into a Transform: every frame is renamed to its
+// unit and consecutive frames of the same unit merge, so a package calling
+// itself twenty levels deep becomes one frame and the tree reads as the
+// hand‑offs between units.
+package flamegraph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Granularity selects the unit frames are grouped by.
+type Granularity string
+
+const (
+    ByFunction Granularity = "function"
+    ByPackage  Granularity = "package"
+    ByModule   Granularity = "module"
+)
+
+// ParseGranularity accepts function, package or module (and their first
+// letters).
+func ParseGranularity(s string) (Granularity, error) {
+    switch s {
+    case "function", "func", "f", "":
+        return ByFunction, nil
+    case "package", "pkg", "p":
+        return ByPackage, nil
+    case "module", "mod", "m":
+        return ByModule, nil
+    }
+    return "", fmt.Errorf("unknown granularity %q (want function, package or module)", s)
+}
+
+// Key maps a frame name to its unit at granularity g.
+func (g Granularity) Key(name string) string {
+    switch g {
+    case ByPackage:
+        return PackageOf(name)
+    case ByModule:
+        return ModuleOf(name)
+    }
+    return name
+}
+
+// GroupBy returns the Transform re‑keying every frame to its unit at
+// granularity g and merging consecutive frames of the same unit.  Runtime
+// bands are kept as they are.
+func GroupBy(g Granularity) Transform {
+    return func(root *Frame) *Frame {
+        return rewriteStacks(root, func(stack []string) []string {
+            out := make([]string, 0, len(stack))
+            for _, name := range stack {
+                k := g.Key(name)
+                if len(out) == 0 || out[len(out)-1] != k {
+                    out = append(out, k)
+                }
+            }
+            return out
+        })
+    }
+}
+
+// PackageOf returns the import path (or trimmed package name) of a Go
+// function name.
+func PackageOf(name string) string {
+    if name == "" || strings.HasPrefix(name, "(") || strings.HasPrefix(name, "0x") {
+        return name
+    }
+    dir, last := "", name
+    if i := strings.LastIndex(name, "/"); i >= 0 {
+        dir, last = name[:i+1], name[i+1:]
+    }
+    // The package ends at the first dot of the last path element, except
+    // for gopkg.in style version suffixes ("yaml.v3.Unmarshal").
+    dot := strings.IndexByte(last, '.')
+    if dot < 0 {
+        return name
+    }
+    if rest := last[dot+1:]; len(rest) > 2 && rest[0] == 'v' && rest[1] >= '0' && rest[1] <= '9' {
+        if j := strings.IndexByte(rest, '.'); j > 0 && isDigits(rest[1:j]) {
+            dot += 1 + j
+        }
+    }
+    return dir + last[:dot]
+}
+
+// ModuleOf returns the Go module of a function name: "std" for the standard
+// library, the repository root for hosted import paths (github.com/org/repo,
+// golang.org/x/net, google.golang.org/grpc) and the package for trimmed
+// names of other code.
+func ModuleOf(name string) string {
+    pkg := PackageOf(name)
+    if pkg == name && !strings.Contains(name, ".") {
+        return name // not a Go function name
+    }
+    parts := strings.Split(pkg, "/")
+    if !strings.Contains(parts[0], ".") {
+        if stdPackages[parts[0]] && (len(parts) > 1 || !stdDirs[parts[0]]) {
+            return "std"
+        }
+        return pkg
+    }
+    n := 2
+    switch parts[0] {
+    case "github.com", "gitlab.com", "bitbucket.org", "golang.org", "sigs.k8s.io":
+        n = 3
+    }
+    // Major version suffixes belong to the module path.
+    if len(parts) > n && len(parts[n]) > 1 && parts[n][0] == 'v' && isDigits(parts[n][1:]) {
+        n++
+    }
+    if len(parts) < n {
+        n = len(parts)
+    }
+    return strings.Join(parts[:n], "/")
+}
+
+func isDigits(s string) bool {
+    if s == "" {
+        return false
+    }
+    for _, r := range s {
+        if r < '0' || r > '9' {
+            return false
+        }
+    }
+    return true
+}
+
+// stdPackages holds the first path element of every standard library
+// package.
+var stdPackages = map[string]bool{
+    "archive": true, "bufio": true, "builtin": true, "bytes": true, "cmp": true,
+    "compress": true, "container": true, "context": true, "crypto": true,
+    "database": true, "debug": true, "embed": true, "encoding": true,
+    "errors": true, "expvar": true, "flag": true, "fmt": true, "go": true,
+    "hash": true, "html": true, "image": true, "index": true, "internal": true,
+    "io": true, "iter": true, "log": true, "maps": true, "math": true,
+    "mime": true, "net": true, "os": true, "path": true, "plugin": true,
+    "reflect": true, "regexp": true, "runtime": true, "slices": true,
+    "sort": true, "strconv": true, "strings": true, "structs": true,
+    "sync": true, "syscall": true, "testing": true, "text": true, "time": true,
+    "unicode": true, "unique": true, "unsafe": true, "vendor": true, "weak": true,
+}
+
+// stdDirs holds the entries of stdPackages that only contain packages and
+// are none themselves, so a trimmed name with that package is not std.
+var stdDirs = map[string]bool{
+    "archive": true, "compress": true, "container": true, "database": true,
+    "debug": true, "go": true, "index": true, "internal": true, "text": true,
+    "vendor": true,
+}
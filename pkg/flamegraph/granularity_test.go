@@ -0,0 +1,51 @@
+package flamegraph
+
+import "testing"
+
+func TestGranularity(t *testing.T) {
+	cases := []struct{ name, pkg, mod string }{
+		{"github.com/org/svc/internal/db.(*Pool).Get", "github.com/org/svc/internal/db", "github.com/org/svc"},
+		{"google.golang.org/grpc.(*Server).Serve", "google.golang.org/grpc", "google.golang.org/grpc"},
+		{"gopkg.in/yaml.v3.Unmarshal", "gopkg.in/yaml.v3", "gopkg.in/yaml.v3"},
+		{"github.com/jackc/pgx/v5/pgconn.Connect", "github.com/jackc/pgx/v5/pgconn", "github.com/jackc/pgx/v5"},
+		{"database/sql.(*DB).Query", "database/sql", "std"},
+		{"runtime.mallocgc", "runtime", "std"},
+		{"sampler.(*GoroutineSampler).loop", "sampler", "sampler"},
+		{"http.(*conn).serve", "http", "http"},
+		{"sync.(*Mutex).Lock", "sync", "std"},
+		{"compress.Helper", "compress", "compress"},
+		{"(GC)", "(GC)", "(GC)"},
+	}
+	for _, c := range cases {
+		if got := PackageOf(c.name); got != c.pkg {
+			t.Errorf("PackageOf(%q) = %q, expected %q", c.name, got, c.pkg)
+		}
+		if got := ModuleOf(c.name); got != c.mod {
+			t.Errorf("ModuleOf(%q) = %q, expected %q", c.name, got, c.mod)
+		}
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	b := NewBuilder("root")
+	b.Add(Sample{Stack: []string{"main.main", "http.(*Server).Serve", "http.(*conn).serve", "api.Handle", "sync.(*Mutex).Lock"}, Weight: 7})
+	b.Add(Sample{Stack: []string{"main.main", "http.(*Server).Serve", "api.Handle", "api.render"}, Weight: 3})
+	b.Add(Sample{Stack: []string{BandGC}, Weight: 50})
+	root := b.Build()
+
+	out := GroupBy(ByModule)(root)
+	http := out.Children["main"].Children["http"]
+	if http == nil || http.Value != 10 || len(http.Children) != 1 {
+		t.Fatalf("Expected consecutive http frames merged, got %+v", http)
+	}
+	api := http.Children["api"]
+	if api == nil || api.Value != 10 || SelfValue(api) != 3 || api.Children["std"].Value != 7 {
+		t.Errorf("Expected api with its standard library call below, got %+v", api)
+	}
+	if out.Children[BandGC] == nil || out.Children["main"].Value != 10 {
+		t.Errorf("Expected bands kept and totals preserved")
+	}
+	if _, err := ParseTransform("group=crate"); err == nil {
+		t.Error("Expected error for unknown granularity")
+	}
+}
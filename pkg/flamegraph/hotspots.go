@@ -0,0 +1,218 @@
+// pkg/flamegraph/hotspots.go
+// Per‑function aggregation used by terminal views and reports.  A frame name
+// may appear at many places in the tree; Hotspots folds them into one row:
+//
+//   • Self – node value minus the values of its children, summed over all
+//            occurrences
+//   • Cum  – node value summed over the *outermost* occurrences only, so
+//            recursive functions are not counted once per recursion level
+//
+// Node values are inclusive (AddSample adds the weight to every frame on the
+// path), which is what makes Self a subtraction.  HotspotsBy folds by any key
+// instead of the name (e.g. Granularity.Key), and Peek lists the callers and
+// callees of selected functions like `pprof -peek`.
+package flamegraph
+
+import (
+	"regexp"
+	"sort"
+)
+
+// Hotspot is the aggregated weight of one frame name.
+type Hotspot struct {
+    Name string `json:"name"`
+    Self int64  `json:"self"`
+    Cum  int64  `json:"cum"`
+}
+
+// Hotspots aggregates every frame below root by name and returns the rows
+// ordered by Cum descending (ties by name).  The root itself is skipped.
+func Hotspots(root *Frame) []Hotspot {
+    return HotspotsBy(root, nil)
+}
+
+// HotspotsBy is Hotspots with frames grouped by key(name); a nil key groups
+// by name.  Cum counts the outermost frame of each key on a path, so a
+// package calling itself is not counted twice.
+func HotspotsBy(root *Frame, key func(string) string) []Hotspot {
+    if root == nil {
+        return nil
+    }
+    if key == nil {
+        key = func(name string) string { return name }
+    }
+    agg := make(map[string]*Hotspot)
+    onPath := make(map[string]int)
+    var walk func(*Frame)
+    walk = func(f *Frame) {
+        k := key(f.Name)
+        h, ok := agg[k]
+        if !ok {
+            h = &Hotspot{Name: k}
+            agg[k] = h
+        }
+        h.Self += SelfValue(f)
+        if onPath[k] == 0 {
+            h.Cum += f.Value
+        }
+        onPath[k]++
+        for _, c := range f.Children {
+            walk(c)
+        }
+        onPath[k]--
+    }
+    for _, c := range root.Children {
+        walk(c)
+    }
+
+    out := make([]Hotspot, 0, len(agg))
+    for _, h := range agg {
+        out = append(out, *h)
+    }
+    sortHotspots(out)
+    return out
+}
+
+func sortHotspots(hs []Hotspot) {
+    sort.Slice(hs, func(i, j int) bool {
+        if hs[i].Cum != hs[j].Cum {
+            return hs[i].Cum > hs[j].Cum
+        }
+        return hs[i].Name < hs[j].Name
+    })
+}
+
+// SelfValue is f's value minus its children's.
+func SelfValue(f *Frame) int64 {
+    self := f.Value
+    for _, c := range f.Children {
+        self -= c.Value
+    }
+    return self
+}
+
+// Edge is the weight passing between a function and one caller or callee.
+type Edge struct {
+    Name  string `json:"name"`
+    Value int64  `json:"value"`
+}
+
+// PeekEntry lists one function with its callers and callees, heaviest first.
+type PeekEntry struct {
+    Hotspot
+    Callers []Edge `json:"callers"`
+    Callees []Edge `json:"callees"`
+}
+
+// Peek returns an entry for every frame name matching re, ordered by Cum.
+// A caller edge carries the inclusive value of the calls from that caller; a
+// callee edge the inclusive value of the callee.  Frames called directly from
+// the root have no caller.
+func Peek(root *Frame, re *regexp.Regexp) []PeekEntry {
+    if root == nil {
+        return nil
+    }
+    type acc struct {
+        h                Hotspot
+        callers, callees map[string]int64
+    }
+    agg := make(map[string]*acc)
+    onPath := make(map[string]int)
+    var walk func(f, parent *Frame)
+    walk = func(f, parent *Frame) {
+        if re.MatchString(f.Name) {
+            a, ok := agg[f.Name]
+            if !ok {
+                a = &acc{h: Hotspot{Name: f.Name}, callers: map[string]int64{}, callees: map[string]int64{}}
+                agg[f.Name] = a
+            }
+            a.h.Self += SelfValue(f)
+            if onPath[f.Name] == 0 {
+                a.h.Cum += f.Value
+            }
+            if parent != nil {
+                a.callers[parent.Name] += f.Value
+            }
+            for _, c := range f.Children {
+                a.callees[c.Name] += c.Value
+            }
+        }
+        onPath[f.Name]++
+        for _, c := range f.Children {
+            walk(c, f)
+        }
+        onPath[f.Name]--
+    }
+    for _, c := range root.Children {
+        walk(c, nil)
+    }
+
+    hs := make([]Hotspot, 0, len(agg))
+    for _, a := range agg {
+        hs = append(hs, a.h)
+    }
+    sortHotspots(hs)
+    out := make([]PeekEntry, 0, len(hs))
+    for _, h := range hs {
+        a := agg[h.Name]
+        out = append(out, PeekEntry{Hotspot: h, Callers: sortedEdges(a.callers), Callees: sortedEdges(a.callees)})
+    }
+    return out
+}
+
+func sortedEdges(m map[string]int64) []Edge {
+    out := make([]Edge, 0, len(m))
+    for name, v := range m {
+        out = append(out, Edge{Name: name, Value: v})
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Value != out[j].Value {
+            return out[i].Value > out[j].Value
+        }
+        return out[i].Name < out[j].Name
+    })
+    return out
+}
+
+// Total returns the weight represented by root: its own value when set,
+// otherwise the sum of its children (builders leave the root at zero).
+func Total(root *Frame) int64 {
+    if root == nil {
+        return 0
+    }
+    if root.Value != 0 || len(root.Children) == 0 {
+        return root.Value
+    }
+    var sum int64
+    for _, c := range root.Children {
+        sum += c.Value
+    }
+    return sum
+}
+
+// FocusName merges the subtrees of all outermost frames called name into one
+// tree rooted at name, i.e. "everything that happens under name, wherever it
+// is called from".  It returns nil when name does not occur.
+func FocusName(root *Frame, name string) *Frame {
+    if root == nil {
+        return nil
+    }
+    var out *Frame
+    var walk func(*Frame)
+    walk = func(f *Frame) {
+        if f.Name == name {
+            if out == nil {
+                out = New(name)
+            }
+            out.Merge(f)
+            return // nested occurrences are already part of this subtree
+        }
+        for _, c := range f.Children {
+            walk(c)
+        }
+    }
+    for _, c := range root.Children {
+        walk(c)
+    }
+    return out
+}
@@ -0,0 +1,69 @@
+package flamegraph
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestHotspots(t *testing.T) {
+	b := NewBuilder("root")
+	b.Add(Sample{Stack: []string{"main", "walk", "walk", "leaf"}, Weight: 10})
+	b.Add(Sample{Stack: []string{"main", "walk"}, Weight: 5})
+	b.Add(Sample{Stack: []string{"main", "io"}, Weight: 3})
+	root := b.Build()
+
+	if got := Total(root); got != 18 {
+		t.Errorf("Expected total 18, got %d", got)
+	}
+	rows := map[string]Hotspot{}
+	for _, h := range Hotspots(root) {
+		rows[h.Name] = h
+	}
+	if h := rows["walk"]; h.Cum != 15 || h.Self != 5 {
+		t.Errorf("Expected recursive walk cum=15 self=5, got %+v", h)
+	}
+	if h := rows["leaf"]; h.Cum != 10 || h.Self != 10 {
+		t.Errorf("Expected leaf cum=10 self=10, got %+v", h)
+	}
+	if h := rows["main"]; h.Self != 0 || h.Cum != 18 {
+		t.Errorf("Expected main cum=18 self=0, got %+v", h)
+	}
+
+	f := FocusName(root, "walk")
+	if f == nil || f.Value != 15 || f.Children["walk"] == nil {
+		t.Fatalf("Unexpected focus tree %+v", f)
+	}
+	if FocusName(root, "missing") != nil {
+		t.Error("Expected nil focus for unknown frame")
+	}
+}
+
+func TestPeekAndFlatten(t *testing.T) {
+	root := New("root")
+	root.AddSample([]string{"main", "a", "work", "io"}, 4)
+	root.AddSample([]string{"main", "b", "work"}, 6)
+
+	peek := Peek(root, regexp.MustCompile(`^work$`))
+	if len(peek) != 1 {
+		t.Fatalf("Expected one peek entry, got %d", len(peek))
+	}
+	e := peek[0]
+	if e.Cum != 10 || e.Self != 6 {
+		t.Errorf("Expected work cum=10 self=6, got %+v", e.Hotspot)
+	}
+	if len(e.Callers) != 2 || e.Callers[0] != (Edge{"b", 6}) || e.Callers[1] != (Edge{"a", 4}) {
+		t.Errorf("Unexpected callers %+v", e.Callers)
+	}
+	if len(e.Callees) != 1 || e.Callees[0] != (Edge{"io", 4}) {
+		t.Errorf("Unexpected callees %+v", e.Callees)
+	}
+
+	for _, r := range root.Flatten() {
+		if r.Name == "main" && (r.Cumulative != 10 || r.Self != 0) {
+			t.Errorf("Expected main cum=10 self=0, got %+v", r)
+		}
+		if r.Depth == 0 && r.Cumulative != 10 {
+			t.Errorf("Expected zero root to report the total, got %+v", r)
+		}
+	}
+}
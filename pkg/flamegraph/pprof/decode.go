@@ -0,0 +1,255 @@
+// pkg/flamegraph/pprof/decode.go
+// profile.proto → Frame.  Parse resolves every sample to a symbolised stack
+// once and keeps all of its values; Tree then builds the flamegraph for one
+// sample index.  Locations with several lines (inlined calls) contribute one
+// frame per line, and unsymbolised locations are named by their address.
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+// Profile is a parsed profile.proto reduced to what a Frame tree needs.
+type Profile struct {
+    SampleTypes       []ValueType
+    DefaultSampleType string // may be empty
+    PeriodType        ValueType
+    Period            int64
+    TimeNanos         int64
+    DurationNanos     int64
+
+    samples []sample
+}
+
+type sample struct {
+    stack  []string // outermost first
+    values []int64  // one per SampleTypes entry
+}
+
+type location struct {
+    address uint64
+    funcs   []uint64 // leaf (innermost inlined) first
+}
+
+// Parse decodes a (possibly gzipped) profile.
+func Parse(r io.Reader) (*Profile, error) {
+    data, err := io.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+        zr, err := gzip.NewReader(bytes.NewReader(data))
+        if err != nil {
+            return nil, err
+        }
+        if data, err = io.ReadAll(zr); err != nil {
+            return nil, err
+        }
+    }
+
+    var (
+        p          Profile
+        strs       []string
+        types      [][2]int64 // string indexes, resolved once strs is complete
+        periodType [2]int64
+        defType    int64
+        rawSamples [][]byte
+        locs       = map[uint64]location{}
+        funcNames  = map[uint64]int64{}
+    )
+    err = fields(data, func(num protowire.Number, v []byte, x uint64) error {
+        switch num {
+        case profSampleType, profPeriodType:
+            var vt [2]int64
+            err := fields(v, func(num protowire.Number, _ []byte, x uint64) error {
+                switch num {
+                case valueType:
+                    vt[0] = int64(x)
+                case valueUnit:
+                    vt[1] = int64(x)
+                }
+                return nil
+            })
+            if num == profSampleType {
+                types = append(types, vt)
+            } else {
+                periodType = vt
+            }
+            return err
+        case profSample:
+            rawSamples = append(rawSamples, v)
+        case profStrings:
+            strs = append(strs, string(v))
+        case profLocation:
+            var id uint64
+            var loc location
+            err := fields(v, func(num protowire.Number, v []byte, x uint64) error {
+                switch num {
+                case locID:
+                    id = x
+                case locAddress:
+                    loc.address = x
+                case locLine:
+                    return fields(v, func(num protowire.Number, _ []byte, x uint64) error {
+                        if num == lineFunction {
+                            loc.funcs = append(loc.funcs, x)
+                        }
+                        return nil
+                    })
+                }
+                return nil
+            })
+            locs[id] = loc
+            return err
+        case profFunction:
+            var id uint64
+            var name int64
+            err := fields(v, func(num protowire.Number, _ []byte, x uint64) error {
+                switch num {
+                case fnID:
+                    id = x
+                case fnName:
+                    name = int64(x)
+                }
+                return nil
+            })
+            funcNames[id] = name
+            return err
+        case profTimeNanos:
+            p.TimeNanos = int64(x)
+        case profDurationNanos:
+            p.DurationNanos = int64(x)
+        case profPeriod:
+            p.Period = int64(x)
+        case profDefaultType:
+            defType = int64(x)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    if len(types) == 0 {
+        return nil, fmt.Errorf("profile has no sample types")
+    }
+
+    str := func(i int64) string {
+        if i >= 0 && int(i) < len(strs) {
+            return strs[i]
+        }
+        return ""
+    }
+    for _, t := range types {
+        p.SampleTypes = append(p.SampleTypes, ValueType{Type: str(t[0]), Unit: str(t[1])})
+    }
+    p.PeriodType = ValueType{Type: str(periodType[0]), Unit: str(periodType[1])}
+    p.DefaultSampleType = str(defType)
+
+    for _, raw := range rawSamples {
+        var ids []uint64
+        var s sample
+        err := fields(raw, func(num protowire.Number, v []byte, x uint64) error {
+            switch num {
+            case sampleLocation:
+                if v == nil {
+                    ids = append(ids, x)
+                    return nil
+                }
+                return packed(v, func(x uint64) { ids = append(ids, x) })
+            case sampleValue:
+                if v == nil {
+                    s.values = append(s.values, int64(x))
+                    return nil
+                }
+                return packed(v, func(x uint64) { s.values = append(s.values, int64(x)) })
+            }
+            return nil
+        })
+        if err != nil {
+            return nil, err
+        }
+        if len(s.values) != len(p.SampleTypes) {
+            return nil, fmt.Errorf("sample has %d values, want %d", len(s.values), len(p.SampleTypes))
+        }
+        // Locations are leaf first; walk them backwards for root → leaf.
+        for i := len(ids) - 1; i >= 0; i-- {
+            loc := locs[ids[i]]
+            if len(loc.funcs) == 0 {
+                s.stack = append(s.stack, fmt.Sprintf("0x%x", loc.address))
+                continue
+            }
+            for j := len(loc.funcs) - 1; j >= 0; j-- {
+                name := str(funcNames[loc.funcs[j]])
+                if name == "" {
+                    name = fmt.Sprintf("0x%x", loc.address)
+                }
+                s.stack = append(s.stack, name)
+            }
+        }
+        p.samples = append(p.samples, s)
+    }
+    return &p, nil
+}
+
+// SampleIndex resolves a -sample_index style selector: a position, a sample
+// type name such as "inuse_space", or "" for the profile's default (the
+// declared default type, else the last one).
+func (p *Profile) SampleIndex(sel string) (int, error) {
+    if sel == "" {
+        sel = p.DefaultSampleType
+        if sel == "" {
+            return len(p.SampleTypes) - 1, nil
+        }
+    }
+    if i, err := strconv.Atoi(sel); err == nil {
+        if i < 0 || i >= len(p.SampleTypes) {
+            return 0, fmt.Errorf("sample index %d out of range [0,%d)", i, len(p.SampleTypes))
+        }
+        return i, nil
+    }
+    for i, t := range p.SampleTypes {
+        if t.Type == sel {
+            return i, nil
+        }
+    }
+    return 0, fmt.Errorf("no sample type %q (have %v)", sel, p.SampleTypes)
+}
+
+// Tree builds the flamegraph for sample index i.
+func (p *Profile) Tree(i int) *flamegraph.Frame {
+    root := flamegraph.New("root")
+    for _, s := range p.samples {
+        if w := s.values[i]; w != 0 && len(s.stack) > 0 {
+            root.AddSample(s.stack, w)
+        }
+    }
+    return root
+}
+
+// Decode reads a profile and returns the tree of its default sample type,
+// the one `go tool pprof` shows without -sample_index.
+func Decode(r io.Reader) (*flamegraph.Frame, error) {
+    return DecodeIndex(r, "")
+}
+
+// DecodeIndex reads a profile and returns the tree for the sample type
+// selected by sel (see SampleIndex).
+func DecodeIndex(r io.Reader, sel string) (*flamegraph.Frame, error) {
+    p, err := Parse(r)
+    if err != nil {
+        return nil, err
+    }
+    i, err := p.SampleIndex(sel)
+    if err != nil {
+        return nil, err
+    }
+    return p.Tree(i), nil
+}
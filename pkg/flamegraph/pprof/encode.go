@@ -0,0 +1,175 @@
+// pkg/flamegraph/pprof/encode.go
+// Frame → profile.proto.  The profile is assembled field by field with
+// protowire: a string table (index 0 is always ""), one Function per distinct
+// frame name, one Location per Function (FlareGo frames have no addresses or
+// line numbers), and one Sample per stack with non‑zero self weight, its
+// location IDs leaf first as pprof expects.
+package pprof
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+// Options tune Encode.  Zero values select the defaults.
+type Options struct {
+    SampleType    ValueType // default {"samples", "count"}
+    Period        int64     // default 1, in SampleType units
+    TimeNanos     int64     // wall‑clock start of the recording
+    DurationNanos int64     // recording length
+}
+
+// ParseValueType parses "type/unit" (unit defaults to "count").
+func ParseValueType(s string) (ValueType, error) {
+    typ, unit, _ := strings.Cut(s, "/")
+    if typ == "" {
+        return ValueType{}, fmt.Errorf("sample type %q: want type/unit, e.g. cpu/nanoseconds", s)
+    }
+    if unit == "" {
+        unit = "count"
+    }
+    return ValueType{Type: typ, Unit: unit}, nil
+}
+
+// Encode writes root as a gzipped profile with the default Options.
+func Encode(w io.Writer, root *flamegraph.Frame) (flamegraph.Loss, error) {
+    return EncodeWith(w, root, Options{})
+}
+
+// EncodeWith writes root as a gzipped profile.  The returned Loss names the
+// runtime bands whose unit differs from the profile's sample type, since
+// pprof cannot mix units within one value.
+func EncodeWith(w io.Writer, root *flamegraph.Frame, opt Options) (flamegraph.Loss, error) {
+    if opt.SampleType.Type == "" {
+        opt.SampleType = ValueType{Type: "samples", Unit: "count"}
+    }
+    if opt.Period == 0 {
+        opt.Period = 1
+    }
+    e := &encoder{strIdx: map[string]int64{"": 0}, strs: []string{""}, funcIDs: map[string]uint64{}}
+
+    var out []byte
+    st := e.valueType(opt.SampleType)
+    out = protowire.AppendTag(out, profSampleType, protowire.BytesType)
+    out = protowire.AppendBytes(out, st)
+
+    flamegraph.WalkSelf(root, func(stack []string, self int64) {
+        var locs []byte
+        for i := len(stack) - 1; i >= 0; i-- { // leaf first
+            locs = protowire.AppendVarint(locs, e.function(stack[i]))
+        }
+        var smp []byte
+        smp = protowire.AppendTag(smp, sampleLocation, protowire.BytesType)
+        smp = protowire.AppendBytes(smp, locs)
+        smp = protowire.AppendTag(smp, sampleValue, protowire.BytesType)
+        smp = protowire.AppendBytes(smp, protowire.AppendVarint(nil, uint64(self)))
+        out = protowire.AppendTag(out, profSample, protowire.BytesType)
+        out = protowire.AppendBytes(out, smp)
+    })
+
+    // Location N refers to function N.
+    for id := uint64(1); id <= uint64(len(e.funcs)); id++ {
+        var line, loc []byte
+        line = protowire.AppendTag(line, lineFunction, protowire.VarintType)
+        line = protowire.AppendVarint(line, id)
+        loc = protowire.AppendTag(loc, locID, protowire.VarintType)
+        loc = protowire.AppendVarint(loc, id)
+        loc = protowire.AppendTag(loc, locLine, protowire.BytesType)
+        loc = protowire.AppendBytes(loc, line)
+        out = protowire.AppendTag(out, profLocation, protowire.BytesType)
+        out = protowire.AppendBytes(out, loc)
+    }
+    for _, fn := range e.funcs {
+        out = protowire.AppendTag(out, profFunction, protowire.BytesType)
+        out = protowire.AppendBytes(out, fn)
+    }
+
+    if opt.TimeNanos != 0 {
+        out = protowire.AppendTag(out, profTimeNanos, protowire.VarintType)
+        out = protowire.AppendVarint(out, uint64(opt.TimeNanos))
+    }
+    if opt.DurationNanos != 0 {
+        out = protowire.AppendTag(out, profDurationNanos, protowire.VarintType)
+        out = protowire.AppendVarint(out, uint64(opt.DurationNanos))
+    }
+    out = protowire.AppendTag(out, profPeriodType, protowire.BytesType)
+    out = protowire.AppendBytes(out, st)
+    out = protowire.AppendTag(out, profPeriod, protowire.VarintType)
+    out = protowire.AppendVarint(out, uint64(opt.Period))
+    out = protowire.AppendTag(out, profDefaultType, protowire.VarintType)
+    out = protowire.AppendVarint(out, uint64(e.str(opt.SampleType.Type)))
+
+    // The string table goes last: every index is known by now.
+    for _, s := range e.strs {
+        out = protowire.AppendTag(out, profStrings, protowire.BytesType)
+        out = protowire.AppendString(out, s)
+    }
+
+    zw := gzip.NewWriter(w)
+    if _, err := zw.Write(out); err != nil {
+        return nil, err
+    }
+    if err := zw.Close(); err != nil {
+        return nil, err
+    }
+
+    var loss flamegraph.Loss
+    if root != nil {
+        for _, b := range flamegraph.RuntimeBands {
+            if unit, _ := flamegraph.BandUnit(b); root.Children[b] != nil && unit != opt.SampleType.Unit {
+                loss = append(loss, fmt.Sprintf("%s band is measured in %s but exported as %s", b, unit, opt.SampleType.Unit))
+            }
+        }
+    }
+    return loss, nil
+}
+
+type encoder struct {
+    strs    []string
+    strIdx  map[string]int64
+    funcs   [][]byte // encoded Function messages, ID = index+1
+    funcIDs map[string]uint64
+}
+
+func (e *encoder) str(s string) int64 {
+    if i, ok := e.strIdx[s]; ok {
+        return i
+    }
+    e.strIdx[s] = int64(len(e.strs))
+    e.strs = append(e.strs, s)
+    return int64(len(e.strs) - 1)
+}
+
+func (e *encoder) valueType(v ValueType) []byte {
+    var b []byte
+    b = protowire.AppendTag(b, valueType, protowire.VarintType)
+    b = protowire.AppendVarint(b, uint64(e.str(v.Type)))
+    b = protowire.AppendTag(b, valueUnit, protowire.VarintType)
+    b = protowire.AppendVarint(b, uint64(e.str(v.Unit)))
+    return b
+}
+
+// function returns the ID of the Function (and Location) for name.
+func (e *encoder) function(name string) uint64 {
+    if id, ok := e.funcIDs[name]; ok {
+        return id
+    }
+    id := uint64(len(e.funcs) + 1)
+    e.funcIDs[name] = id
+    idx := uint64(e.str(name))
+    var fn []byte
+    fn = protowire.AppendTag(fn, fnID, protowire.VarintType)
+    fn = protowire.AppendVarint(fn, id)
+    fn = protowire.AppendTag(fn, fnName, protowire.VarintType)
+    fn = protowire.AppendVarint(fn, idx)
+    fn = protowire.AppendTag(fn, fnSystemName, protowire.VarintType)
+    fn = protowire.AppendVarint(fn, idx)
+    e.funcs = append(e.funcs, fn)
+    return id
+}
@@ -0,0 +1,178 @@
+// pkg/flamegraph/pprof/pprof.go
+// Package pprof converts between Frame trees and Go's pprof profile.proto
+// (github.com/google/pprof/proto/profile.proto).  The wire format is written
+// and parsed with protowire, which is already part of our protobuf
+// dependency, so no pprof library is needed.
+//
+// A Frame carries a single value per node, so Encode writes one sample type
+// (Options.SampleType, "samples/count" by default) with one sample per stack
+// of non‑zero self weight, and Parse exposes every sample type of a profile so
+// callers can pick the one to turn into a tree, like `pprof -sample_index`.
+//
+// Importing the package registers the "pprof" codec with pkg/flamegraph:
+//
+//	import _ "github.com/Voskan/flarego/pkg/flamegraph/pprof"
+package pprof
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+func init() {
+    flamegraph.RegisterCodec(flamegraph.Codec{
+        Format:     flamegraph.FormatPprof,
+        Extensions: []string{".pprof", ".pb.gz", ".pb"},
+        Gzipped:    true,
+        Detect:     Detect,
+        Read:       Decode,
+        Write:      Encode,
+    })
+}
+
+// ValueType names a sample value, e.g. {"alloc_space", "bytes"}.
+type ValueType struct {
+    Type string `json:"type"`
+    Unit string `json:"unit"`
+}
+
+func (v ValueType) String() string { return v.Type + "/" + v.Unit }
+
+// profile.proto field numbers.
+const (
+    profSampleType    = 1
+    profSample        = 2
+    profLocation      = 4
+    profFunction      = 5
+    profStrings       = 6
+    profTimeNanos     = 9
+    profDurationNanos = 10
+    profPeriodType    = 11
+    profPeriod        = 12
+    profDefaultType   = 14
+
+    valueType = 1
+    valueUnit = 2
+
+    sampleLocation = 1
+    sampleValue    = 2
+
+    locID      = 1
+    locAddress = 3
+    locLine    = 4
+
+    lineFunction = 1
+
+    fnID         = 1
+    fnName       = 2
+    fnSystemName = 3
+)
+
+// profile.proto top‑level fields and the wire type each is written with.
+// comment (13) is a repeated int64 and may be packed or not.
+var profWireTypes = map[protowire.Number][]protowire.Type{
+    profSampleType:    {protowire.BytesType},
+    profSample:        {protowire.BytesType},
+    3:                 {protowire.BytesType}, // mapping
+    profLocation:      {protowire.BytesType},
+    profFunction:      {protowire.BytesType},
+    profStrings:       {protowire.BytesType},
+    7:                 {protowire.VarintType}, // drop_frames
+    8:                 {protowire.VarintType}, // keep_frames
+    profTimeNanos:     {protowire.VarintType},
+    profDurationNanos: {protowire.VarintType},
+    profPeriodType:    {protowire.BytesType},
+    profPeriod:        {protowire.VarintType},
+    13:                {protowire.VarintType, protowire.BytesType}, // comment
+    profDefaultType:   {protowire.VarintType},
+    15:                {protowire.VarintType}, // doc_url
+}
+
+// detectFields is how many top‑level fields Detect checks.
+const detectFields = 8
+
+// Detect reports whether head looks like an uncompressed profile.proto: its
+// first top‑level fields (up to detectFields, or as many as head holds) all
+// carry a profile.proto field number with the matching wire type.  Writers
+// order the fields differently, so no particular field has to come first.
+func Detect(head []byte) bool {
+    seen := 0
+    for len(head) > 0 && seen < detectFields {
+        num, typ, n := protowire.ConsumeTag(head)
+        if n < 0 || !knownField(num, typ) {
+            return false
+        }
+        m := protowire.ConsumeFieldValue(num, typ, head[n:])
+        if m < 0 {
+            // The value runs past head; what came before decides.
+            return seen > 0
+        }
+        head = head[n+m:]
+        seen++
+    }
+    return seen > 0
+}
+
+func knownField(num protowire.Number, typ protowire.Type) bool {
+    for _, t := range profWireTypes[num] {
+        if t == typ {
+            return true
+        }
+    }
+    return false
+}
+
+// fields iterates the top‑level fields of a message.  Length‑delimited
+// values are passed as v; varints as x with v nil.
+func fields(b []byte, fn func(num protowire.Number, v []byte, x uint64) error) error {
+    for len(b) > 0 {
+        num, typ, n := protowire.ConsumeTag(b)
+        if n < 0 {
+            return protowire.ParseError(n)
+        }
+        b = b[n:]
+        switch typ {
+        case protowire.VarintType:
+            x, m := protowire.ConsumeVarint(b)
+            if m < 0 {
+                return protowire.ParseError(m)
+            }
+            if err := fn(num, nil, x); err != nil {
+                return err
+            }
+            b = b[m:]
+        case protowire.BytesType:
+            v, m := protowire.ConsumeBytes(b)
+            if m < 0 {
+                return protowire.ParseError(m)
+            }
+            if v == nil {
+                v = []byte{}
+            }
+            if err := fn(num, v, 0); err != nil {
+                return err
+            }
+            b = b[m:]
+        default:
+            m := protowire.ConsumeFieldValue(num, typ, b)
+            if m < 0 {
+                return protowire.ParseError(m)
+            }
+            b = b[m:]
+        }
+    }
+    return nil
+}
+
+func packed(b []byte, fn func(uint64)) error {
+    for len(b) > 0 {
+        x, n := protowire.ConsumeVarint(b)
+        if n < 0 {
+            return protowire.ParseError(n)
+        }
+        fn(x)
+        b = b[n:]
+    }
+    return nil
+}
@@ -0,0 +1,133 @@
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	b := flamegraph.NewBuilder("root")
+	b.Add(flamegraph.Sample{Stack: []string{"main.main", "main.parse"}, Weight: 20})
+	b.Add(flamegraph.Sample{Stack: []string{"main.main", "main.serve"}, Weight: 80})
+	b.Add(flamegraph.Sample{Stack: []string{flamegraph.BandHeap}, Weight: 4096})
+	want := b.Build()
+
+	var buf bytes.Buffer
+	loss, err := EncodeWith(&buf, want, Options{SampleType: ValueType{Type: "cpu", Unit: "nanoseconds"}, DurationNanos: 1e9})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if len(loss) != 1 || !strings.Contains(loss[0], "(Heap)") {
+		t.Errorf("Expected the heap band to be reported, got %v", loss)
+	}
+
+	p, err := Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(p.SampleTypes) != 1 || p.SampleTypes[0] != (ValueType{"cpu", "nanoseconds"}) || p.DurationNanos != 1e9 {
+		t.Errorf("Expected cpu/nanoseconds over 1s, got %v %d", p.SampleTypes, p.DurationNanos)
+	}
+	if d := flamegraph.Diff(p.Tree(0), want); d != nil {
+		t.Errorf("Expected identical tree after round trip, diff %+v", d)
+	}
+}
+
+func TestDecodeRuntimeHeapProfile(t *testing.T) {
+	old := runtime.MemProfileRate
+	runtime.MemProfileRate = 1
+	defer func() { runtime.MemProfileRate = old }()
+
+	keep := make([][]byte, 0, 64)
+	for i := 0; i < 64; i++ {
+		keep = append(keep, make([]byte, 1024))
+	}
+	runtime.GC()
+
+	var buf bytes.Buffer
+	if err := rpprof.Lookup("heap").WriteTo(&buf, 0); err != nil {
+		t.Fatalf("write heap profile: %v", err)
+	}
+	p, err := Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	i, err := p.SampleIndex("alloc_space")
+	if err != nil {
+		t.Fatalf("sample index: %v", err)
+	}
+	if p.SampleTypes[i].Unit != "bytes" {
+		t.Errorf("Expected alloc_space in bytes, got %v", p.SampleTypes[i])
+	}
+	tree := p.Tree(i)
+	if h := flamegraph.FocusName(tree, "testing.tRunner"); h == nil || h.Value < 64*1024 {
+		t.Errorf("Expected at least 64 KiB allocated under testing.tRunner, got %+v", h)
+	}
+	if _, err := p.SampleIndex("nope"); err == nil {
+		t.Errorf("Expected error for unknown sample type")
+	}
+	runtime.KeepAlive(keep)
+}
+
+func TestDetectRuntimeProfile(t *testing.T) {
+	var buf bytes.Buffer
+	if err := rpprof.Lookup("goroutine").WriteTo(&buf, 0); err != nil {
+		t.Fatalf("write goroutine profile: %v", err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gunzip: %v", err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("gunzip: %v", err)
+	}
+	if !Detect(raw) {
+		t.Errorf("Expected runtime/pprof output to be detected")
+	}
+
+	// Another writer may put the string table first and sample_type last.
+	var strs, rest, types []byte
+	err = fields(raw, func(num protowire.Number, v []byte, x uint64) error {
+		var f []byte
+		if v != nil {
+			f = protowire.AppendBytes(protowire.AppendTag(nil, num, protowire.BytesType), v)
+		} else {
+			f = protowire.AppendVarint(protowire.AppendTag(nil, num, protowire.VarintType), x)
+		}
+		switch num {
+		case profStrings:
+			strs = append(strs, f...)
+		case profSampleType:
+			types = append(types, f...)
+		default:
+			rest = append(rest, f...)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("split fields: %v", err)
+	}
+	reordered := append(append(strs, rest...), types...)
+	if !Detect(reordered) {
+		t.Errorf("Expected a profile starting with its string table to be detected")
+	}
+	if root, format, err := flamegraph.Read(bytes.NewReader(reordered)); err != nil || format != flamegraph.FormatPprof || root == nil {
+		t.Errorf("Expected reordered profile to read as pprof, got %v %v", format, err)
+	}
+
+	for _, other := range []string{`{"name":"root"}`, "main;work 10\n", "\nmain;work 10\n", ""} {
+		if Detect([]byte(other)) {
+			t.Errorf("Expected %q not to be detected as pprof", other)
+		}
+	}
+}
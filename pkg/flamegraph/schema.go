@@ -0,0 +1,322 @@
+// pkg/flamegraph/schema.go
+// Versioned JSON schema for exchanging trees with tools.  Version 1 is the
+// plain Frame struct: children are an object keyed by name, which
+// encoding/json writes sorted by key, so every consumer has to re‑sort by
+// weight.  Version 2 wraps the tree in a document and stores children as an
+// array, heaviest first (ties by name):
+//
+//	{"version":2,"unit":"nanoseconds","meta":{"host":"api-1"},
+//	 "root":{"name":"root","value":20,"self":0,"children":[
+//	   {"name":"main","value":20,"children":[…]}]}}
+//
+// unit, meta and the per‑node self are optional.  EncodeJSON streams the
+// document straight from a live tree, sorting one level of children at a
+// time instead of copying the tree first.  Frame.UnmarshalJSON reads both
+// versions (and bare v2 nodes), so every decoder of Frame JSON accepts
+// either; the agent → gateway → UI wire format stays version 1 (ToJSON).
+package flamegraph
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONVersion is the version EncodeJSON writes.
+const JSONVersion = 2
+
+// JSONOptions select the optional fields of a v2 document.
+type JSONOptions struct {
+    Unit   string            // unit of the values, e.g. UnitNanoseconds
+    Meta   map[string]string // free‑form document metadata
+    Self   bool              // write each node's self value
+    Indent string            // indent per level; empty writes compact JSON
+}
+
+// JSONDocument is a decoded v2 document.  Decoding v1 input yields Version 1
+// and no unit or metadata.
+type JSONDocument struct {
+    Version int               `json:"version"`
+    Unit    string            `json:"unit,omitempty"`
+    Meta    map[string]string `json:"meta,omitempty"`
+    Root    *Frame            `json:"root"`
+}
+
+// EncodeJSON writes root as a v2 document.
+func EncodeJSON(w io.Writer, root *Frame, opt JSONOptions) error {
+    if root == nil {
+        return fmt.Errorf("flamegraph: nil tree")
+    }
+    e := &jsonEncoder{w: bufio.NewWriter(w), opt: opt}
+    e.str(`{`)
+    e.newline(1)
+    e.str(`"version":` + strconv.Itoa(JSONVersion))
+    if opt.Unit != "" {
+        e.str(`,`)
+        e.newline(1)
+        e.str(`"unit":`)
+        e.quote(opt.Unit)
+    }
+    if len(opt.Meta) > 0 {
+        meta, err := json.Marshal(opt.Meta) // keys sorted
+        if err != nil {
+            return err
+        }
+        e.str(`,`)
+        e.newline(1)
+        e.str(`"meta":` + string(meta))
+    }
+    e.str(`,`)
+    e.newline(1)
+    e.str(`"root":`)
+    e.node(root, 1, true)
+    e.newline(0)
+    e.str("}\n")
+    if e.err != nil {
+        return e.err
+    }
+    return e.w.Flush()
+}
+
+// DecodeJSON reads a v1 or v2 tree.
+func DecodeJSON(r io.Reader) (*JSONDocument, error) {
+    doc := &JSONDocument{Version: 1}
+    root, err := decodeNode(json.NewDecoder(r), doc)
+    if err != nil {
+        return nil, err
+    }
+    if root == nil {
+        root = &Frame{}
+    }
+    doc.Root = root
+    return doc, nil
+}
+
+// UnmarshalJSON reads a v1 node, a v2 node or a whole v2 document (whose
+// root it takes); children may be an object keyed by name or an array.
+func (f *Frame) UnmarshalJSON(b []byte) error {
+    n, err := decodeNode(json.NewDecoder(bytes.NewReader(b)), nil)
+    if err != nil || n == nil {
+        return err
+    }
+    f.Name, f.Value, f.Children = n.Name, n.Value, n.Children
+    return nil
+}
+
+// decodeNode reads one node, or a v2 document whose root it returns, from
+// dec in a single pass over the tokens; nested nodes are decoded by the same
+// call instead of being buffered and re‑parsed level by level.  A JSON null
+// yields nil.  When doc is non‑nil the document fields are stored in it.
+func decodeNode(dec *json.Decoder, doc *JSONDocument) (*Frame, error) {
+    tok, err := dec.Token()
+    if err != nil || tok == nil {
+        return nil, err
+    }
+    if d, ok := tok.(json.Delim); !ok || d != '{' {
+        return nil, fmt.Errorf("flamegraph: expected a frame object, got %v", tok)
+    }
+    f := &Frame{Children: make(map[string]*Frame)}
+    var root *Frame
+    var version int
+    var unit string
+    var meta map[string]string
+    for dec.More() {
+        tok, err := dec.Token()
+        if err != nil {
+            return nil, err
+        }
+        switch tok.(string) {
+        case "name":
+            err = dec.Decode(&f.Name)
+        case "value":
+            err = dec.Decode(&f.Value)
+        case "children":
+            err = decodeChildren(dec, f)
+        case "root":
+            root, err = decodeNode(dec, nil)
+        case "version":
+            err = dec.Decode(&version)
+        case "unit":
+            err = dec.Decode(&unit)
+        case "meta":
+            err = dec.Decode(&meta)
+        default:
+            err = skipValue(dec)
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+    if _, err := dec.Token(); err != nil {
+        return nil, err
+    }
+    if root == nil {
+        return f, nil
+    }
+    if version > JSONVersion {
+        return nil, fmt.Errorf("flamegraph: JSON version %d is newer than supported (%d)", version, JSONVersion)
+    }
+    if doc != nil {
+        doc.Version, doc.Unit, doc.Meta = version, unit, meta
+    }
+    return root, nil
+}
+
+// decodeChildren reads a children object keyed by name or array into f.
+// Array entries of the same name are merged.
+func decodeChildren(dec *json.Decoder, f *Frame) error {
+    tok, err := dec.Token()
+    if err != nil || tok == nil {
+        return err
+    }
+    switch tok {
+    case json.Delim('['):
+        for dec.More() {
+            c, err := decodeNode(dec, nil)
+            if err != nil {
+                return err
+            }
+            if c == nil {
+                continue
+            }
+            if dup, ok := f.Children[c.Name]; ok {
+                dup.Merge(c)
+                continue
+            }
+            f.Children[c.Name] = c
+        }
+    case json.Delim('{'):
+        for dec.More() {
+            key, err := dec.Token()
+            if err != nil {
+                return err
+            }
+            c, err := decodeNode(dec, nil)
+            if err != nil {
+                return err
+            }
+            if c != nil {
+                f.Children[key.(string)] = c
+            }
+        }
+    default:
+        return fmt.Errorf("flamegraph: expected children object or array, got %v", tok)
+    }
+    _, err = dec.Token()
+    return err
+}
+
+// skipValue consumes the next value of dec whatever its type.
+func skipValue(dec *json.Decoder) error {
+    depth := 0
+    for {
+        tok, err := dec.Token()
+        if err != nil {
+            return err
+        }
+        switch tok {
+        case json.Delim('{'), json.Delim('['):
+            depth++
+        case json.Delim('}'), json.Delim(']'):
+            depth--
+        }
+        if depth == 0 {
+            return nil
+        }
+    }
+}
+
+//--------------------------------------------------------------------
+// encoder
+//--------------------------------------------------------------------
+
+// encodeNode returns f as a compact v2 node without the document around it.
+func encodeNode(f *Frame) ([]byte, error) {
+    if f == nil {
+        return []byte("null"), nil
+    }
+    var buf bytes.Buffer
+    e := &jsonEncoder{w: bufio.NewWriter(&buf)}
+    e.node(f, 0, false)
+    if e.err == nil {
+        e.err = e.w.Flush()
+    }
+    return buf.Bytes(), e.err
+}
+
+type jsonEncoder struct {
+    w   *bufio.Writer
+    opt JSONOptions
+    err error
+}
+
+func (e *jsonEncoder) str(s string) {
+    if e.err == nil {
+        _, e.err = e.w.WriteString(s)
+    }
+}
+
+func (e *jsonEncoder) quote(s string) {
+    b, err := json.Marshal(s)
+    if err != nil && e.err == nil {
+        e.err = err
+    }
+    if e.err == nil {
+        _, e.err = e.w.Write(b)
+    }
+}
+
+func (e *jsonEncoder) newline(depth int) {
+    if e.opt.Indent != "" {
+        e.str("\n" + strings.Repeat(e.opt.Indent, depth))
+    }
+}
+
+// node writes f with its children heaviest first.  Only the child slice of
+// the levels on the current path is held in memory.  A zero root (as
+// builders leave it) has no self weight.
+func (e *jsonEncoder) node(f *Frame, depth int, root bool) {
+    e.str(`{"name":`)
+    e.quote(f.Name)
+    e.str(`,"value":` + strconv.FormatInt(f.Value, 10))
+    if e.opt.Self {
+        self := SelfValue(f)
+        if root && f.Value == 0 {
+            self = 0
+        }
+        e.str(`,"self":` + strconv.FormatInt(self, 10))
+    }
+    if len(f.Children) > 0 {
+        e.str(`,"children":[`)
+        for i, c := range ChildrenByWeight(f) {
+            if i > 0 {
+                e.str(`,`)
+            }
+            e.newline(depth + 1)
+            e.node(c, depth+1, false)
+        }
+        e.newline(depth)
+        e.str(`]`)
+    }
+    e.str(`}`)
+}
+
+// ChildrenByWeight returns f's children by descending value, ties by name.
+func ChildrenByWeight(f *Frame) []*Frame {
+    out := make([]*Frame, 0, len(f.Children))
+    for _, c := range f.Children {
+        out = append(out, c)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Value != out[j].Value {
+            return out[i].Value > out[j].Value
+        }
+        return out[i].Name < out[j].Name
+    })
+    return out
+}
@@ -0,0 +1,108 @@
+package flamegraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEncodeJSONOrdered(t *testing.T) {
+	b := NewBuilder("root")
+	b.Add(Sample{Stack: []string{"main", "a"}, Weight: 1})
+	b.Add(Sample{Stack: []string{"main", "z"}, Weight: 5})
+	b.Add(Sample{Stack: []string{"main", "m"}, Weight: 3})
+	root := b.Build()
+
+	var buf bytes.Buffer
+	if err := EncodeJSON(&buf, root, JSONOptions{Unit: UnitNanoseconds, Meta: map[string]string{"host": "api-1"}, Self: true}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, `{"version":2,"unit":"nanoseconds","meta":{"host":"api-1"},"root":`) {
+		t.Errorf("Unexpected document header: %s", out)
+	}
+	if z, m, a := strings.Index(out, `"z"`), strings.Index(out, `"m"`), strings.Index(out, `"a"`); !(z < m && m < a) {
+		t.Errorf("Expected children heaviest first, got %s", out)
+	}
+	if !strings.Contains(out, `{"name":"main","value":9,"self":0,"children":[`) {
+		t.Errorf("Expected self values and children array, got %s", out)
+	}
+
+	doc, err := DecodeJSON(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected decode error: %v", err)
+	}
+	if doc.Version != 2 || doc.Unit != UnitNanoseconds || doc.Meta["host"] != "api-1" {
+		t.Errorf("Unexpected document %+v", doc)
+	}
+	if c := doc.Root.Children["main"].Children["z"]; c == nil || c.Value != 5 {
+		t.Errorf("Expected z below main after round trip, got %+v", c)
+	}
+
+	var indented bytes.Buffer
+	if err := EncodeJSON(&indented, root, JSONOptions{Indent: "  "}); err != nil || !json.Valid(indented.Bytes()) {
+		t.Errorf("Expected valid indented JSON, got %v: %s", err, indented.String())
+	}
+}
+
+func TestDecodeJSONCompat(t *testing.T) {
+	inputs := map[string]string{
+		"v1":      `{"name":"root","value":0,"children":{"main":{"name":"main","value":4,"children":{"f":{"name":"f","value":4}}}}}`,
+		"v2 node": `{"name":"root","value":0,"children":[{"name":"main","value":4,"children":[{"name":"f","value":4}]}]}`,
+		"v2 doc":  `{"version":2,"root":{"name":"root","value":0,"children":[{"name":"main","value":4,"children":[{"name":"f","value":4}]}]}}`,
+	}
+	for label, in := range inputs {
+		var f Frame
+		if err := json.Unmarshal([]byte(in), &f); err != nil {
+			t.Errorf("%s: unexpected error: %v", label, err)
+			continue
+		}
+		if c := f.Children["main"]; c == nil || c.Children["f"] == nil || c.Children["f"].Value != 4 {
+			t.Errorf("%s: unexpected tree %+v", label, &f)
+		}
+		if f.Children["main"].Children["f"].Children == nil {
+			t.Errorf("%s: expected leaves to be mergeable", label)
+		}
+	}
+	if _, err := DecodeJSON(strings.NewReader(`{"version":9,"root":{}}`)); err == nil {
+		t.Error("Expected error for a future version")
+	}
+}
+
+func TestDecodeJSONDeepTree(t *testing.T) {
+	const depth = 3000
+	root := New("root")
+	stack := make([]string, depth)
+	for i := range stack {
+		stack[i] = "f" + strconv.Itoa(i)
+	}
+	root.AddSample(stack, 1)
+
+	var v2 bytes.Buffer
+	if err := EncodeJSON(&v2, root, JSONOptions{Self: true}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	v1, err := json.Marshal(root)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for label, in := range map[string][]byte{"v1": v1, "v2": v2.Bytes()} {
+		doc, err := DecodeJSON(bytes.NewReader(in))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", label, err)
+			continue
+		}
+		n, f := 0, doc.Root
+		for len(f.Children) == 1 {
+			for _, c := range f.Children {
+				f = c
+			}
+			n++
+		}
+		if n != depth || f.Name != stack[depth-1] || f.Value != 1 {
+			t.Errorf("%s: expected a chain of %d frames ending in %s, got %d ending in %+v", label, depth, stack[depth-1], n, f)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// pkg/flamegraph/speedscope.go
+// speedscope's file format (https://www.speedscope.app/file-format-schema.json).
+// A file holds a shared frame table and one or more profiles, either
+// "sampled" (stacks of frame indexes with weights) or "evented" (open/close
+// events on a timeline).  Reading merges all profiles into one tree – under a
+// frame per profile when there are several – and converts time units to
+// nanoseconds.  Writing produces a single sampled profile named after the
+// root.
+package flamegraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const speedscopeSchema = "https://www.speedscope.app/file-format-schema.json"
+
+type speedscopeFile struct {
+    Schema   string `json:"$schema"`
+    Shared   struct {
+        Frames []speedscopeFrame `json:"frames"`
+    } `json:"shared"`
+    Profiles           []speedscopeProfile `json:"profiles"`
+    Name               string              `json:"name,omitempty"`
+    ActiveProfileIndex int                 `json:"activeProfileIndex"`
+    Exporter           string              `json:"exporter,omitempty"`
+}
+
+type speedscopeFrame struct {
+    Name string `json:"name"`
+    File string `json:"file,omitempty"`
+    Line int    `json:"line,omitempty"`
+}
+
+type speedscopeProfile struct {
+    Type       string            `json:"type"`
+    Name       string            `json:"name"`
+    Unit       string            `json:"unit"`
+    StartValue float64           `json:"startValue"`
+    EndValue   float64           `json:"endValue"`
+    Samples    [][]int           `json:"samples,omitempty"`
+    Weights    []float64         `json:"weights,omitempty"`
+    Events     []speedscopeEvent `json:"events,omitempty"`
+}
+
+type speedscopeEvent struct {
+    Type  string  `json:"type"` // "O" open, "C" close
+    Frame int     `json:"frame"`
+    At    float64 `json:"at"`
+}
+
+// speedscopeScale converts a profile unit to the Frame convention
+// (nanoseconds for time, bytes or counts otherwise).
+var speedscopeScale = map[string]float64{
+    "nanoseconds":  1,
+    "microseconds": 1e3,
+    "milliseconds": 1e6,
+    "seconds":      1e9,
+}
+
+func detectSpeedscope(head []byte) bool {
+    return bytes.Contains(head, []byte("speedscope.app/file-format-schema"))
+}
+
+// ReadSpeedscope decodes a speedscope file.
+func ReadSpeedscope(r io.Reader) (*Frame, error) {
+    var f speedscopeFile
+    if err := json.NewDecoder(r).Decode(&f); err != nil {
+        return nil, err
+    }
+    rootName := f.Name
+    if len(f.Profiles) == 1 && f.Profiles[0].Name != "" {
+        rootName = f.Profiles[0].Name
+    }
+    if rootName == "" {
+        rootName = "root"
+    }
+    root := New(rootName)
+    frameName := func(i int) (string, error) {
+        if i < 0 || i >= len(f.Shared.Frames) {
+            return "", fmt.Errorf("frame index %d out of range", i)
+        }
+        return f.Shared.Frames[i].Name, nil
+    }
+    for pi, p := range f.Profiles {
+        var prefix []string
+        if len(f.Profiles) > 1 {
+            name := p.Name
+            if name == "" {
+                name = fmt.Sprintf("profile %d", pi)
+            }
+            prefix = []string{name}
+        }
+        scale := speedscopeScale[p.Unit]
+        if scale == 0 {
+            scale = 1
+        }
+        add := func(idx []int, w float64) error {
+            stack := append([]string(nil), prefix...)
+            for _, i := range idx {
+                name, err := frameName(i)
+                if err != nil {
+                    return err
+                }
+                stack = append(stack, name)
+            }
+            if v := int64(w*scale + 0.5); v != 0 && len(stack) > 0 {
+                root.AddSample(stack, v)
+            }
+            return nil
+        }
+        switch p.Type {
+        case "sampled":
+            if len(p.Weights) != 0 && len(p.Weights) != len(p.Samples) {
+                return nil, fmt.Errorf("profile %q: %d samples but %d weights", p.Name, len(p.Samples), len(p.Weights))
+            }
+            for i, s := range p.Samples {
+                w := 1.0
+                if len(p.Weights) > 0 {
+                    w = p.Weights[i]
+                }
+                if err := add(s, w); err != nil {
+                    return nil, err
+                }
+            }
+        case "evented":
+            var open []int
+            last := p.StartValue
+            for _, ev := range p.Events {
+                if len(open) > 0 && ev.At > last {
+                    if err := add(open, ev.At-last); err != nil {
+                        return nil, err
+                    }
+                }
+                last = ev.At
+                switch ev.Type {
+                case "O":
+                    open = append(open, ev.Frame)
+                case "C":
+                    if len(open) > 0 {
+                        open = open[:len(open)-1]
+                    }
+                }
+            }
+        default:
+            return nil, fmt.Errorf("profile %q: unsupported type %q", p.Name, p.Type)
+        }
+    }
+    return root, nil
+}
+
+// WriteSpeedscope encodes root as one sampled profile with unit "none";
+// stacks with negative self weight are dropped.
+func WriteSpeedscope(w io.Writer, root *Frame) (Loss, error) {
+    out := speedscopeFile{Schema: speedscopeSchema, Exporter: "flarego"}
+    prof := speedscopeProfile{Type: "sampled", Name: root.Name, Unit: "none"}
+    index := map[string]int{}
+    negative := 0
+    WalkSelf(root, func(stack []string, self int64) {
+        if self < 0 {
+            negative++
+            return
+        }
+        ids := make([]int, len(stack))
+        for i, name := range stack {
+            id, ok := index[name]
+            if !ok {
+                id = len(out.Shared.Frames)
+                index[name] = id
+                out.Shared.Frames = append(out.Shared.Frames, speedscopeFrame{Name: name})
+            }
+            ids[i] = id
+        }
+        prof.Samples = append(prof.Samples, ids)
+        prof.Weights = append(prof.Weights, float64(self))
+        prof.EndValue += float64(self)
+    })
+    out.Name = root.Name
+    out.Profiles = []speedscopeProfile{prof}
+    if out.Shared.Frames == nil {
+        out.Shared.Frames = []speedscopeFrame{}
+    }
+    var loss Loss
+    if negative > 0 {
+        loss = append(loss, fmt.Sprintf("%d stack(s) with negative weight dropped", negative))
+    }
+    return loss, json.NewEncoder(w).Encode(out)
+}
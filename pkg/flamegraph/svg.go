@@ -0,0 +1,275 @@
+// pkg/flamegraph/svg.go
+// Static SVG rendering of a Frame tree, for sharing a flamegraph where the
+// web UI is not available (tickets, chat, CI artefacts).  The layout follows
+// flamegraph.pl: each frame is a box whose width is proportional to its
+// inclusive value, children sorted by name left to right, the root ("all")
+// at the bottom for a flame graph or at the top for an icicle graph.
+//
+// Boxes are coloured with a Colourer (DefaultColourer unless set).  With
+// SVGOptions.Base the tree is drawn as a differential flame graph instead:
+// each frame is tinted red when its share of the total grew relative to Base
+// and blue when it shrank, the saturation scaled by the largest change.
+// Frames matching SVGOptions.Search are highlighted and their combined share
+// is printed under the title.  Every box carries a <title> tooltip.
+package flamegraph
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// SVGOptions control RenderSVG.  Zero values select the defaults.
+type SVGOptions struct {
+    Width       int      // image width in px (default 1200)
+    MinWidth    float64  // frames narrower than this many px are omitted (default 0.1)
+    FrameHeight int      // px per stack level (default 16)
+    FontSize    float64  // px (default 11)
+    Title       string   // default "Flame Graph" / "Icicle Graph"
+    Icicle      bool     // root at the top instead of the bottom
+    Search      string   // regexp; matching frames are highlighted
+    Colourer    Colourer // default DefaultColourer
+    Base        *Frame   // when set, colour by normalised change against Base
+}
+
+const (
+    svgSearchColour = "#e040fb"
+    svgPadX         = 10
+    svgHeaderHeight = 44
+    svgFooterHeight = 12
+)
+
+// RenderSVG writes root as a standalone SVG document.  The runtime bands are
+// not in the unit of the stacks, so they are left out of the layout (and of
+// the comparison with Base) and listed with their own units under the title.
+func RenderSVG(w io.Writer, root *Frame, opt SVGOptions) error {
+    if root == nil {
+        root = New("root")
+    }
+    var bands []string
+    for _, b := range RuntimeBands {
+        if c := root.Children[b]; c != nil {
+            unit, _ := BandUnit(b)
+            bands = append(bands, b+" "+FormatValue(c.Value, unit))
+        }
+    }
+    root, opt.Base = WithoutBands(root), WithoutBands(opt.Base)
+    if opt.Width <= 0 {
+        opt.Width = 1200
+    }
+    if opt.MinWidth <= 0 {
+        opt.MinWidth = 0.1
+    }
+    if opt.FrameHeight <= 0 {
+        opt.FrameHeight = 16
+    }
+    if opt.FontSize <= 0 {
+        opt.FontSize = 11
+    }
+    if opt.Colourer == nil {
+        opt.Colourer = DefaultColourer
+    }
+    if opt.Title == "" {
+        opt.Title = "Flame Graph"
+        if opt.Icicle {
+            opt.Title = "Icicle Graph"
+        }
+        if opt.Base != nil {
+            opt.Title = "Differential " + opt.Title
+        }
+    }
+    var search *regexp.Regexp
+    if opt.Search != "" {
+        re, err := regexp.Compile(opt.Search)
+        if err != nil {
+            return fmt.Errorf("search: %w", err)
+        }
+        search = re
+    }
+
+    total := layoutValue(Total(root))
+    r := &svgRenderer{opt: opt, search: search, total: total}
+    r.scale = float64(opt.Width-2*svgPadX) / math.Max(float64(total), 1)
+    if opt.Base != nil {
+        r.baseTotal = math.Max(float64(Total(opt.Base)), 1)
+        r.maxDelta = r.maxChange(root, opt.Base)
+    }
+
+    // First pass: collect boxes so the image height is known.
+    r.visit(root, opt.Base, 0, svgPadX, true)
+
+    height := svgHeaderHeight + (r.depth+1)*opt.FrameHeight + svgFooterHeight
+    bw := bufio.NewWriter(w)
+    fmt.Fprintf(bw, `<?xml version="1.0" standalone="no"?>
+<svg version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">
+<style>text{font-family:Verdana,sans-serif;font-size:%.1fpx;fill:#000}.t{font-size:%.1fpx;text-anchor:middle}.s{text-anchor:middle;fill:#555}rect{stroke:#fff;stroke-width:0.5}</style>
+<rect x="0" y="0" width="100%%" height="100%%" fill="#fafafa" style="stroke:none"/>
+<text class="t" x="%d" y="20">%s</text>
+`, opt.Width, height, opt.Width, height, opt.FontSize, opt.FontSize+6, opt.Width/2, html.EscapeString(opt.Title))
+    sub := bands
+    if search != nil {
+        sub = append(sub, fmt.Sprintf("matched %q: %.2f%%", opt.Search, pctOf(r.matched, total)))
+    }
+    if opt.Base != nil {
+        sub = append(sub, "red = larger share than base, blue = smaller")
+    }
+    if len(sub) > 0 {
+        fmt.Fprintf(bw, "<text class=\"s\" x=\"%d\" y=\"36\">%s</text>\n", opt.Width/2, html.EscapeString(strings.Join(sub, " · ")))
+    }
+
+    charW := opt.FontSize * 0.59
+    for _, b := range r.boxes {
+        level := b.depth
+        y := svgHeaderHeight + level*opt.FrameHeight
+        if !opt.Icicle {
+            y = svgHeaderHeight + (r.depth-level)*opt.FrameHeight
+        }
+        fmt.Fprintf(bw, "<g><title>%s</title><rect x=\"%.2f\" y=\"%d\" width=\"%.2f\" height=\"%d\" fill=\"%s\" rx=\"2\"/>",
+            html.EscapeString(b.tooltip), b.x, y, b.w, opt.FrameHeight-1, b.fill)
+        if n := int((b.w - 6) / charW); n >= 3 {
+            label := b.name
+            if utf8.RuneCountInString(label) > n {
+                label = string([]rune(label)[:n-2]) + ".."
+            }
+            fmt.Fprintf(bw, "<text x=\"%.2f\" y=\"%.1f\">%s</text>", b.x+3, float64(y)+float64(opt.FrameHeight)/2+opt.FontSize/2-1, html.EscapeString(label))
+        }
+        bw.WriteString("</g>\n")
+    }
+    bw.WriteString("</svg>\n")
+    return bw.Flush()
+}
+
+type svgBox struct {
+    name, tooltip, fill string
+    x, w                float64
+    depth               int
+}
+
+type svgRenderer struct {
+    opt       SVGOptions
+    search    *regexp.Regexp
+    total     int64
+    scale     float64
+    baseTotal float64
+    maxDelta  float64
+    boxes     []svgBox
+    depth     int
+    matched   int64
+}
+
+// layoutValue clamps negative values (heap deltas) that cannot have a width.
+func layoutValue(v int64) int64 {
+    if v < 0 {
+        return 0
+    }
+    return v
+}
+
+func pctOf(v, total int64) float64 {
+    if total == 0 {
+        return 0
+    }
+    return float64(v) * 100 / float64(total)
+}
+
+// delta is the change of f's share of the total relative to base, in
+// percentage points.
+func (r *svgRenderer) delta(f, base *Frame) float64 {
+    var b int64
+    if base != nil {
+        b = base.Value
+    }
+    return float64(f.Value)*100/math.Max(float64(r.total), 1) - float64(b)*100/r.baseTotal
+}
+
+func (r *svgRenderer) maxChange(f, base *Frame) float64 {
+    m := 0.0
+    for name, c := range f.Children {
+        var bc *Frame
+        if base != nil {
+            bc = base.Children[name]
+        }
+        m = math.Max(m, math.Abs(r.delta(c, bc)))
+        m = math.Max(m, r.maxChange(c, bc))
+    }
+    return m
+}
+
+// visit lays out f at x and recurses; the root is drawn as "all".
+func (r *svgRenderer) visit(f, base *Frame, depth int, x float64, outermost bool) {
+    value := layoutValue(f.Value)
+    if depth == 0 {
+        value = r.total
+    }
+    w := float64(value) * r.scale
+    if w < r.opt.MinWidth {
+        return
+    }
+    if depth > r.depth {
+        r.depth = depth
+    }
+    name := f.Name
+    if depth == 0 {
+        name = "all"
+    }
+    box := svgBox{name: name, x: x, w: w, depth: depth}
+    box.tooltip = fmt.Sprintf("%s (%d, %.2f%%)", name, value, pctOf(value, r.total))
+    matches := depth > 0 && r.search != nil && r.search.MatchString(f.Name)
+    switch {
+    case matches:
+        box.fill = svgSearchColour
+        if outermost {
+            r.matched += value
+        }
+    case r.opt.Base != nil:
+        var d float64
+        if depth == 0 {
+            d = 0
+        } else {
+            d = r.delta(f, base)
+        }
+        box.fill = diffColour(d, r.maxDelta)
+        box.tooltip += fmt.Sprintf(", %+.2f pp vs base", d)
+    case depth == 0:
+        box.fill = "#d0d0d0"
+    default:
+        box.fill = r.opt.Colourer(f.Name, f.Value)
+    }
+    r.boxes = append(r.boxes, box)
+
+    names := make([]string, 0, len(f.Children))
+    for k := range f.Children {
+        names = append(names, k)
+    }
+    sort.Strings(names)
+    cx := x
+    for _, k := range names {
+        c := f.Children[k]
+        var bc *Frame
+        if base != nil {
+            bc = base.Children[k]
+        }
+        r.visit(c, bc, depth+1, cx, outermost && !matches)
+        cx += float64(layoutValue(c.Value)) * r.scale
+    }
+}
+
+// diffColour maps a change to white→red (growth) or white→blue (shrink),
+// saturated in proportion to |d| / max.
+func diffColour(d, max float64) string {
+    if max == 0 || d == 0 {
+        return "#e8e8e8"
+    }
+    k := math.Min(math.Abs(d)/max, 1)
+    fade := uint8(230 - 180*k)
+    if d > 0 {
+        return fmt.Sprintf("#%02x%02x%02x", 240, fade, fade)
+    }
+    return fmt.Sprintf("#%02x%02x%02x", fade, fade, 240)
+}
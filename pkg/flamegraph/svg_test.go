@@ -0,0 +1,95 @@
+package flamegraph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestRenderSVG(t *testing.T) {
+	b := NewBuilder("root")
+	b.Add(Sample{Stack: []string{"main", "encoding/json.Marshal"}, Weight: 30})
+	b.Add(Sample{Stack: []string{"main", "serve<T>"}, Weight: 70})
+	head := b.Build()
+
+	base := NewBuilder("root")
+	base.Add(Sample{Stack: []string{"main", "encoding/json.Marshal"}, Weight: 10})
+	base.Add(Sample{Stack: []string{"main", "serve<T>"}, Weight: 90})
+
+	var buf bytes.Buffer
+	err := RenderSVG(&buf, head, SVGOptions{Width: 400, Search: "json", Base: base.Build()})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	out := buf.String()
+
+	dec := xml.NewDecoder(strings.NewReader(out))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Expected well-formed XML: %v", err)
+		}
+	}
+	if !strings.Contains(out, `matched &#34;json&#34;: 30.00%`) {
+		t.Errorf("Expected search summary in output")
+	}
+	if !strings.Contains(out, "<title>serve&lt;T&gt; (70, 70.00%), -20.00 pp vs base</title>") {
+		t.Errorf("Expected escaped diff tooltip for serve<T>")
+	}
+	if !strings.Contains(out, `fill="`+svgSearchColour+`"`) {
+		t.Errorf("Expected highlighted search match")
+	}
+	if err := RenderSVG(io.Discard, head, SVGOptions{Search: "("}); err == nil {
+		t.Errorf("Expected error for invalid search regex")
+	}
+}
+
+func TestRenderSVGTruncatesRunes(t *testing.T) {
+	b := NewBuilder("root")
+	b.Add(Sample{Stack: []string{strings.Repeat("関数", 200)}, Weight: 1})
+
+	var buf bytes.Buffer
+	if err := RenderSVG(&buf, b.Build(), SVGOptions{Width: 300}); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	out := buf.String()
+	if !utf8.ValidString(out) {
+		t.Errorf("Expected valid UTF-8 after truncating a multi-byte label")
+	}
+	if !strings.Contains(out, "関数..</text>") && !strings.Contains(out, "関..</text>") {
+		t.Errorf("Expected the label cut at a rune boundary")
+	}
+}
+
+func TestRenderSVGKeepsBandsOutOfLayout(t *testing.T) {
+	width := func(withGC bool) string {
+		b := NewBuilder("root")
+		b.Add(Sample{Stack: []string{"main", "work"}, Weight: 30})
+		b.Add(Sample{Stack: []string{"main", "io"}, Weight: 10})
+		if withGC {
+			b.Add(Sample{Stack: []string{BandGC}, Weight: int64(1000 * time.Second)})
+		}
+		var buf bytes.Buffer
+		if err := RenderSVG(&buf, b.Build(), SVGOptions{Width: 400}); err != nil {
+			t.Fatalf("render: %v", err)
+		}
+		out := buf.String()
+		if withGC && (strings.Contains(out, "<title>(GC)") || !strings.Contains(out, "(GC) 16m40s")) {
+			t.Errorf("Expected (GC) listed under the title and not drawn as a frame")
+		}
+		m := regexp.MustCompile(`<title>work [^<]*</title><rect [^>]*width="([0-9.]+)"`).FindStringSubmatch(out)
+		if m == nil {
+			t.Fatalf("Expected a box for work in %s", out)
+		}
+		return m[1]
+	}
+	if plain, gc := width(false), width(true); plain != gc {
+		t.Errorf("Expected (GC) not to change frame widths, got %s and %s", plain, gc)
+	}
+}
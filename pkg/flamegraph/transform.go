@@ -0,0 +1,344 @@
+// pkg/flamegraph/transform.go
+// pprof‑style tree surgery.  Every Transform returns a new tree and leaves
+// its input untouched, so transforms can be applied to snapshots shared with
+// other readers (retention, replay) and chained freely:
+//
+//   • Focus(re)         – keep only stacks with a frame matching re
+//   • Ignore(re)        – drop stacks with a frame matching re
+//   • Hide(re)          – remove matching frames, their callees move up to
+//                         the caller
+//   • PruneBelow(f)     – drop subtrees worth less than fraction f of the
+//                         total; their weight stays with the parent as self
+//   • MaxDepth(n)       – cut stacks after n frames
+//   • CollapseRecursion – fold direct recursion (a;a;a;b → a;b)
+//   • Rename(re, repl)  – rewrite frame names, merging frames that end up
+//                         with the same name under the same caller
+//   • Invert            – bottom‑up tree (see butterfly.go)
+//   • GroupBy(g)        – frames re‑keyed to package or module
+//                         (see granularity.go)
+//
+// The runtime bands below the root are not stacks and pass through every
+// transform unchanged; PruneBelow measures shares without them.
+//
+// ParseTransform reads the textual form used by `--transform` flags and the
+// gateway's ?transform= parameter:
+//
+//	focus=RE  ignore=RE  hide=RE  prune=0.01|1%  maxdepth=N  collapse
+//	rename=RE=>REPL  invert  group=package|module
+package flamegraph
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Transform rewrites a tree into a new one without modifying its input.
+type Transform func(root *Frame) *Frame
+
+// Focus keeps only the stacks that pass through a frame matching re.
+func Focus(re *regexp.Regexp) Transform {
+    return func(root *Frame) *Frame {
+        return rewriteStacks(root, func(stack []string) []string {
+            if anyMatch(re, stack) {
+                return stack
+            }
+            return nil
+        })
+    }
+}
+
+// Ignore drops the stacks that pass through a frame matching re.
+func Ignore(re *regexp.Regexp) Transform {
+    return func(root *Frame) *Frame {
+        return rewriteStacks(root, func(stack []string) []string {
+            if anyMatch(re, stack) {
+                return nil
+            }
+            return stack
+        })
+    }
+}
+
+// Hide removes the frames matching re from every stack; what they called is
+// attributed to their caller.  Stacks made only of hidden frames become self
+// weight of the root.
+func Hide(re *regexp.Regexp) Transform {
+    return func(root *Frame) *Frame {
+        return rewriteStacks(root, func(stack []string) []string {
+            out := stack[:0:0]
+            for _, name := range stack {
+                if !re.MatchString(name) {
+                    out = append(out, name)
+                }
+            }
+            return out
+        })
+    }
+}
+
+// PruneBelow drops every subtree whose value is below fraction (0–1) of the
+// stack total.  Parents keep their values, so the pruned weight shows up as
+// their self weight.
+func PruneBelow(fraction float64) Transform {
+    return func(root *Frame) *Frame {
+        if root == nil {
+            return nil
+        }
+        floor := fraction * float64(Total(WithoutBands(root)))
+        var prune func(*Frame) *Frame
+        prune = func(f *Frame) *Frame {
+            out := &Frame{Name: f.Name, Value: f.Value, Children: make(map[string]*Frame, len(f.Children))}
+            for name, c := range f.Children {
+                if float64(abs64(c.Value)) < floor {
+                    continue
+                }
+                out.Children[name] = prune(c)
+            }
+            return out
+        }
+        out := &Frame{Name: root.Name, Value: root.Value, Children: make(map[string]*Frame, len(root.Children))}
+        for name, c := range root.Children {
+            switch {
+            case isBand(name):
+                out.Children[name] = deepCopy(c)
+            case float64(abs64(c.Value)) >= floor:
+                out.Children[name] = prune(c)
+            }
+        }
+        return out
+    }
+}
+
+// MaxDepth cuts every stack after n frames; the weight of deeper frames
+// becomes self weight of the frame at depth n.
+func MaxDepth(n int) Transform {
+    return func(root *Frame) *Frame {
+        return rewriteStacks(root, func(stack []string) []string {
+            if len(stack) > n {
+                return stack[:n]
+            }
+            return stack
+        })
+    }
+}
+
+// CollapseRecursion folds consecutive frames with the same name into one, so
+// a directly recursive function appears once per call chain.
+func CollapseRecursion(root *Frame) *Frame {
+    return rewriteStacks(root, func(stack []string) []string {
+        out := stack[:0:0]
+        for i, name := range stack {
+            if i == 0 || name != stack[i-1] {
+                out = append(out, name)
+            }
+        }
+        return out
+    })
+}
+
+// Rename replaces the matches of re in every frame name with repl, which may
+// reference submatches as in regexp.ReplaceAllString.
+func Rename(re *regexp.Regexp, repl string) Transform {
+    return func(root *Frame) *Frame {
+        return rewriteStacks(root, func(stack []string) []string {
+            out := make([]string, len(stack))
+            for i, name := range stack {
+                out[i] = re.ReplaceAllString(name, repl)
+            }
+            return out
+        })
+    }
+}
+
+// Chain applies ts in order.  An empty chain copies the tree.
+func Chain(ts ...Transform) Transform {
+    return func(root *Frame) *Frame {
+        if root == nil {
+            return nil
+        }
+        if len(ts) == 0 {
+            return deepCopy(root)
+        }
+        for _, t := range ts {
+            root = t(root)
+        }
+        return root
+    }
+}
+
+// ParseTransform parses one transform spec, e.g. "focus=^main\." or
+// "prune=1%".
+func ParseTransform(spec string) (Transform, error) {
+    op, arg, hasArg := strings.Cut(strings.TrimSpace(spec), "=")
+    op = strings.ToLower(strings.TrimSpace(op))
+    needArg := func() error {
+        if !hasArg || arg == "" {
+            return fmt.Errorf("transform %q: %s needs an argument", spec, op)
+        }
+        return nil
+    }
+    compile := func(expr string) (*regexp.Regexp, error) {
+        re, err := regexp.Compile(expr)
+        if err != nil {
+            return nil, fmt.Errorf("transform %q: %w", spec, err)
+        }
+        return re, nil
+    }
+
+    switch op {
+    case "focus", "ignore", "hide":
+        if err := needArg(); err != nil {
+            return nil, err
+        }
+        re, err := compile(arg)
+        if err != nil {
+            return nil, err
+        }
+        switch op {
+        case "focus":
+            return Focus(re), nil
+        case "ignore":
+            return Ignore(re), nil
+        }
+        return Hide(re), nil
+    case "prune":
+        if err := needArg(); err != nil {
+            return nil, err
+        }
+        pct := strings.HasSuffix(arg, "%")
+        f, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+        if pct {
+            f /= 100
+        }
+        if err != nil || f < 0 || f > 1 {
+            return nil, fmt.Errorf("transform %q: prune wants a fraction between 0 and 1 or a percentage", spec)
+        }
+        return PruneBelow(f), nil
+    case "maxdepth":
+        if err := needArg(); err != nil {
+            return nil, err
+        }
+        n, err := strconv.Atoi(arg)
+        if err != nil || n < 1 {
+            return nil, fmt.Errorf("transform %q: maxdepth wants a positive integer", spec)
+        }
+        return MaxDepth(n), nil
+    case "collapse", "collapse-recursion", "invert":
+        if hasArg {
+            return nil, fmt.Errorf("transform %q: %s takes no argument", spec, op)
+        }
+        if op == "invert" {
+            return Invert, nil
+        }
+        return CollapseRecursion, nil
+    case "group":
+        if err := needArg(); err != nil {
+            return nil, err
+        }
+        g, err := ParseGranularity(arg)
+        if err != nil {
+            return nil, fmt.Errorf("transform %q: %w", spec, err)
+        }
+        return GroupBy(g), nil
+    case "rename":
+        if err := needArg(); err != nil {
+            return nil, err
+        }
+        expr, repl, ok := strings.Cut(arg, "=>")
+        if !ok {
+            return nil, fmt.Errorf("transform %q: rename wants RE=>REPLACEMENT", spec)
+        }
+        re, err := compile(expr)
+        if err != nil {
+            return nil, err
+        }
+        return Rename(re, repl), nil
+    }
+    return nil, fmt.Errorf("transform %q: unknown operation %q (focus, ignore, hide, prune, maxdepth, collapse, rename, invert, group)", spec, op)
+}
+
+// ParseTransforms parses specs and chains them in order.  It returns nil for
+// no specs so callers can skip the copy.
+func ParseTransforms(specs []string) (Transform, error) {
+    if len(specs) == 0 {
+        return nil, nil
+    }
+    ts := make([]Transform, 0, len(specs))
+    for _, s := range specs {
+        t, err := ParseTransform(s)
+        if err != nil {
+            return nil, err
+        }
+        ts = append(ts, t)
+    }
+    return Chain(ts...), nil
+}
+
+//--------------------------------------------------------------------
+// helpers
+//--------------------------------------------------------------------
+
+// rewriteStacks rebuilds root from its self weights with every stack passed
+// through fn; a nil result drops the weight, an empty one moves it to the
+// root.  Bands are copied as they are.  A root with a value of its own keeps
+// it, less the dropped weight.
+func rewriteStacks(root *Frame, fn func(stack []string) []string) *Frame {
+    if root == nil {
+        return nil
+    }
+    out := New(root.Name)
+    out.Value = root.Value
+    for _, b := range RuntimeBands {
+        if c, ok := root.Children[b]; ok {
+            out.Children[b] = deepCopy(c)
+        }
+    }
+    var dropped, rootSelf int64
+    WalkSelf(root, func(stack []string, self int64) {
+        if isBand(stack[0]) {
+            return
+        }
+        switch s := fn(stack); {
+        case s == nil:
+            dropped += self
+        case len(s) == 0:
+            rootSelf += self
+        default:
+            out.AddSample(s, self)
+        }
+    })
+    switch {
+    case out.Value != 0:
+        out.Value -= dropped
+    case rootSelf != 0:
+        out.Value = Total(out) + rootSelf
+    }
+    return out
+}
+
+func anyMatch(re *regexp.Regexp, stack []string) bool {
+    for _, name := range stack {
+        if re.MatchString(name) {
+            return true
+        }
+    }
+    return false
+}
+
+func isBand(name string) bool {
+    for _, b := range RuntimeBands {
+        if name == b {
+            return true
+        }
+    }
+    return false
+}
+
+func abs64(v int64) int64 {
+    if v < 0 {
+        return -v
+    }
+    return v
+}
@@ -0,0 +1,97 @@
+package flamegraph
+
+import (
+	"regexp"
+	"testing"
+)
+
+func transformTree() *Frame {
+	b := NewBuilder("root")
+	b.Add(Sample{Stack: []string{"main", "walk", "walk", "leaf"}, Weight: 10})
+	b.Add(Sample{Stack: []string{"main", "runtime.mallocgc", "memclr"}, Weight: 6})
+	b.Add(Sample{Stack: []string{"main", "io"}, Weight: 3})
+	b.Add(Sample{Stack: []string{"main", "tiny"}, Weight: 1})
+	b.Add(Sample{Stack: []string{BandGC}, Weight: 500})
+	return b.Build()
+}
+
+func TestTransforms(t *testing.T) {
+	root := transformTree()
+	stacks := func(f *Frame) map[string]int64 {
+		out := map[string]int64{}
+		WalkSelf(f, func(stack []string, self int64) {
+			key := ""
+			for i, s := range stack {
+				if i > 0 {
+					key += ";"
+				}
+				key += s
+			}
+			out[key] = self
+		})
+		return out
+	}
+
+	focused := Focus(regexp.MustCompile(`^walk$`))(root)
+	if got := Total(WithoutBands(focused)); got != 10 {
+		t.Errorf("Expected focus total 10, got %d", got)
+	}
+	if focused.Children[BandGC] == nil || focused.Children[BandGC].Value != 500 {
+		t.Errorf("Expected bands to pass through focus")
+	}
+
+	ignored := Ignore(regexp.MustCompile(`^runtime\.`))(root)
+	if got := Total(WithoutBands(ignored)); got != 14 {
+		t.Errorf("Expected ignore total 14, got %d", got)
+	}
+
+	hidden := stacks(Hide(regexp.MustCompile(`^runtime\.`))(root))
+	if hidden["main;memclr"] != 6 {
+		t.Errorf("Expected hidden frame's callee under main, got %v", hidden)
+	}
+
+	collapsed := stacks(CollapseRecursion(root))
+	if collapsed["main;walk;leaf"] != 10 {
+		t.Errorf("Expected collapsed recursion, got %v", collapsed)
+	}
+
+	shallow := stacks(MaxDepth(2)(root))
+	if shallow["main;walk"] != 10 || shallow["main;runtime.mallocgc"] != 6 {
+		t.Errorf("Expected stacks cut at depth 2, got %v", shallow)
+	}
+
+	pruned := PruneBelow(0.2)(root)
+	main := pruned.Children["main"]
+	if main.Value != 20 || main.Children["tiny"] != nil || main.Children["io"] != nil || main.Children["walk"] == nil {
+		t.Errorf("Expected tiny and io pruned under an unchanged main, got %+v", main)
+	}
+
+	renamed := Rename(regexp.MustCompile(`^(walk|io)$`), "work")(root)
+	if w := renamed.Children["main"].Children["work"]; w == nil || w.Value != 13 {
+		t.Errorf("Expected renamed frames merged, got %+v", w)
+	}
+
+	if root.Children["main"].Children["walk"].Children["walk"] == nil || root.Children["main"].Value != 20 {
+		t.Error("Expected transforms to leave the input untouched")
+	}
+}
+
+func TestParseTransforms(t *testing.T) {
+	tr, err := ParseTransforms([]string{"ignore=^runtime\\.", "rename=^(walk|io)$=>work", "prune=50%", "collapse"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := tr(transformTree())
+	main := out.Children["main"]
+	if len(main.Children) != 1 || main.Children["work"] == nil {
+		t.Errorf("Expected only work to survive, got %+v", main.Children)
+	}
+	for _, bad := range []string{"focus", "prune=2", "maxdepth=0", "rename=x", "collapse=1", "zoom=x", "hide=("} {
+		if _, err := ParseTransform(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+	if tr, err := ParseTransforms(nil); tr != nil || err != nil {
+		t.Errorf("Expected nil transform for no specs")
+	}
+}
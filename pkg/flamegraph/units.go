@@ -0,0 +1,68 @@
+// pkg/flamegraph/units.go
+// Frame values carry no unit of their own: goroutine and pprof stacks count
+// samples, the (GC) band pauses in nanoseconds, (Heap) bytes and (Blocked)
+// goroutines.  These helpers name the unit of a value and print it the way a
+// reader expects – durations for time, binary multiples for bytes, plain
+// integers for counts – instead of formatting everything as a duration.
+package flamegraph
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Units, named as in pprof sample types.
+const (
+    UnitSamples     = "samples"
+    UnitNanoseconds = "nanoseconds"
+    UnitBytes       = "bytes"
+    UnitCount       = "count"
+)
+
+// BandUnit returns the unit of a runtime band frame name.
+func BandUnit(name string) (string, bool) {
+    switch name {
+    case BandGC:
+        return UnitNanoseconds, true
+    case BandHeap:
+        return UnitBytes, true
+    case BandBlocked:
+        return UnitCount, true
+    }
+    return "", false
+}
+
+// FormatValue renders v in unit.  Unknown units print the bare number
+// followed by the unit name.
+func FormatValue(v int64, unit string) string {
+    switch unit {
+    case UnitNanoseconds:
+        return time.Duration(v).String()
+    case UnitBytes:
+        return formatBytes(v)
+    case UnitSamples, UnitCount, "":
+        return strconv.FormatInt(v, 10)
+    }
+    return strconv.FormatInt(v, 10) + " " + unit
+}
+
+func formatBytes(v int64) string {
+    units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+    f := float64(v)
+    if f < 0 {
+        f = -f
+    }
+    i := 0
+    for f >= 1024 && i < len(units)-1 {
+        f /= 1024
+        i++
+    }
+    if v < 0 {
+        f = -f
+    }
+    if i == 0 {
+        return fmt.Sprintf("%d B", v)
+    }
+    return fmt.Sprintf("%.1f %s", f, units[i])
+}
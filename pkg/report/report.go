@@ -0,0 +1,124 @@
+// pkg/report/report.go
+// Package report renders a self‑contained interactive HTML flamegraph: one
+// file with the frame tree embedded as JSON and a small vanilla JS viewer,
+// no external scripts, fonts or styles, so it can be attached to postmortems
+// and tickets and opened offline.
+//
+// The page is generated from an html/template (report.html.tmpl, embedded in
+// the binary).  The viewer supports click‑to‑zoom, regex search with the
+// matched share, a call tree, an inverted (bottom‑up) tree and a sandwich
+// view of one function's callers and callees, and – when a base recording is
+// given – diff colouring by the change of each frame's share of the total.
+// The runtime bands are listed in the header with their own units instead of
+// being drawn, so shares only count the stacks.
+package report
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+	"github.com/Voskan/flarego/pkg/version"
+)
+
+//go:embed report.html.tmpl
+var pageSource string
+
+var page = template.Must(template.New("report").Parse(pageSource))
+
+// Options describe the report.  Zero values select the defaults.
+type Options struct {
+    Title    string            // default "FlareGo report"
+    HeadName string            // label of the main recording, e.g. its file name
+    Base     *flamegraph.Frame // optional earlier recording to diff against
+    BaseName string
+    MinShare float64   // drop frames below this fraction of the total (e.g. 0.0001)
+    Now      time.Time // generation time shown in the footer (default time.Now)
+}
+
+// node is the compact tree shape the viewer consumes.
+type node struct {
+    N string `json:"n"`
+    V int64  `json:"v"`
+    C []node `json:"c,omitempty"`
+}
+
+// band is a runtime band of the head recording, kept out of the trees
+// because its unit is not the unit of the stacks.
+type band struct {
+    Name  string
+    Value int64
+    Unit  string
+    Text  string // Value formatted in Unit
+}
+
+type pageData struct {
+    Title     string
+    HeadName  string
+    BaseName  string
+    Generated string
+    Version   string
+    Head      node
+    Base      *node
+    Bands     []band
+}
+
+// Write renders the report for head.
+func Write(w io.Writer, head *flamegraph.Frame, opt Options) error {
+    if opt.Title == "" {
+        opt.Title = "FlareGo report"
+    }
+    if opt.Now.IsZero() {
+        opt.Now = time.Now()
+    }
+    data := pageData{
+        Title:     opt.Title,
+        HeadName:  opt.HeadName,
+        BaseName:  opt.BaseName,
+        Generated: opt.Now.UTC().Format(time.RFC3339),
+        Version:   version.String(),
+        Head:      compact(flamegraph.WithoutBands(head), opt.MinShare),
+    }
+    if opt.Base != nil {
+        b := compact(flamegraph.WithoutBands(opt.Base), opt.MinShare)
+        data.Base = &b
+    }
+    for _, name := range flamegraph.RuntimeBands {
+        if head == nil || head.Children[name] == nil {
+            continue
+        }
+        v := head.Children[name].Value
+        unit, _ := flamegraph.BandUnit(name)
+        data.Bands = append(data.Bands, band{Name: name, Value: v, Unit: unit, Text: flamegraph.FormatValue(v, unit)})
+    }
+    return page.Execute(w, data)
+}
+
+// compact converts f, dropping subtrees below minShare of the total.
+func compact(f *flamegraph.Frame, minShare float64) node {
+    if f == nil {
+        return node{N: "root"}
+    }
+    limit := int64(minShare * float64(flamegraph.Total(f)))
+    var conv func(*flamegraph.Frame) node
+    conv = func(f *flamegraph.Frame) node {
+        n := node{N: f.Name, V: f.Value}
+        names := make([]string, 0, len(f.Children))
+        for k := range f.Children {
+            names = append(names, k)
+        }
+        sort.Strings(names)
+        for _, k := range names {
+            c := f.Children[k]
+            if limit > 0 && c.Value < limit && c.Value > -limit {
+                continue
+            }
+            n.C = append(n.C, conv(c))
+        }
+        return n
+    }
+    return conv(f)
+}
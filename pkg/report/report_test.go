@@ -0,0 +1,66 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Voskan/flarego/pkg/flamegraph"
+)
+
+func TestWrite(t *testing.T) {
+	b := flamegraph.NewBuilder("root")
+	b.Add(flamegraph.Sample{Stack: []string{"main", "</script><b>evil"}, Weight: 99})
+	b.Add(flamegraph.Sample{Stack: []string{"main", "tiny"}, Weight: 1})
+
+	var buf bytes.Buffer
+	err := Write(&buf, b.Build(), Options{
+		Title:    "Incident <42>",
+		HeadName: "after.fgo",
+		MinShare: 0.05,
+		Now:      time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "<title>Incident &lt;42&gt;</title>") {
+		t.Errorf("Expected escaped title")
+	}
+	if strings.Count(out, "</script>") != 1 {
+		t.Errorf("Expected frame names to be escaped inside the script block")
+	}
+	if strings.Contains(out, `"tiny"`) {
+		t.Errorf("Expected frames below MinShare to be dropped")
+	}
+	if !strings.Contains(out, "var BASE =  null ;") {
+		t.Errorf("Expected no base tree without Options.Base")
+	}
+	if strings.Contains(out, "src=") || strings.Contains(out, "href=\"http") {
+		t.Errorf("Expected no external assets")
+	}
+}
+
+func TestWriteKeepsBandsOutOfTree(t *testing.T) {
+	b := flamegraph.NewBuilder("root")
+	b.Add(flamegraph.Sample{Stack: []string{"main", "work"}, Weight: 10})
+	b.Add(flamegraph.Sample{Stack: []string{flamegraph.BandGC}, Weight: int64(1000 * time.Second)})
+	b.Add(flamegraph.Sample{Stack: []string{flamegraph.BandHeap}, Weight: 2 << 20})
+	head := b.Build()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, head, Options{Base: head}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out := buf.String()
+	if strings.Contains(out, `"n":"(GC)"`) || strings.Contains(out, `"n":"(Heap)"`) {
+		t.Errorf("Expected runtime bands to be left out of the embedded trees")
+	}
+	if !strings.Contains(out, "<b>(GC)</b> 16m40s") || !strings.Contains(out, "<b>(Heap)</b> 2.0 MiB") {
+		t.Errorf("Expected runtime bands listed with their units")
+	}
+	if head.Children[flamegraph.BandGC] == nil {
+		t.Errorf("Expected the caller's tree to be left alone")
+	}
+}
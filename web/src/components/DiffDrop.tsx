@@ -1,5 +1,6 @@
 import React, { useRef, useState } from "react";
 import { FlameGraphCanvas } from "./FlameGraphCanvas";
+import { readProfile } from "../utils/fgo";
 
 function computeDiff(head: any, base: any): any {
   // Простой рекурсивный diff для flamegraph (JS, не учитывает все edge-cases)
@@ -37,18 +38,7 @@ export const DiffDrop: React.FC = () => {
   const [diff, setDiff] = useState<any | null>(null);
 
   const handleFile = async (file: File, setter: (d: any) => void) => {
-    const buf = await file.arrayBuffer();
-    let data: any;
-    try {
-      // eslint-disable-next-line @typescript-eslint/no-var-requires
-      const pako: any = require("pako");
-      const gunzip = pako.ungzip;
-      const json = new TextDecoder().decode(gunzip(new Uint8Array(buf)));
-      data = JSON.parse(json);
-    } catch {
-      data = JSON.parse(new TextDecoder().decode(buf));
-    }
-    setter(data);
+    setter(await readProfile(await file.arrayBuffer()));
   };
 
   React.useEffect(() => {
@@ -1,4 +1,5 @@
 import React, { useRef } from "react";
+import { readProfile } from "../utils/fgo";
 
 export const ReplayDrop: React.FC<{ onLoad: (data: any) => void }> = ({
   onLoad,
@@ -6,20 +7,7 @@ export const ReplayDrop: React.FC<{ onLoad: (data: any) => void }> = ({
   const inputRef = useRef<HTMLInputElement>(null);
 
   const handleFile = async (file: File) => {
-    const buf = await file.arrayBuffer();
-    let data: any;
-    try {
-      // Try gzip
-      // eslint-disable-next-line @typescript-eslint/no-var-requires
-      const pako: any = require("pako");
-      const gunzip = pako.ungzip;
-      const json = new TextDecoder().decode(gunzip(new Uint8Array(buf)));
-      data = JSON.parse(json);
-    } catch {
-      // Fallback: plain JSON
-      data = JSON.parse(new TextDecoder().decode(buf));
-    }
-    onLoad(data);
+    onLoad(await readProfile(await file.arrayBuffer()));
   };
 
   return (
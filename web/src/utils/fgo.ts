@@ -0,0 +1,73 @@
+// web/src/utils/fgo.ts
+// Reads the files the CLI writes into one tree for the components.  A .fgo
+// v2 container (pkg/flamegraph/container.go) is the magic "FLAREGO" 0x02
+// followed by blocks of kind byte, uvarint length and payload; its snapshot
+// blocks ('S' gzipped, 's' plain JSON) are merged like `flarego replay`
+// does without a time range.  Scanning stops at the index block or where a
+// recording was cut short.  Anything else is a v1 .fgo (gzipped Frame JSON)
+// or plain JSON in either schema.
+
+import { FrameNode, normalizeFrame } from "./frame";
+
+const containerMagic = [0x46, 0x4c, 0x41, 0x52, 0x45, 0x47, 0x4f, 0x02];
+
+export async function readProfile(buf: ArrayBuffer): Promise<FrameNode> {
+  const bytes = new Uint8Array(buf);
+  if (containerMagic.every((b, i) => bytes[i] === b)) {
+    return readContainer(bytes);
+  }
+  return normalizeFrame(JSON.parse(await decodeText(bytes)));
+}
+
+async function readContainer(bytes: Uint8Array): Promise<FrameNode> {
+  let root: FrameNode | null = null;
+  let off = containerMagic.length;
+  while (off < bytes.length) {
+    const kind = String.fromCharCode(bytes[off]);
+    const len = uvarint(bytes, off + 1);
+    if (!len || len.end + len.value > bytes.length) break;
+    const payload = bytes.subarray(len.end, len.end + len.value);
+    off = len.end + len.value;
+    if (kind === "I") break;
+    if (kind !== "S" && kind !== "s") continue;
+    const snap = JSON.parse(await decodeText(payload));
+    const tree = normalizeFrame(snap.root || { name: "root", value: 0 });
+    root = root ? mergeFrame(root, tree) : tree;
+  }
+  return root || { name: "root", value: 0, children: {} };
+}
+
+// decodeText gunzips bytes when they carry the gzip magic.
+async function decodeText(bytes: Uint8Array): Promise<string> {
+  if (bytes[0] !== 0x1f || bytes[1] !== 0x8b) {
+    return new TextDecoder().decode(bytes);
+  }
+  // A fresh copy is backed by a plain ArrayBuffer, which Blob requires.
+  const copy = new Uint8Array(bytes.length);
+  copy.set(bytes);
+  const stream = new Blob([copy])
+    .stream()
+    .pipeThrough(new DecompressionStream("gzip"));
+  return new Response(stream).text();
+}
+
+function uvarint(
+  bytes: Uint8Array,
+  off: number
+): { value: number; end: number } | null {
+  let value = 0;
+  for (let i = off, shift = 0; i < bytes.length && shift < 53; i++) {
+    value += (bytes[i] & 0x7f) * 2 ** shift;
+    if (bytes[i] < 0x80) return { value, end: i + 1 };
+    shift += 7;
+  }
+  return null;
+}
+
+function mergeFrame(into: FrameNode, from: FrameNode): FrameNode {
+  into.value += from.value;
+  for (const [k, c] of Object.entries(from.children)) {
+    into.children[k] = into.children[k] ? mergeFrame(into.children[k], c) : c;
+  }
+  return into;
+}
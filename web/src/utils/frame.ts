@@ -0,0 +1,27 @@
+// web/src/utils/frame.ts
+// Normalises flamegraph JSON to the version 1 shape the components work on
+// (children keyed by name).  Files written by `flarego convert --to json`,
+// `flarego replay --json` or the gateway's /query use the ordered version 2
+// schema instead: a {version, unit, meta, root} document whose children are
+// arrays.
+
+export interface FrameNode {
+  name: string;
+  value: number;
+  children: Record<string, FrameNode>;
+}
+
+export function normalizeFrame(data: any): FrameNode {
+  const node = data && data.root && data.version ? data.root : data;
+  const children: Record<string, FrameNode> = {};
+  if (Array.isArray(node.children)) {
+    for (const c of node.children) {
+      children[c.name] = normalizeFrame(c);
+    }
+  } else if (node.children) {
+    for (const [k, c] of Object.entries(node.children)) {
+      children[k] = normalizeFrame(c);
+    }
+  }
+  return { name: node.name, value: node.value || 0, children };
+}